@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/Bitspark/slang/pkg/api"
+	"github.com/Bitspark/slang/pkg/core"
+)
+
+const executableBundle = "bundle.slang.json"
+
+var nameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+var executableTemplate = template.Must(template.New("main").Parse(`// Code generated by slang build. DO NOT EDIT.
+
+// {{.Name}} runs the Slang operator {{.Id}}.
+package main
+
+import (
+	_ "embed"
+	"log"
+	"os"
+
+	"github.com/Bitspark/slang/pkg/api"
+)
+
+//go:embed {{.Bundle}}
+var bundle []byte
+
+func main() {
+	if err := api.RunExecutable(bundle, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+`))
+
+func buildUsage(fs *flag.FlagSet) {
+	fmt.Println("USAGE: slang build [OPTIONS] OPERATOR")
+	fmt.Println("OPERATOR is an operator id, the path to an operator file (YAML or JSON) or to a bundled SLANGFILE.slang.json or .slang.yaml.")
+	fmt.Println("The executable serves the operator over stdin/stdout, HTTP (-http) or the slangr wire protocol (-socket-in, -socket-out).")
+	fmt.Println("Building requires Go 1.16 or newer and the slang packages to be found by the go tool, e.g. in GOPATH.")
+	fmt.Println("OPTIONS:")
+	fs.PrintDefaults()
+}
+
+// runBuild generates a Go program embedding the operator and its dependencies and builds it
+func runBuild(args []string) error {
+	props, gens := keyValues{}, keyValues{}
+
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	dir := fs.String("dir", os.Getenv("SLANG_DIR"), "project directory containing the operators, defaults to env var SLANG_DIR or the current directory")
+	lib := fs.String("lib", os.Getenv("SLANG_LIB"), "library directory, defaults to env var SLANG_LIB")
+	fs.Var(props, "prop", "property value as KEY=VALUE, where VALUE is YAML, may be repeated")
+	fs.Var(gens, "gen", "generic type as KEY=TYPE, where TYPE is a type name or a YAML type definition, may be repeated")
+	out := fs.String("o", "", "name of the executable, defaults to the name of the operator")
+	emit := fs.String("emit", "", "write the sources of the program into the directory instead of building it")
+	fs.Usage = func() { buildUsage(fs) }
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		buildUsage(fs)
+		return nil
+	}
+	if *dir == "" {
+		*dir = "."
+	}
+
+	slFile, err := resolveSlangFile(fs.Arg(0), *dir, *lib, api.SlangFileOptions{ShakeDelegates: true, ShakeTests: true})
+	if err != nil {
+		return err
+	}
+	if err := applyArgs(slFile, props, gens); err != nil {
+		return err
+	}
+	// The operator has to be buildable with the embedded arguments
+	if _, err := api.NewExecutable(slFile); err != nil {
+		return err
+	}
+
+	name := executableName(slFile)
+	if *out == "" {
+		*out = name
+	}
+
+	src := *emit
+	if src == "" {
+		if src, err = ioutil.TempDir("", "slang-build"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(src)
+	} else if err := os.MkdirAll(src, 0755); err != nil {
+		return err
+	}
+
+	if err := writeExecutable(src, name, slFile); err != nil {
+		return err
+	}
+	if *emit != "" {
+		return nil
+	}
+
+	target, err := filepath.Abs(*out)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("go", "build", "-o", target, ".")
+	cmd.Dir = src
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if os.Getenv("GO111MODULE") == "" {
+		// The generated program has no module, the slang packages are taken from GOPATH
+		cmd.Env = append(cmd.Env, "GO111MODULE=off")
+	}
+	return cmd.Run()
+}
+
+func executableName(slFile *core.SlangFileDef) string {
+	for _, bp := range slFile.Blueprints {
+		if bp.Id == slFile.Main && bp.Meta.Name != "" {
+			return strings.ToLower(nameInvalidChars.ReplaceAllString(strings.TrimSpace(bp.Meta.Name), "-"))
+		}
+	}
+	return slFile.Main
+}
+
+func writeExecutable(dir string, name string, slFile *core.SlangFileDef) error {
+	b, err := json.Marshal(slFile)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, executableBundle), b, 0644); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(dir, "main.go"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return executableTemplate.Execute(f, struct {
+		Name   string
+		Id     string
+		Bundle string
+	}{name, slFile.Main, executableBundle})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/Bitspark/slang/pkg/api"
+	"gopkg.in/yaml.v2"
+)
+
+func bundleUsage(fs *flag.FlagSet) {
+	fmt.Println("USAGE: slang bundle [OPTIONS] OPERATOR")
+	fmt.Println("OPERATOR is an operator id or the path to an operator file (YAML or JSON).")
+	fmt.Println("OPTIONS:")
+	fs.PrintDefaults()
+}
+
+// runBundle writes the operator together with all operators it depends on into a SlangFileDef
+func runBundle(args []string) error {
+	props, gens := keyValues{}, keyValues{}
+
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	dir := fs.String("dir", os.Getenv("SLANG_DIR"), "project directory containing the operators, defaults to env var SLANG_DIR or the current directory")
+	lib := fs.String("lib", os.Getenv("SLANG_LIB"), "library directory, defaults to env var SLANG_LIB")
+	fs.Var(props, "prop", "default property value as KEY=VALUE, where VALUE is YAML, may be repeated")
+	fs.Var(gens, "gen", "default generic type as KEY=TYPE, where TYPE is a type name or a YAML type definition, may be repeated")
+	format := fs.String("format", "json", "output format, json or yaml")
+	out := fs.String("o", "", "output file, defaults to stdout")
+	shake := fs.Bool("shake", false, "leave out unused delegates and test cases")
+	fs.Usage = func() { bundleUsage(fs) }
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		bundleUsage(fs)
+		return nil
+	}
+	if *dir == "" {
+		*dir = "."
+	}
+
+	opId, st, err := resolveOperator(fs.Arg(0), *dir, *lib)
+	if err != nil {
+		return err
+	}
+	slFile, err := api.CreateSlangFile(opId, api.SlangFileOptions{ShakeDelegates: *shake, ShakeTests: *shake}, *st)
+	if err != nil {
+		return err
+	}
+	if err := applyArgs(slFile, props, gens); err != nil {
+		return err
+	}
+
+	var b []byte
+	switch *format {
+	case "json":
+		b, err = json.MarshalIndent(slFile, "", "  ")
+	case "yaml":
+		b, err = yaml.Marshal(slFile)
+	default:
+		return fmt.Errorf("unknown format %s", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(append(b, '\n'))
+		return err
+	}
+	return ioutil.WriteFile(*out, b, 0644)
+}
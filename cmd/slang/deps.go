@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Bitspark/slang/pkg/registry"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/google/uuid"
+)
+
+func depsUsage(fs *flag.FlagSet) {
+	fmt.Println("USAGE: slang deps [OPTIONS] OPERATOR_ID")
+	fmt.Println("OPTIONS:")
+	fs.PrintDefaults()
+}
+
+// runDeps prints the operators the given operator depends on or, with -dependents, the operators using it
+func runDeps(args []string) error {
+	fs := flag.NewFlagSet("deps", flag.ExitOnError)
+	dir := fs.String("dir", ".", "project directory containing the operators")
+	lib := fs.String("lib", os.Getenv("SLANG_LIB"), "library directory, defaults to env var SLANG_LIB")
+	dependents := fs.Bool("dependents", false, "list operators using the operator instead of the ones it uses")
+	direct := fs.Bool("direct", false, "list direct dependencies only")
+	fs.Usage = func() { depsUsage(fs) }
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		depsUsage(fs)
+		return nil
+	}
+
+	opId, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid operator id %s", fs.Arg(0))
+	}
+
+	st := storage.
+		NewStorage(storage.NewFileSystem(*dir)).
+		AddLoader(storage.NewPackageLoader(filepath.Join(*dir, registry.PACKAGES_DIR)))
+	if *lib != "" {
+		st.AddLoader(storage.NewFileSystem(filepath.Join(*lib, "slang")))
+	}
+
+	graph, err := st.DependencyGraph()
+	if err != nil {
+		return err
+	}
+	if !graph.Has(opId) {
+		return fmt.Errorf("unknown operator for id: %s", opId)
+	}
+
+	ids := graph.Dependencies(opId, !*direct)
+	if *dependents {
+		ids = graph.Dependents(opId, !*direct)
+	}
+	for _, id := range ids {
+		fmt.Printf("%s  %s\n", id, graph.Name(id))
+	}
+	return nil
+}
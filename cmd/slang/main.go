@@ -7,22 +7,77 @@ import (
 	"fmt"
 	"github.com/Bitspark/slang/pkg/api"
 	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/pkg/utils"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
 	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 )
 
 var printPorts bool
+var policyFile string
+var encoding string
+var workers int
+var drainTimeout time.Duration
+
+// inputDone is signalled once all input has been sent to the runner, outputDone once the runner closed the output
+var inputDone = make(chan bool, 1)
+var outputDone = make(chan bool, 1)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "pkg" {
+		if err := runPkg(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		if err := runRun(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		if err := runBundle(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "build" {
+		if err := runBuild(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "deps" {
+		if err := runDeps(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	flag.BoolVar(&printPorts, "print-ports", false, "display port def")
+	flag.StringVar(&encoding, "encoding", api.ENCODING_CBOR, "encoding of values sent to and received from the runner, cbor or json")
+	flag.DurationVar(&drainTimeout, "drain-timeout", 10*time.Second, "time the runner has for processing remaining items after the input has ended")
+	flag.IntVar(&workers, "workers", 1, "number of slangr workers the operator is split across")
+	flag.StringVar(&policyFile, "policy", "", "file restricting the capabilities of operators, defaults to policy.yaml next to SLANGFILE")
 
 	if len(os.Args) < 2 {
 		fmt.Println("USAGE: slang [OPTIONS] SLANGFILE.slang.json")
+		fmt.Println("       slang run [OPTIONS] OPERATOR")
+		fmt.Println("       slang bundle [OPTIONS] OPERATOR")
+		fmt.Println("       slang build [OPTIONS] OPERATOR")
+		fmt.Println("       slang pkg [OPTIONS] COMMAND [PACKAGES]")
+		fmt.Println("       slang deps [OPTIONS] OPERATOR_ID")
 		fmt.Println("OPTIONS:")
 		flag.PrintDefaults()
 		return
@@ -43,7 +98,18 @@ func main() {
 		return
 	}
 
-	if err := run(slFile); err != nil {
+	if policyFile == "" {
+		if p := filepath.Join(filepath.Dir(flag.Arg(0)), "policy.yaml"); utils.FileExists(p) {
+			policyFile = p
+		}
+	}
+
+	if workers > 1 {
+		err = runDistributed(slFile)
+	} else {
+		err = run(slFile)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 
@@ -57,6 +123,16 @@ func readSlangFile(slFilePath string) (*core.SlangFileDef, error) {
 	if err != nil {
 		return &slFile, fmt.Errorf("could not read operator file: %s", slFilePath)
 	}
+	if utils.IsYAML(slFilePath) {
+		err = yaml.Unmarshal(b, &slFile)
+		slFile.Args.Properties.Clean()
+		for _, bp := range slFile.Blueprints {
+			for _, insDef := range bp.InstanceDefs {
+				insDef.Properties.Clean()
+			}
+		}
+		return &slFile, err
+	}
 	err = json.Unmarshal(b, &slFile)
 	return &slFile, err
 }
@@ -86,6 +162,7 @@ func run(slFile *core.SlangFileDef) error {
 	errors := make(chan error, 1)
 	done := make(chan bool, 1)
 	portcfgs := make(chan map[string]string, 1)
+	var cmds api.Commands
 
 	cmdr := api.NewCommander(":0")
 
@@ -115,6 +192,7 @@ func run(slFile *core.SlangFileDef) error {
 				return err
 			}
 
+			cmds = c
 			portcfgs <- pcfg
 			return nil
 		})
@@ -124,7 +202,11 @@ func run(slFile *core.SlangFileDef) error {
 		}
 	}()
 
-	cmd := exec.Command("slangr", "--aggr-in", "--aggr-out", "--mgnt-addr", fmt.Sprintf("%s", cmdr.Addr()))
+	args := []string{"--aggr-in", "--aggr-out", "--mgnt-addr", fmt.Sprintf("%s", cmdr.Addr())}
+	if policyFile != "" {
+		args = append(args, "--policy", policyFile)
+	}
+	cmd := exec.Command("slangr", args...)
 
 	cmd.Stderr = os.Stderr
 
@@ -156,10 +238,109 @@ func run(slFile *core.SlangFileDef) error {
 		return err
 	}
 
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case <-done:
+		return nil
+	case <-inputDone:
+		// Let the runner finish the remaining items before stopping it
+		if _, err := cmds.Drain(drainTimeout); err != nil {
+			wrerr(err)
+		}
+	case <-quit:
+	}
+
+	if _, err := cmds.Stop(); err != nil {
+		return err
+	}
 	<-done
+
+	select {
+	case <-outputDone:
+	case <-time.After(time.Second):
+	}
 	return nil
 }
 
+// runDistributed splits the operator across several slangr workers and serves its main ports on stdin and stdout
+func runDistributed(slFile *core.SlangFileDef) error {
+	opId, err := uuid.Parse(slFile.Main)
+	if err != nil {
+		return err
+	}
+	stor := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(slFile.Blueprints...))
+
+	partition, err := api.PartitionOperator(opId, nil, nil, *stor, workers, nil)
+	if err != nil {
+		return err
+	}
+	cluster, err := api.NewCluster(partition)
+	if err != nil {
+		return err
+	}
+	cluster.Encoding = encoding
+
+	var args []string
+	if policyFile != "" {
+		args = append(args, "--policy", policyFile)
+	}
+	if err := cluster.Start(api.LocalSpawner("slangr", args...)); err != nil {
+		return err
+	}
+	defer cluster.Stop()
+
+	main := cluster.Main()
+	go func() {
+		stdin := bufio.NewReader(os.Stdin)
+		for {
+			m, err := api.Rdbuf(stdin)
+			if err == io.EOF {
+				inputDone <- true
+				return
+			}
+			if err != nil {
+				wrerr(err)
+				continue
+			}
+
+			var v interface{}
+			if len(m) != 0 {
+				if v, err = api.DecodeValue(api.ENCODING_JSON, []byte(m)); err != nil {
+					wrerr(fmt.Errorf("invalid input %s: %s", m, err))
+					continue
+				}
+			}
+			main.In().Push(api.BindMarker(main.In(), v))
+		}
+	}()
+	go func() {
+		stdout := bufio.NewWriter(os.Stdout)
+		for {
+			m, err := api.EncodeValue(api.ENCODING_JSON, main.Out().Pull())
+			if err != nil {
+				wrerr(err)
+				continue
+			}
+			if err := api.Wrbuf(stdout, string(m)); err != nil {
+				wrerr(err)
+				return
+			}
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case <-inputDone:
+		return cluster.Drain(drainTimeout)
+	case <-quit:
+		return nil
+	}
+}
+
 func jsonString(j interface{}) string {
 	jb, _ := json.Marshal(j)
 	return string(jb)
@@ -173,16 +354,36 @@ func wrerr(err error) {
 
 func pushToRnr(connRnr net.Conn) bool {
 	stdin := bufio.NewReader(os.Stdin)
-	wrRnr := bufio.NewWriter(connRnr)
+	wc := api.NewWireConn(connRnr)
 
 	defer connRnr.Close()
 
+	if _, err := wc.Handshake(encoding); err != nil {
+		wrerr(err)
+		return false
+	}
+
+	// Values the runner cannot decode are reported back
+	go func() {
+		for {
+			_, err := wc.ReadValue()
+			if _, ok := err.(*api.RemoteError); ok {
+				wrerr(err)
+				continue
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
 	for {
 		m, err := api.Rdbuf(stdin)
 
 		time.Sleep(1 * time.Second)
 
 		if err == io.EOF {
+			inputDone <- true
 			break
 		}
 
@@ -191,7 +392,15 @@ func pushToRnr(connRnr net.Conn) bool {
 			continue
 		}
 
-		if err := api.Wrbuf(wrRnr, m); err != nil {
+		var v interface{}
+		if len(m) != 0 {
+			if v, err = api.DecodeValue(api.ENCODING_JSON, []byte(m)); err != nil {
+				wrerr(fmt.Errorf("invalid input %s: %s", m, err))
+				continue
+			}
+		}
+
+		if err := wc.WriteValue(v); err != nil {
 			break
 		}
 	}
@@ -200,24 +409,39 @@ func pushToRnr(connRnr net.Conn) bool {
 }
 
 func pullFromRnr(connRnr net.Conn) bool {
-	rdRnr := bufio.NewReader(connRnr)
+	wc := api.NewWireConn(connRnr)
 	stdout := bufio.NewWriter(os.Stdout)
 
 	defer connRnr.Close()
+	defer func() { outputDone <- true }()
+
+	if _, err := wc.Handshake(encoding); err != nil {
+		wrerr(err)
+		return false
+	}
 
 	for {
-		m, err := api.Rdbuf(rdRnr)
+		v, err := wc.ReadValue()
 
 		if err == io.EOF {
 			break
 		}
 
+		if err != nil {
+			wrerr(err)
+			if _, ok := err.(*api.DecodeError); ok {
+				continue
+			}
+			break
+		}
+
+		m, err := api.EncodeValue(api.ENCODING_JSON, v)
 		if err != nil {
 			wrerr(err)
 			continue
 		}
 
-		if err := api.Wrbuf(stdout, m); err != nil {
+		if err := api.Wrbuf(stdout, string(m)); err != nil {
 			wrerr(err)
 			break
 		}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Bitspark/slang/pkg/registry"
+)
+
+func pkgUsage(fs *flag.FlagSet) {
+	fmt.Println("USAGE: slang pkg [OPTIONS] COMMAND [PACKAGES]")
+	fmt.Println("COMMANDS:")
+	fmt.Println("  install                       install all packages pinned by the lock file")
+	fmt.Println("  install NAME[@CONSTRAINT]...  install packages and add them to the lock file")
+	fmt.Println("  update [NAME...]              update packages within their version constraints")
+	fmt.Println("  remove NAME...                remove packages")
+	fmt.Println("OPTIONS:")
+	fs.PrintDefaults()
+}
+
+func runPkg(args []string) error {
+	fs := flag.NewFlagSet("pkg", flag.ExitOnError)
+	dir := fs.String("dir", ".", "project directory containing the lock file")
+	registryURL := fs.String("registry", os.Getenv("SLANG_REGISTRY"), "registry URL, defaults to env var SLANG_REGISTRY or the registry of the lock file")
+	fs.Usage = func() { pkgUsage(fs) }
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		pkgUsage(fs)
+		return nil
+	}
+
+	project, err := registry.OpenProject(*dir, *registryURL)
+	if err != nil {
+		return err
+	}
+
+	cmd, names := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "install":
+		if len(names) == 0 {
+			return project.Sync()
+		}
+		for _, ref := range names {
+			name, constraint := ref, ""
+			if i := strings.Index(ref, "@"); i != -1 {
+				name, constraint = ref[:i], ref[i+1:]
+			}
+			pkg, err := project.Install(name, constraint)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("installed %s@%s\n", pkg.Name, pkg.Version)
+		}
+	case "update":
+		updated, err := project.Update(names...)
+		for _, pkg := range updated {
+			fmt.Printf("updated %s to %s\n", pkg.Name, pkg.Version)
+		}
+		return err
+	case "remove":
+		if len(names) == 0 {
+			return fmt.Errorf("no packages given")
+		}
+		for _, name := range names {
+			if err := project.Remove(name); err != nil {
+				return err
+			}
+			fmt.Printf("removed %s\n", name)
+		}
+	default:
+		return fmt.Errorf("unknown command %s", cmd)
+	}
+	return nil
+}
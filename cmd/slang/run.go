@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/api"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/registry"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/pkg/utils"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
+)
+
+// keyValues collects repeated KEY=VALUE flags
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	pairs := make([]string, 0, len(kv))
+	for k, v := range kv {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (kv keyValues) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return fmt.Errorf("expected KEY=VALUE, got %s", s)
+	}
+	kv[s[:i]] = s[i+1:]
+	return nil
+}
+
+func runUsage(fs *flag.FlagSet) {
+	fmt.Println("USAGE: slang run [OPTIONS] OPERATOR")
+	fmt.Println("OPERATOR is an operator id, the path to an operator file (YAML or JSON) or to a bundled SLANGFILE.slang.json or .slang.yaml.")
+	fmt.Println("Values for the main in port are read from stdin and values of the main out port written to stdout, one JSON value per line.")
+	fmt.Println("OPTIONS:")
+	fs.PrintDefaults()
+}
+
+// runRun runs an operator resolving its dependencies from the project and library directories
+func runRun(args []string) error {
+	props, gens := keyValues{}, keyValues{}
+
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	dir := fs.String("dir", os.Getenv("SLANG_DIR"), "project directory containing the operators, defaults to env var SLANG_DIR or the current directory")
+	lib := fs.String("lib", os.Getenv("SLANG_LIB"), "library directory, defaults to env var SLANG_LIB")
+	fs.Var(props, "prop", "property value as KEY=VALUE, where VALUE is YAML, may be repeated")
+	fs.Var(gens, "gen", "generic type as KEY=TYPE, where TYPE is a type name or a YAML type definition, may be repeated")
+	slangr := fs.Bool("slangr", false, "run the operator in a slangr process instead of in-process")
+	fs.StringVar(&policyFile, "policy", "", "file restricting the capabilities of operators")
+	fs.StringVar(&encoding, "encoding", api.ENCODING_CBOR, "encoding of values sent to and received from slangr, cbor or json")
+	fs.DurationVar(&drainTimeout, "drain-timeout", 10*time.Second, "time the operator has for processing remaining items after the input has ended")
+	fs.Usage = func() { runUsage(fs) }
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		runUsage(fs)
+		return nil
+	}
+	if *dir == "" {
+		*dir = "."
+	}
+
+	slFile, err := resolveSlangFile(fs.Arg(0), *dir, *lib, api.SlangFileOptions{})
+	if err != nil {
+		return err
+	}
+	if err := applyArgs(slFile, props, gens); err != nil {
+		return err
+	}
+
+	if *slangr {
+		return run(slFile)
+	}
+
+	if policyFile != "" {
+		policy, err := elem.LoadPolicy(policyFile)
+		if err != nil {
+			return err
+		}
+		elem.SetPolicy(policy)
+	}
+	return runInProcess(slFile)
+}
+
+// resolveSlangFile collects the operator and all operators it depends on
+func resolveSlangFile(ref string, dir string, lib string, opts api.SlangFileOptions) (*core.SlangFileDef, error) {
+	if strings.HasSuffix(ref, ".slang.json") || strings.HasSuffix(ref, ".slang.yaml") {
+		return readSlangFile(ref)
+	}
+
+	opId, st, err := resolveOperator(ref, dir, lib)
+	if err != nil {
+		return nil, err
+	}
+	return api.CreateSlangFile(opId, opts, *st)
+}
+
+// resolveOperator returns the id of the operator referenced by id or file path and a storage for loading it
+// together with its dependencies
+func resolveOperator(ref string, dir string, lib string) (uuid.UUID, *storage.Storage, error) {
+	st := storage.NewStorage(nil)
+	opId, err := uuid.Parse(ref)
+	if err != nil {
+		if !utils.FileExists(ref) {
+			return uuid.Nil, nil, fmt.Errorf("%s is neither an operator id nor an operator file", ref)
+		}
+		opDef, err := readOperatorFile(ref)
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+		opId = uuid.MustParse(opDef.Id)
+		// The file and operators next to it take precedence over the project directory
+		st.AddLoader(storage.NewMemoryLoader(*opDef))
+		st.AddLoader(storage.NewFileSystem(filepath.Dir(ref)))
+	}
+	st.AddLoader(storage.NewFileSystem(dir))
+	st.AddLoader(storage.NewPackageLoader(filepath.Join(dir, registry.PACKAGES_DIR)))
+	if lib != "" {
+		st.AddLoader(storage.NewFileSystem(filepath.Join(lib, "slang")))
+	}
+	return opId, st, nil
+}
+
+func readOperatorFile(path string) (*core.OperatorDef, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read operator file: %s", path)
+	}
+
+	var opDef core.OperatorDef
+	if utils.IsYAML(path) {
+		opDef, err = core.ParseYAMLOperatorDef(string(b))
+	} else if utils.IsJSON(path) {
+		opDef, err = core.ParseJSONOperatorDef(string(b))
+	} else {
+		err = fmt.Errorf("unsupported file ending: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := opDef.Validate(); err != nil {
+		return nil, err
+	}
+	return &opDef, nil
+}
+
+// applyArgs sets the properties and generics given as flags, overriding those of the SlangFileDef
+func applyArgs(slFile *core.SlangFileDef, props keyValues, gens keyValues) error {
+	if len(props) != 0 && slFile.Args.Properties == nil {
+		slFile.Args.Properties = make(core.Properties)
+	}
+	for k, v := range props {
+		var val interface{}
+		if err := yaml.Unmarshal([]byte(v), &val); err != nil {
+			return fmt.Errorf("invalid value for property %s: %s", k, err)
+		}
+		slFile.Args.Properties[k] = core.CleanValue(val)
+	}
+	if len(gens) != 0 && slFile.Args.Generics == nil {
+		slFile.Args.Generics = make(core.Generics)
+	}
+	for k, v := range gens {
+		typeDef, err := parseGeneric(v)
+		if err != nil {
+			return fmt.Errorf("invalid type for generic %s: %s", k, err)
+		}
+		slFile.Args.Generics[k] = typeDef
+	}
+	return nil
+}
+
+func parseGeneric(s string) (*core.TypeDef, error) {
+	typeDef := &core.TypeDef{}
+	if err := yaml.Unmarshal([]byte(s), typeDef); err != nil {
+		typeDef = &core.TypeDef{Type: strings.TrimSpace(s)}
+	}
+	if err := typeDef.Validate(); err != nil {
+		return nil, err
+	}
+	return typeDef, nil
+}
+
+// runInProcess runs the operator in this process, pushing stdin into its main in port and writing its main out port
+// to stdout. Once the input has ended the remaining items are processed before returning.
+func runInProcess(slFile *core.SlangFileDef) error {
+	exe, err := api.NewExecutable(slFile)
+	if err != nil {
+		return err
+	}
+	exe.DrainTimeout = drainTimeout
+
+	exe.Start()
+	defer exe.Stop()
+
+	errs := make(chan error, 1)
+	go func() { errs <- exe.ServeStdio(os.Stdin, os.Stdout) }()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-quit:
+		return nil
+	}
+}
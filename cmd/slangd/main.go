@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"github.com/Bitspark/slang/pkg/storage"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os/user"
 	"path/filepath"
 	"strings"
@@ -17,6 +19,8 @@ import (
 
 	"github.com/Bitspark/browser"
 	"github.com/Bitspark/slang/pkg/daemon"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/registry"
 	"github.com/Bitspark/slang/pkg/utils"
 )
 
@@ -45,12 +49,48 @@ func EnsureEnvironVar(key string, dfltVal string) string {
 
 var onlyDaemon bool
 var skipChecks bool
+var disableOperators string
+var bindAddr string
+var apiKey string
+var usersFile string
+var anonymousPerms string
+var allowedOrigins string
+var history bool
+var storageURL string
+var remotes string
+var plugins string
+var maxBundleSize int64
 
 func main() {
 	flag.BoolVar(&onlyDaemon, "only-daemon", false, "Don't automatically open UI")
 	flag.BoolVar(&skipChecks, "skip-checks", false, "Skip checking and updating UI and Lib")
+	flag.StringVar(&disableOperators, "disable-operators", "", "Comma-separated names or ids of builtin operators which must not be run, e.g. \"shell execute\"")
+	flag.StringVar(&bindAddr, "bind", "localhost", "Address the daemon listens on, use 0.0.0.0 to listen on all interfaces")
+	flag.StringVar(&apiKey, "api-key", "", "API key granting all permissions, defaults to env var SLANG_API_KEY")
+	flag.StringVar(&usersFile, "users", "", "YAML file with users and their permissions, defaults to users.yaml in SLANG_PATH")
+	flag.StringVar(&anonymousPerms, "anonymous", "", "Comma-separated permissions of requests while authentication is disabled, e.g. \"read,run,store\", defaults to all permissions on loopback addresses and \"read\" otherwise")
+	flag.StringVar(&allowedOrigins, "allow-origin", "", "Comma-separated origins which may access the daemon from a browser besides its own, e.g. \"http://localhost:4200\"")
+	flag.BoolVar(&history, "history", false, "Commit stored operators into a git repository in SLANG_DIR to keep their history")
+	flag.StringVar(&storageURL, "storage", "", "Store operators in a database instead of SLANG_DIR, e.g. sqlite3:operators.db or mysql:user:pass@tcp(host)/db")
+	flag.StringVar(&remotes, "remote", "", "Comma-separated URLs of daemons to load operators from, use ?token=... for daemons requiring authentication")
+	flag.StringVar(&plugins, "plugins", "", "Comma-separated Go plugins or directories containing them, which register custom elementary operators")
+	flag.Int64Var(&maxBundleSize, "max-bundle-size", storage.MaxBundleSize>>20, "Maximum size of imported bundles in MiB")
 	flag.Parse()
 
+	storage.MaxBundleSize = maxBundleSize << 20
+
+	loadPlugins(plugins)
+
+	for _, name := range strings.Split(disableOperators, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if err := elem.Disable(name); err != nil {
+			log.Fatalf("cannot disable operator %s: %s", name, err)
+		}
+		log.Printf("Builtin operator %s is disabled", name)
+	}
+
 	buildTime, _ := strconv.ParseInt(BuildTime, 10, 64)
 	if buildTime != 0 {
 		log.Printf("Starting slangd %s built %s...\n", Version, time.Unix(buildTime, 0).Format(time.RFC3339))
@@ -62,6 +102,7 @@ func main() {
 	daemon.SlangVersion = Version
 
 	envPaths := initEnvironPaths()
+	envPaths.loadPolicy()
 
 	if !skipChecks {
 		envPaths.loadLocalComponents()
@@ -73,9 +114,17 @@ func main() {
 	}
 
 	st := storage.
-		NewStorage(storage.NewFileSystem(envPaths.SLANG_DIR)).
-		AddLoader(storage.NewFileSystem(dirSlib))
-	srv := daemon.New(*st, "localhost", PORT)
+		NewStorage(envPaths.projectStorage()).
+		AddLoader(storage.NewFileSystem(dirSlib)).
+		AddLoader(storage.NewPackageLoader(filepath.Join(envPaths.SLANG_DIR, registry.PACKAGES_DIR)))
+	for _, remote := range strings.Split(remotes, ",") {
+		if remote = strings.TrimSpace(remote); remote != "" {
+			st.AddLoader(remoteLoader(remote))
+			log.Printf("Loading operators from %s", remote)
+		}
+	}
+	srv := daemon.New(*st, bindAddr, PORT)
+	envPaths.loadAuthentication(srv)
 	envPaths.loadDaemonServices(srv)
 	envPaths.startDaemonServer(srv)
 }
@@ -108,6 +157,127 @@ func initEnvironPaths() *EnvironPaths {
 	return e
 }
 
+// projectStorage stores operators in SLANG_DIR and picks up changes made to its files while the daemon is running
+func (e *EnvironPaths) projectStorage() storage.LoaderDumper {
+	if storageURL != "" {
+		i := strings.Index(storageURL, ":")
+		if i == -1 {
+			log.Fatalf("invalid storage %s, expected DRIVER:DSN", storageURL)
+		}
+		sqlStorage, err := storage.OpenSQLStorage(storageURL[:i], storageURL[i+1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Storing operators in %s database", storageURL[:i])
+		return sqlStorage
+	}
+	if !history {
+		fs := storage.NewFileSystem(e.SLANG_DIR)
+		watchFileSystem(fs)
+		return fs
+	}
+	gitFs, err := storage.NewGitFileSystem(e.SLANG_DIR)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Keeping history of operators in %s", e.SLANG_DIR)
+	watchFileSystem(gitFs.FileSystem)
+	return gitFs
+}
+
+// remoteLoader creates a loader for the daemon at the URL, a token query parameter is used for authentication
+func remoteLoader(remote string) *storage.HTTPLoader {
+	u, err := url.Parse(remote)
+	if err != nil {
+		log.Fatalf("invalid remote %s: %s", remote, err)
+	}
+	token := u.Query().Get("token")
+	u.RawQuery = ""
+	return storage.NewHTTPLoader(u.String(), token)
+}
+
+func watchFileSystem(fs *storage.FileSystem) {
+	if err := fs.Watch(); err != nil {
+		log.Printf("Cannot watch for changes of operators: %s", err)
+	}
+}
+
+// loadPolicy restricts the capabilities of operators in case the project directory contains a policy file
+func (e *EnvironPaths) loadPolicy() {
+	policyFile := filepath.Join(e.SLANG_DIR, "policy.yaml")
+	if !utils.FileExists(policyFile) {
+		return
+	}
+	policy, err := elem.LoadPolicy(policyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	elem.SetPolicy(policy)
+	log.Printf("Operator capabilities restricted by %s", policyFile)
+}
+
+// loadAuthentication enables authentication in case an API key or a user file is present, otherwise it grants the
+// configured permissions to anonymous requests. It also sets the origins allowed to access the daemon.
+func (e *EnvironPaths) loadAuthentication(srv *daemon.Server) {
+	if apiKey == "" {
+		apiKey = os.Getenv("SLANG_API_KEY")
+	}
+	if apiKey != "" {
+		srv.Auth.AddAPIKey("api-key", apiKey)
+	}
+
+	if usersFile == "" {
+		if f := filepath.Join(e.SLANG_PATH, "users.yaml"); utils.FileExists(f) {
+			usersFile = f
+		}
+	}
+	if usersFile != "" {
+		if err := srv.Auth.LoadUserFile(usersFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, origin := range strings.Split(allowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			srv.AllowedOrigins = append(srv.AllowedOrigins, origin)
+		}
+	}
+
+	if srv.Auth.Enabled() {
+		log.Println("Authentication enabled")
+		return
+	}
+	if anonymousPerms == "" {
+		// The bundled UI cannot send tokens, it is fully usable as long as the daemon is only reachable locally
+		anonymousPerms = daemon.PERM_READ
+		if isLoopback(bindAddr) {
+			anonymousPerms = strings.Join([]string{daemon.PERM_READ, daemon.PERM_RUN, daemon.PERM_STORE}, ",")
+		}
+	}
+	var perms []string
+	for _, perm := range strings.Split(anonymousPerms, ",") {
+		if perm = strings.TrimSpace(perm); perm != "" {
+			perms = append(perms, perm)
+		}
+	}
+	if err := srv.Auth.SetAnonymousPermissions(perms); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Authentication disabled, requests are granted permissions %s", strings.Join(perms, ","))
+	if !isLoopback(bindAddr) {
+		log.Printf("WARNING: listening on %s without authentication, set an API key or a user file", bindAddr)
+	}
+}
+
+// isLoopback tells whether the address is only reachable from this machine
+func isLoopback(addr string) bool {
+	if addr == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
+
 func checkNewestVersion() {
 	isNewest, newestVer, err := daemon.IsNewestSlangVersion(Version)
 	if err != nil {
@@ -159,11 +329,16 @@ func (e *EnvironPaths) loadDaemonServices(srv *daemon.Server) {
 	srv.AddService("/operator", daemon.DefinitionService)
 	srv.AddService("/run", daemon.RunnerService)
 	srv.AddService("/share", daemon.SharingService)
+	srv.AddService("/history", daemon.HistoryService)
 	srv.AddOperatorProxy("/instance")
 }
 
 func (e *EnvironPaths) startDaemonServer(srv *daemon.Server) {
-	url := fmt.Sprintf("http://%s:%d/", srv.Host, srv.Port)
+	host := srv.Host
+	if host == "" || host == "0.0.0.0" {
+		host = "localhost"
+	}
+	url := fmt.Sprintf("http://%s:%d/", host, srv.Port)
 	errors := make(chan error)
 	go informUser(url, errors)
 	errors <- srv.Run()
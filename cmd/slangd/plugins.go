@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"plugin"
+	"strings"
+
+	"github.com/Bitspark/slang/pkg/elem"
+)
+
+// loadPlugins opens the Go plugins given as comma-separated files or directories containing .so files
+func loadPlugins(paths string) {
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+
+		files := []string{path}
+		if info, err := os.Stat(path); err != nil {
+			log.Fatalf("cannot load plugins from %s: %s", path, err)
+		} else if info.IsDir() {
+			files, _ = filepath.Glob(filepath.Join(path, "*.so"))
+		}
+
+		for _, file := range files {
+			before := len(elem.GetBuiltinIds())
+			if err := loadPlugin(file); err != nil {
+				log.Fatalf("cannot load plugin %s: %s", file, err)
+			}
+			log.Printf("Plugin %s registered %d operators", file, len(elem.GetBuiltinIds())-before)
+		}
+	}
+}
+
+// loadPlugin opens a plugin which has to export a function Register() error registering its operators by calling
+// elem.Register. It has to be built with the same version of Go and the slang packages as slangd. The operators are
+// restricted by the policy according to the capabilities declared with elem.RegisterCapabilities.
+func loadPlugin(file string) error {
+	p, err := plugin.Open(file)
+	if err != nil {
+		return err
+	}
+	sym, err := p.Lookup("Register")
+	if err != nil {
+		return err
+	}
+	register, ok := sym.(func() error)
+	if !ok {
+		return fmt.Errorf("Register must be a func() error, is %T", sym)
+	}
+	return register()
+}
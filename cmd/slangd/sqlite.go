@@ -0,0 +1,7 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+// SQLite requires cgo, builds without cgo can still use MySQL as storage
+import _ "github.com/mattn/go-sqlite3"
@@ -1,13 +1,13 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/Bitspark/go-funk"
 	"github.com/Bitspark/slang/pkg/api"
 	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
 	"github.com/Bitspark/slang/pkg/storage"
 	"github.com/google/uuid"
 	"io"
@@ -17,74 +17,64 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 )
 
-/*** (Loader *******/
-type runnerLoader struct {
-	blueprintbyId map[string]core.OperatorDef
-}
-
-func newRunnerStorage(blueprints []core.OperatorDef) *storage.Storage {
-	m := make(map[string]core.OperatorDef)
-
-	for _, bp := range blueprints {
-		m[bp.Id] = bp
-	}
-
-	return storage.NewStorage(nil).AddLoader(&runnerLoader{m})
-}
-
-func (l *runnerLoader) Has(opId uuid.UUID) bool {
-	_, ok := l.blueprintbyId[opId.String()]
-	return ok
-}
-
-func (l *runnerLoader) List() ([]uuid.UUID, error) {
-	var uuidList []uuid.UUID
-
-	for _, idOrName := range funk.Keys(l.blueprintbyId).([]string) {
-		if id, err := uuid.Parse(idOrName); err == nil {
-			uuidList = append(uuidList, id)
-		}
-	}
-
-	return uuidList, nil
-}
-
-func (l *runnerLoader) Load(opId uuid.UUID) (*core.OperatorDef, error) {
-	if opDef, ok := l.blueprintbyId[opId.String()]; ok {
-		return &opDef, nil
-	}
-	return nil, fmt.Errorf("unknown operator")
-}
-
 var mgntAddr string
 var aggrIn bool
 var aggrOut bool
+var policyFile string
 
 func main() {
 	flag.StringVar(&mgntAddr, "mgnt-addr", "", "REQUIRED")
 	flag.BoolVar(&aggrIn, "aggr-in", false, "")
 	flag.BoolVar(&aggrOut, "aggr-out", false, "")
+	flag.StringVar(&policyFile, "policy", "", "file restricting the capabilities of operators")
 	flag.Parse()
 
 	if mgntAddr == "" {
 		log.Fatal("address for receiving management commands")
 	}
 
+	if policyFile != "" {
+		policy, err := elem.LoadPolicy(policyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		elem.SetPolicy(policy)
+	}
+
 	if err := run(); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// maxErrors limits the number of recent errors reported by the status command
+const maxErrors = 10
+
 type wrkCmds struct {
 	op    *core.Operator
 	sp    *SocketPort
 	ready chan bool
+	quit  chan os.Signal
+
+	mutex    *sync.Mutex
+	state    string
+	conns    map[net.Conn]bool
+	itemsIn  int
+	itemsOut int
+	errors   []string
+	inFlight *api.InFlight
 }
 
 func newWrkCmds() api.Commands {
-	return &wrkCmds{nil, nil, make(chan bool, 0)}
+	return &wrkCmds{
+		ready: make(chan bool, 1),
+		quit:  make(chan os.Signal, 1),
+		mutex: &sync.Mutex{},
+		state: api.WORKER_IDLE,
+		conns: make(map[net.Conn]bool),
+	}
 }
 
 func (w *wrkCmds) Hello() (string, error) {
@@ -110,51 +100,198 @@ func (w *wrkCmds) Init(a string) (string, error) {
 		return "", err
 	}
 
-	w.op = op
-
 	sp, err := newSocketPort(op, aggrIn, aggrOut)
 
 	if err != nil {
 		return "", err
 	}
 
-	w.sp = sp
-
 	op.Main().Out().Bufferize()
+
+	ports := make([]*core.Port, 0, len(sp.pmap))
+	for _, p := range sp.pmap {
+		ports = append(ports, p)
+	}
+
+	w.op = op
+	w.sp = sp
+	w.inFlight = api.NewInFlight(ports...)
 	w.ready <- true
 
 	return w.PrtCfg()
 }
 
 func (w *wrkCmds) PrtCfg() (string, error) {
-	if w.op == nil {
+	if w.sp == nil {
 		return "", fmt.Errorf("runner is not initialized: provide valid operator")
 	}
 
-	// todo timeout to prevent infinite loop
-	for w.sp == nil {
+	return w.sp.String(), nil
+}
+
+func (w *wrkCmds) Status() (string, error) {
+	buffered, inFlight := 0, 0
+	if w.op != nil {
+		buffered = w.op.Buffered()
+		inFlight = w.inFlight.Items()
+	}
+
+	w.mutex.Lock()
+	status := api.WorkerStatus{
+		State:    w.state,
+		Running:  w.state == api.WORKER_RUNNING || w.state == api.WORKER_DRAINING,
+		ItemsIn:  w.itemsIn,
+		ItemsOut: w.itemsOut,
+		InFlight: inFlight,
+		Buffered: buffered,
+		Errors:   append([]string{}, w.errors...),
 	}
+	w.mutex.Unlock()
 
-	return w.sp.String(), nil
+	b, err := json.Marshal(status)
+	return string(b), err
+}
+
+// Drain stops accepting input and waits until every item which has entered the worker has left it
+func (w *wrkCmds) Drain(timeout time.Duration) (string, error) {
+	w.mutex.Lock()
+	if w.state == api.WORKER_DRAINED {
+		w.mutex.Unlock()
+		return w.Status()
+	}
+	if w.state != api.WORKER_RUNNING && w.state != api.WORKER_DRAINING {
+		state := w.state
+		w.mutex.Unlock()
+		return "", fmt.Errorf("cannot drain %s worker", state)
+	}
+	w.state = api.WORKER_DRAINING
+	w.closeConns(core.DIRECTION_IN)
+	w.mutex.Unlock()
+
+	w.sp.Close(core.DIRECTION_IN)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if w.inFlight.Items() <= 0 && w.op.Buffered() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("%d items left after %s", w.op.Buffered(), timeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	w.mutex.Lock()
+	if w.state == api.WORKER_DRAINING {
+		w.state = api.WORKER_DRAINED
+	}
+	w.mutex.Unlock()
+	return w.Status()
+}
+
+func (w *wrkCmds) Stop() (string, error) {
+	w.mutex.Lock()
+	started := w.state != api.WORKER_IDLE && w.state != api.WORKER_STOPPED
+	w.state = api.WORKER_STOPPED
+	w.closeConns(core.DIRECTION_IN)
+	w.closeConns(core.DIRECTION_OUT)
+	w.mutex.Unlock()
+
+	if w.sp != nil {
+		w.sp.Close(core.DIRECTION_IN)
+		w.sp.Close(core.DIRECTION_OUT)
+	}
+	if started {
+		w.op.Stop()
+	}
+	return w.Status()
 }
 
 func (w *wrkCmds) Action() error {
-	<-w.ready
+	// Handle SIGTERM (CTRL-C)
+	signal.Notify(w.quit, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case <-w.ready:
+	case <-w.quit:
+		return nil
+	}
 
 	op := w.op
 	sp := w.sp
 
-	op.Start()
+	w.mutex.Lock()
+	w.state = api.WORKER_RUNNING
+	w.mutex.Unlock()
 
-	go sp.OnInput(hndlInput)
-	go sp.OnOutput(hndlOutput)
+	op.Start(context.Background())
 
-	// Handle SIGTERM (CTRL-C)
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
-	op.Stopped()
-	return nil
+	go sp.OnInput(w.hndlInput)
+	go sp.OnOutput(w.hndlOutput)
+
+	<-w.quit
+	_, err := w.Stop()
+	return err
+}
+
+func (w *wrkCmds) accepting() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.state == api.WORKER_RUNNING
+}
+
+func (w *wrkCmds) stopped() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.state == api.WORKER_STOPPED
+}
+
+// track registers a connection to a port and returns false in case the worker does not accept connections anymore
+func (w *wrkCmds) track(conn net.Conn, dir int) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.state == api.WORKER_STOPPED || (dir == core.DIRECTION_IN && w.state != api.WORKER_RUNNING) {
+		return false
+	}
+	w.conns[conn] = dir == core.DIRECTION_IN
+	return true
+}
+
+func (w *wrkCmds) untrack(conn net.Conn) {
+	w.mutex.Lock()
+	delete(w.conns, conn)
+	w.mutex.Unlock()
+}
+
+// closeConns closes all connections to in or out ports, the mutex has to be held
+func (w *wrkCmds) closeConns(dir int) {
+	for conn, in := range w.conns {
+		if in == (dir == core.DIRECTION_IN) {
+			conn.Close()
+			delete(w.conns, conn)
+		}
+	}
+}
+
+func (w *wrkCmds) count(p *core.Port, v interface{}, in bool) {
+	w.mutex.Lock()
+	if in {
+		w.itemsIn++
+	} else {
+		w.itemsOut++
+	}
+	w.mutex.Unlock()
+	w.inFlight.Count(p, v)
+}
+
+func (w *wrkCmds) fail(err error) {
+	log.Print(err)
+	w.mutex.Lock()
+	w.errors = append(w.errors, err.Error())
+	if len(w.errors) > maxErrors {
+		w.errors = w.errors[len(w.errors)-maxErrors:]
+	}
+	w.mutex.Unlock()
 }
 
 func run() error {
@@ -175,7 +312,7 @@ func buildOperator(d core.SlangFileDef) (*core.Operator, error) {
 		}
 	}
 
-	stor := newRunnerStorage(d.Blueprints)
+	stor := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(d.Blueprints...))
 
 	bpId, _ := uuid.Parse(d.Main)
 	return api.BuildAndCompile(bpId, d.Args.Generics, d.Args.Properties, *stor)
@@ -185,6 +322,12 @@ func eof(e error) bool {
 	return e == io.EOF
 }
 
+// closed reports whether the error has been caused by a closed listener rather than a failing connection
+func closed(e error) bool {
+	ne, ok := e.(net.Error)
+	return !ok || !ne.Temporary()
+}
+
 // TODO see api.PortConnHandler (client) of SocketPort
 type SocketPort struct {
 	op    *core.Operator
@@ -287,16 +430,18 @@ func (sp *SocketPort) OnInput(hndl func(op *core.Operator, p *core.Port, conn ne
 			continue
 		}
 		ln := sp.lnmap[a]
-		op := sp.op
 
 		go func(p *core.Port) {
 			var wg sync.WaitGroup
 			wg.Add(1)
 
-			for !op.Stopped() {
+			for {
 				conn, err := ln.Accept()
 
 				if err != nil {
+					if closed(err) {
+						return
+					}
 					continue
 				}
 
@@ -316,13 +461,15 @@ func (sp *SocketPort) OnOutput(hndl func(op *core.Operator, p *core.Port, conn n
 			continue
 		}
 		ln := sp.lnmap[a]
-		op := sp.op
 
 		go func(p *core.Port) {
-			for !op.Stopped() {
+			for {
 				conn, err := ln.Accept()
 
 				if err != nil {
+					if closed(err) {
+						return
+					}
 					continue
 				}
 
@@ -332,6 +479,15 @@ func (sp *SocketPort) OnOutput(hndl func(op *core.Operator, p *core.Port, conn n
 	}
 }
 
+// Close closes the listeners of all in or out ports
+func (sp *SocketPort) Close(dir int) {
+	for a, p := range sp.pmap {
+		if p.Direction() == dir {
+			sp.lnmap[a].Close()
+		}
+	}
+}
+
 func (sp *SocketPort) String() string {
 	paddr := make(map[string]string)
 	for a, p := range sp.pmap {
@@ -342,53 +498,72 @@ func (sp *SocketPort) String() string {
 	return string(j)
 }
 
-func hndlInput(op *core.Operator, p *core.Port, conn net.Conn, wg *sync.WaitGroup) {
+func (w *wrkCmds) hndlInput(op *core.Operator, p *core.Port, conn net.Conn, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer conn.Close()
 
-	rdconn := bufio.NewReader(conn)
+	if !w.track(conn, core.DIRECTION_IN) {
+		return
+	}
+	defer w.untrack(conn)
 
-	for !op.Stopped() {
-		m, err := api.Rdbuf(rdconn)
+	wc := api.NewWireConn(conn)
+	if err := wc.Accept(op.Id().String()); err != nil {
+		w.fail(fmt.Errorf("handshake on %s failed: %s", p.StringifyComplete(), err))
+		return
+	}
 
-		if eof(err) {
-			break
-		}
+	for w.accepting() {
+		idat, err := wc.ReadValue()
 
-		if len(m) == 0 {
-			p.Push(nil)
+		if _, ok := err.(*api.DecodeError); ok {
+			// The sender has been informed and may continue
+			w.fail(err)
 			continue
 		}
-
-		var idat interface{}
-		err = json.Unmarshal([]byte(m), &idat)
-
 		if err != nil {
-			continue
+			if !eof(err) && w.accepting() {
+				w.fail(fmt.Errorf("cannot read from %s: %s", p.StringifyComplete(), err))
+			}
+			break
 		}
 
+		// Items are counted before they enter, so that they are in flight until they have left
+		idat = api.BindMarker(p, idat)
+		w.count(p, idat, true)
 		p.Push(idat)
 	}
-
-	wg.Done()
-
 }
 
-func hndlOutput(op *core.Operator, p *core.Port, conn net.Conn) {
-
-	wrconn := bufio.NewWriter(conn)
+func (w *wrkCmds) hndlOutput(op *core.Operator, p *core.Port, conn net.Conn) {
 	defer conn.Close()
-	defer wrconn.Flush()
 
-	for !op.Stopped() {
-		odat := p.Pull()
+	if !w.track(conn, core.DIRECTION_OUT) {
+		return
+	}
+	defer w.untrack(conn)
 
-		m, err := json.Marshal(odat)
+	wc := api.NewWireConn(conn)
+	if err := wc.Accept(op.Id().String()); err != nil {
+		w.fail(fmt.Errorf("handshake on %s failed: %s", p.StringifyComplete(), err))
+		return
+	}
 
-		if err != nil {
-			continue
+	for !w.stopped() {
+		odat := p.Pull()
+		if w.stopped() {
+			break
 		}
 
-		if err = api.Wrbuf(wrconn, string(m)); err != nil {
-			break
+		if err := wc.WriteValue(odat); err != nil {
+			// The item is lost, so it is not in flight anymore
+			w.inFlight.Count(p, odat)
+			w.fail(fmt.Errorf("cannot write %v to %s: %s", odat, p.StringifyComplete(), err))
+			if _, ok := err.(net.Error); ok {
+				break
+			}
+			continue
 		}
+		w.count(p, odat, false)
 	}
 }
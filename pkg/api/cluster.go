@@ -0,0 +1,726 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// COORDINATOR is the part number of the coordinator, which holds the main ports of the partitioned operator
+const COORDINATOR = -1
+
+var keyInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// Endpoint is a primitive port of a part, referenced as in connections of its operator
+type Endpoint struct {
+	Part int    `json:"part"`
+	Ref  string `json:"ref"`
+}
+
+// Bridge forwards the items of a port of one part to ports of other parts
+type Bridge struct {
+	From Endpoint   `json:"from"`
+	To   []Endpoint `json:"to"`
+}
+
+// Partition is a compiled operator split into parts which run in separate workers. Main holds the services of the
+// original operator and connections between its own ports, its ports are served by the coordinator.
+type Partition struct {
+	Main    core.OperatorDef    `json:"main"`
+	Parts   []core.SlangFileDef `json:"parts"`
+	Bridges []*Bridge           `json:"bridges"`
+}
+
+// entry is a port of a part's main service replacing cut connections. Items of streams are cut one stream at a
+// time, so an entry is a stream of depth streams containing a single primitive or a map of primitives.
+type entry struct {
+	key    string
+	depth  int
+	leaves map[string]core.TypeDef
+}
+
+func (e *entry) ref(leaf string, in bool) string {
+	path := e.key + strings.Repeat(".~", e.depth)
+	if leaf != "" {
+		path += "." + leaf
+	}
+	if in {
+		return path + "("
+	}
+	return ")" + path
+}
+
+func (e *entry) typeDef() core.TypeDef {
+	var def core.TypeDef
+	if t, ok := e.leaves[""]; ok {
+		def = t
+	} else {
+		def = core.TypeDef{Type: "map", Map: make(map[string]*core.TypeDef)}
+		for leaf, t := range e.leaves {
+			t := t
+			def.Map[leaf] = &t
+		}
+	}
+	for i := 0; i < e.depth; i++ {
+		sub := def
+		def = core.TypeDef{Type: "stream", Stream: &sub}
+	}
+	return def
+}
+
+type partBuilder struct {
+	def  core.OperatorDef
+	ins  []*entry
+	outs []*entry
+	// entries by the stream they are cut from
+	inBy  map[string]*entry
+	outBy map[string]*entry
+	// definitions of external operators used by the part
+	blueprints []core.OperatorDef
+}
+
+// entry returns the entry for the cut stream, named after the operator part of its reference
+func (pb *partBuilder) entry(entries *[]*entry, by map[string]*entry, stream string, opPart string, depth int) *entry {
+	if e, ok := by[stream]; ok {
+		return e
+	}
+
+	key := strings.Trim(keyInvalidChars.ReplaceAllString(opPart, "_"), "_")
+	if key == "" {
+		key = "main"
+	}
+	taken := func(k string) bool {
+		for _, e := range *entries {
+			if e.key == k {
+				return true
+			}
+		}
+		return false
+	}
+	k := key
+	for i := 2; taken(k); i++ {
+		k = fmt.Sprintf("%s%d", key, i)
+	}
+
+	e := &entry{k, depth, make(map[string]core.TypeDef)}
+	*entries = append(*entries, e)
+	by[stream] = e
+	return e
+}
+
+func (pb *partBuilder) service(entries []*entry) core.TypeDef {
+	if len(entries) == 0 {
+		return core.TypeDef{Type: "trigger"}
+	}
+	def := core.TypeDef{Type: "map", Map: make(map[string]*core.TypeDef)}
+	for _, e := range entries {
+		t := e.typeDef()
+		def.Map[e.key] = &t
+	}
+	return def
+}
+
+// splitLeaf splits a reference to a primitive port into the reference to the innermost stream containing it and the
+// path below that stream, joined by underscores. The number of streams containing the port is returned as depth.
+func splitLeaf(ref string) (string, string, string, int) {
+	opPart, path, in := "", "", false
+	if i := strings.Index(ref, "("); i != -1 {
+		path, opPart, in = ref[:i], ref[i+1:], true
+	} else if i := strings.Index(ref, ")"); i != -1 {
+		opPart, path = ref[:i], ref[i+1:]
+	}
+
+	prefix, rest := "", path
+	if i := strings.LastIndex(path, "~"); i != -1 {
+		prefix, rest = path[:i+1], strings.TrimPrefix(path[i+1:], ".")
+	}
+	root := ")" + prefix
+	if in {
+		root = prefix + "("
+	}
+	return opPart, opPart + root, strings.Replace(rest, ".", "_", -1), strings.Count(prefix, "~")
+}
+
+// PartitionOperator compiles the operator and distributes its elementary instances among n parts. Instances can be
+// assigned to parts explicitly, the remaining ones are distributed round robin. Connections between parts are cut
+// and replaced by bridges between ports of the parts' main services. Ports nested in more than one stream cannot be
+// cut, instances connected that way have to be assigned to the same part.
+func PartitionOperator(opId uuid.UUID, gens core.Generics, props core.Properties, st storage.Storage, n int, assign map[string]int) (*Partition, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("cannot partition into %d parts", n)
+	}
+
+	op, err := Build(opId, gens, props, st)
+	if err != nil {
+		return nil, err
+	}
+	op.Compile()
+	flatDef, err := op.Define()
+	if err != nil {
+		return nil, err
+	}
+	flatOp, err := CreateAndConnectOperator("", flatDef, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assign instances to parts, dropping empty parts
+	names := make([]string, 0, len(flatDef.InstanceDefs))
+	for _, insDef := range flatDef.InstanceDefs {
+		names = append(names, insDef.Name)
+	}
+	sort.Strings(names)
+
+	partOf := make(map[string]int)
+	next := 0
+	for _, name := range names {
+		if p, ok := assign[name]; ok {
+			if p < 0 || p >= n {
+				return nil, fmt.Errorf("instance %s assigned to part %d of %d", name, p, n)
+			}
+			partOf[name] = p
+			continue
+		}
+		partOf[name] = next % n
+		next++
+	}
+	used := make(map[int]int)
+	usedParts := []int{}
+	for _, name := range names {
+		if _, ok := used[partOf[name]]; !ok {
+			used[partOf[name]] = 0
+			usedParts = append(usedParts, partOf[name])
+		}
+	}
+	sort.Ints(usedParts)
+	for i, p := range usedParts {
+		used[p] = i
+	}
+
+	parts := make([]*partBuilder, len(usedParts))
+	for i := range parts {
+		parts[i] = &partBuilder{
+			def: core.OperatorDef{
+				Id:          uuid.New().String(),
+				Meta:        core.OperatorMetaDef{Name: fmt.Sprintf("%s part %d", flatDef.Meta.Name, i+1)},
+				Connections: make(map[string][]string),
+			},
+			inBy:  make(map[string]*entry),
+			outBy: make(map[string]*entry),
+		}
+	}
+	for _, insDef := range flatDef.InstanceDefs {
+		// Elementary operators are resolved by the worker, just as when the part is sent to it
+		insCpy := insDef.Copy(false)
+		insCpy.OperatorDef = core.OperatorDef{}
+		pb := parts[used[partOf[insDef.Name]]]
+		if insDef.OperatorDef.IsExternal() {
+			// External operators are unknown to the worker and shipped along with the part. Their definitions have
+			// been specified for the instance, so each instance gets its own.
+			extDef := insDef.OperatorDef.Copy(false)
+			extDef.Id = uuid.New().String()
+			insCpy.Operator = extDef.Id
+			pb.blueprints = append(pb.blueprints, extDef)
+		}
+		pb.def.InstanceDefs = append(pb.def.InstanceDefs, &insCpy)
+	}
+
+	part := func(ref string) int {
+		if ins := refInstance(ref); ins != "" {
+			return used[partOf[ins]]
+		}
+		return COORDINATOR
+	}
+
+	mainDef := core.OperatorDef{
+		Id:          flatDef.Id,
+		Meta:        flatDef.Meta,
+		ServiceDefs: flatDef.ServiceDefs,
+		Connections: make(map[string][]string),
+	}
+	partition := &Partition{Main: mainDef}
+
+	srcs := make([]string, 0, len(flatDef.Connections))
+	for src := range flatDef.Connections {
+		srcs = append(srcs, src)
+	}
+	sort.Strings(srcs)
+
+	for _, src := range srcs {
+		srcPart := part(src)
+		var bridge *Bridge
+
+		for _, dst := range flatDef.Connections[src] {
+			dstPart := part(dst)
+			if srcPart == dstPart {
+				conns := partition.Main.Connections
+				if srcPart != COORDINATOR {
+					conns = parts[srcPart].def.Connections
+				}
+				conns[src] = append(conns[src], dst)
+				continue
+			}
+
+			opPart, stream, leaf, depth := splitLeaf(src)
+			if depth > 1 {
+				return nil, fmt.Errorf("cannot cut connection from %s to %s nested in several streams", src, dst)
+			}
+			port, err := core.ParsePortReference(src, flatOp)
+			if err != nil {
+				return nil, err
+			}
+			leafDef := port.Define()
+
+			if bridge == nil {
+				bridge = &Bridge{From: Endpoint{srcPart, src}}
+				if srcPart != COORDINATOR {
+					pb := parts[srcPart]
+					e := pb.entry(&pb.outs, pb.outBy, stream, opPart, depth)
+					e.leaves[leaf] = leafDef
+					bridge.From.Ref = e.ref(leaf, false)
+					pb.def.Connections[src] = append(pb.def.Connections[src], bridge.From.Ref)
+				}
+				partition.Bridges = append(partition.Bridges, bridge)
+			}
+
+			to := Endpoint{dstPart, dst}
+			if dstPart != COORDINATOR {
+				// Leaves of the same stream share an entry to keep their items together
+				pb := parts[dstPart]
+				e := pb.entry(&pb.ins, pb.inBy, fmt.Sprintf("%d %s", srcPart, stream), opPart, depth)
+				e.leaves[leaf] = leafDef
+				to.Ref = e.ref(leaf, true)
+				pb.def.Connections[to.Ref] = append(pb.def.Connections[to.Ref], dst)
+			}
+			bridge.To = append(bridge.To, to)
+		}
+	}
+
+	for _, pb := range parts {
+		pb.def.ServiceDefs = map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {In: pb.service(pb.ins), Out: pb.service(pb.outs)},
+		}
+		if err := pb.def.Validate(); err != nil {
+			return nil, err
+		}
+		partition.Parts = append(partition.Parts, core.SlangFileDef{Main: pb.def.Id, Blueprints: append([]core.OperatorDef{pb.def}, pb.blueprints...)})
+	}
+	if err := partition.Main.Validate(); err != nil {
+		return nil, err
+	}
+
+	return partition, nil
+}
+
+// Spawner starts a worker which connects to the commander at the address. The returned function terminates it
+// unless it has already ended after being stopped.
+type Spawner func(mgntAddr string) (func() error, error)
+
+// LocalSpawner starts workers as slangr processes on this machine
+func LocalSpawner(slangr string, args ...string) Spawner {
+	return func(mgntAddr string) (func() error, error) {
+		cmd := exec.Command(slangr, append([]string{"--mgnt-addr", mgntAddr}, args...)...)
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		exited := make(chan bool)
+		go func() {
+			cmd.Wait()
+			close(exited)
+		}()
+		return func() error {
+			select {
+			case <-exited:
+				return nil
+			case <-time.After(time.Second):
+				return cmd.Process.Kill()
+			}
+		}, nil
+	}
+}
+
+// Cluster runs the parts of a partition in workers and forwards items along the bridges. The ports of the main
+// service are served by the cluster itself, items pushed into its in port are processed by the workers.
+type Cluster struct {
+	Partition *Partition
+	// Addr is the address workers connect to for receiving their part
+	Addr string
+	// Encoding is used for items sent along bridges
+	Encoding string
+	// Timeout limits the time until all workers have been initialized
+	Timeout time.Duration
+
+	main     *core.Operator
+	inFlight *InFlight
+	cmdr     Commander
+	workers  []clusterWorker
+	stops    []func() error
+	conns    []net.Conn
+	mutex    *sync.Mutex
+	next     int
+	closed   bool
+}
+
+type clusterWorker struct {
+	part  int
+	host  string
+	ports map[string]string
+	cmds  Commands
+}
+
+func NewCluster(partition *Partition) (*Cluster, error) {
+	main, err := CreateAndConnectOperator("", partition.Main, false)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cluster{
+		Partition: partition,
+		Addr:      ":0",
+		Encoding:  ENCODING_CBOR,
+		Timeout:   30 * time.Second,
+		main:      main,
+		mutex:     &sync.Mutex{},
+	}
+
+	main.Main().Out().Bufferize()
+	ports := []*core.Port{}
+	for _, bridge := range partition.Bridges {
+		for _, to := range bridge.To {
+			if to.Part != COORDINATOR {
+				continue
+			}
+			p, err := c.port(to.Ref)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, p)
+		}
+
+		if bridge.From.Part != COORDINATOR {
+			continue
+		}
+		p, err := c.port(bridge.From.Ref)
+		if err != nil {
+			return nil, err
+		}
+		p.Bufferize()
+		ports = append(ports, p)
+	}
+	c.inFlight = NewInFlight(ports...)
+	return c, nil
+}
+
+func (c *Cluster) Main() *core.Service {
+	return c.main.Main()
+}
+
+func (c *Cluster) port(ref string) (*core.Port, error) {
+	return core.ParsePortReference(ref, c.main)
+}
+
+// Start spawns a worker for each part and connects the bridges once all workers have been initialized. Workers are
+// stopped again in case not all of them could be started.
+func (c *Cluster) Start(spawn Spawner) error {
+	parts := c.Partition.Parts
+	c.cmdr = NewCommander(c.Addr)
+
+	// The main operator holds no instances, it is started so that stopping it ends pulling from its ports
+	c.main.Start(context.Background())
+
+	ready := make(chan clusterWorker, len(parts))
+	errs := make(chan error, len(parts)+1)
+
+	go func() {
+		err := c.cmdr.Begin(func(cmds Commands) error {
+			id, err := cmds.Hello()
+			if err != nil {
+				errs <- err
+				return nil
+			}
+			if id != "" {
+				// The worker has already been initialized and reconnects
+				return nil
+			}
+
+			c.mutex.Lock()
+			i := c.next
+			c.next++
+			c.mutex.Unlock()
+			if i >= len(parts) {
+				return nil
+			}
+
+			b, err := json.Marshal(parts[i])
+			if err != nil {
+				errs <- err
+				return nil
+			}
+			msg, err := cmds.Init(string(b))
+			if err != nil {
+				errs <- fmt.Errorf("part %d: %s", i+1, err)
+				return nil
+			}
+
+			w := clusterWorker{part: i, cmds: cmds}
+			if err := json.Unmarshal([]byte(msg), &w.ports); err != nil {
+				errs <- err
+				return nil
+			}
+			if peer, ok := cmds.(interface{ RemoteAddr() net.Addr }); ok {
+				w.host, _, _ = net.SplitHostPort(peer.RemoteAddr().String())
+			}
+			ready <- w
+			return nil
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	for range parts {
+		stop, err := spawn(c.cmdr.Addr())
+		if err != nil {
+			c.Stop()
+			return err
+		}
+		c.stops = append(c.stops, stop)
+	}
+
+	workers := make([]clusterWorker, len(parts))
+	timeout := time.After(c.Timeout)
+	for range parts {
+		select {
+		case w := <-ready:
+			workers[w.part] = w
+		case err := <-errs:
+			c.Stop()
+			return err
+		case <-timeout:
+			c.Stop()
+			return fmt.Errorf("workers have not been initialized within %s", c.Timeout)
+		}
+	}
+
+	c.mutex.Lock()
+	c.workers = workers
+	c.mutex.Unlock()
+
+	if err := c.connect(workers); err != nil {
+		c.Stop()
+		return err
+	}
+	return nil
+}
+
+// Status returns the status of each worker in the order of the parts
+func (c *Cluster) Status() ([]*WorkerStatus, error) {
+	c.mutex.Lock()
+	workers := c.workers
+	c.mutex.Unlock()
+
+	statuses := make([]*WorkerStatus, len(workers))
+	for i, w := range workers {
+		msg, err := w.cmds.Status()
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %s", i+1, err)
+		}
+		if statuses[i], err = ParseWorkerStatus(msg); err != nil {
+			return nil, err
+		}
+	}
+	return statuses, nil
+}
+
+// Drain waits until every item which has entered the cluster has left it. Items may still be pushed into the main in
+// port, so pushing should have ended before. Workers are not drained one by one since items may move back and forth
+// between them.
+func (c *Cluster) Drain(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		statuses, err := c.Status()
+		if err != nil {
+			return err
+		}
+
+		buffered := c.main.Buffered()
+		for _, status := range statuses {
+			buffered += status.Buffered
+		}
+		if buffered == 0 && c.inFlight.Items() <= 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d items left after %s", buffered, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (c *Cluster) dial(w clusterWorker, ref string) (*WireConn, error) {
+	addr, ok := w.ports[ref]
+	if !ok {
+		return nil, fmt.Errorf("part %d has no port %s", w.part+1, ref)
+	}
+	if w.host != "" {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = net.JoinHostPort(w.host, port)
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	c.conns = append(c.conns, conn)
+	c.mutex.Unlock()
+
+	wc := NewWireConn(conn)
+	if _, err := wc.Handshake(c.Encoding); err != nil {
+		return nil, err
+	}
+	return wc, nil
+}
+
+// connect connects all destinations before items start flowing from the sources
+func (c *Cluster) connect(workers []clusterWorker) error {
+	type forward func(v interface{}) error
+	forwards := make([][]forward, len(c.Partition.Bridges))
+
+	for i, bridge := range c.Partition.Bridges {
+		for _, to := range bridge.To {
+			if to.Part == COORDINATOR {
+				p, err := c.port(to.Ref)
+				if err != nil {
+					return err
+				}
+				forwards[i] = append(forwards[i], func(v interface{}) error {
+					v = BindMarker(p, v)
+					p.Push(v)
+					c.inFlight.Count(p, v)
+					return nil
+				})
+				continue
+			}
+
+			wc, err := c.dial(workers[to.Part], to.Ref)
+			if err != nil {
+				return err
+			}
+			forwards[i] = append(forwards[i], wc.WriteValue)
+		}
+	}
+
+	for i, bridge := range c.Partition.Bridges {
+		fs := forwards[i]
+		send := func(v interface{}) error {
+			for _, f := range fs {
+				if err := f(v); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if bridge.From.Part == COORDINATOR {
+			p, err := c.port(bridge.From.Ref)
+			if err != nil {
+				return err
+			}
+			go func() {
+				for {
+					// Items are counted before they are sent, so that they are in flight until they have left
+					v, ok := p.PullOK()
+					if !ok {
+						return
+					}
+					c.inFlight.Count(p, v)
+					if err := send(v); err != nil {
+						return
+					}
+				}
+			}()
+			continue
+		}
+
+		wc, err := c.dial(workers[bridge.From.Part], bridge.From.Ref)
+		if err != nil {
+			return err
+		}
+		go func(from Endpoint) {
+			for {
+				v, err := wc.ReadValue()
+				if _, ok := err.(*DecodeError); ok {
+					continue
+				}
+				if err != nil {
+					if !c.isClosed() {
+						log.Printf("bridge from %s of part %d closed: %s", from.Ref, from.Part+1, err)
+					}
+					return
+				}
+				if err := send(v); err != nil {
+					return
+				}
+			}
+		}(bridge.From)
+	}
+	return nil
+}
+
+func (c *Cluster) isClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// Stop closes all bridges, stops the workers and terminates those which do not end
+func (c *Cluster) Stop() error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.closed = true
+	conns, stops, workers := c.conns, c.stops, c.workers
+	c.mutex.Unlock()
+
+	c.main.Stop()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	// Workers are asked to stop before they are terminated
+	for _, w := range workers {
+		if _, err := w.cmds.Stop(); err != nil {
+			log.Printf("cannot stop part %d: %s", w.part+1, err)
+		}
+	}
+	var err error
+	for _, stop := range stops {
+		if e := stop(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if c.cmdr != nil {
+		c.cmdr.Close()
+	}
+	return err
+}
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotStarted is returned when values are received from a runtime which has not been started yet
+	ErrNotStarted = errors.New("runtime not started")
+	// ErrClosed is returned by all methods of a runtime after it has been closed
+	ErrClosed = errors.New("runtime closed")
+	// ErrStopped is returned when the operator has stopped on its own, e.g. because one of its builtins panicked
+	ErrStopped = errors.New("operator stopped")
+)
+
+// Runtime runs an operator in the calling process and exchanges values with its main service. It is meant for
+// embedding operators into Go programs and safe for concurrent use.
+//
+// Goroutines: Start launches one goroutine pulling items from the main out port and one watching its context. Both
+// have ended or are about to end when Close returns. Each Send hands the item to a goroutine pushing it into the
+// operator, which ends as soon as the operator has accepted the item, even if Send has returned early.
+//
+// Shutdown: Close stops the operator and all of its children. Items not received yet are dropped, pending and later
+// calls return ErrClosed. Cancelling the context passed to Start closes the runtime as well. If the operator stops
+// on its own, Receive returns ErrStopped after all items produced before have been received.
+type Runtime struct {
+	op    *core.Operator
+	inDef core.TypeDef
+
+	items   chan interface{}
+	sending chan bool
+	calling chan bool
+	closing chan bool
+	pulled  chan bool
+
+	mutex   *sync.Mutex
+	started bool
+	closed  bool
+}
+
+// LoadRuntime builds and compiles the operator with the given generics and properties, loading it and its
+// dependencies from the storage
+func LoadRuntime(opId uuid.UUID, gens core.Generics, props core.Properties, st storage.Storage) (*Runtime, error) {
+	op, err := BuildAndCompile(opId, gens, props, st)
+	if err != nil {
+		return nil, err
+	}
+	return NewRuntime(op), nil
+}
+
+// NewRuntime creates a runtime for a compiled operator which has not been started yet
+func NewRuntime(op *core.Operator) *Runtime {
+	op.Main().Out().Bufferize()
+	return &Runtime{
+		op:      op,
+		inDef:   op.Main().In().Define(),
+		items:   make(chan interface{}),
+		sending: make(chan bool, 1),
+		calling: make(chan bool, 1),
+		closing: make(chan bool),
+		pulled:  make(chan bool),
+		mutex:   &sync.Mutex{},
+	}
+}
+
+func (r *Runtime) Operator() *core.Operator {
+	return r.op
+}
+
+// Start starts the operator. The runtime is closed once ctx is done.
+func (r *Runtime) Start(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+	if r.started {
+		return errors.New("runtime already started")
+	}
+	r.started = true
+
+	r.op.Start(ctx)
+	go r.pull()
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.Close()
+		case <-r.closing:
+		}
+	}()
+	return nil
+}
+
+// Close stops the operator and waits until no more items are pulled from it. It may be called several times.
+func (r *Runtime) Close() error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.closing)
+	started := r.started
+	r.mutex.Unlock()
+
+	if started {
+		r.op.Stop()
+		<-r.pulled
+	}
+	return nil
+}
+
+// Send pushes the value into the main in port. Besides Slang values it accepts all Go values which can be encoded as
+// JSON, e.g. structs become maps. The value has to match the type of the port. In case ctx is done before the
+// operator has accepted the value, Send returns the error of ctx and the value may still be processed.
+// Values sent one after another are processed in the same order.
+func (r *Runtime) Send(ctx context.Context, v interface{}) error {
+	if err := r.check(); err != nil {
+		return err
+	}
+
+	item, err := slangValue(v)
+	if err != nil {
+		return err
+	}
+	if err := r.inDef.VerifyData(item); err != nil {
+		return err
+	}
+
+	select {
+	case r.sending <- true:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.closing:
+		return ErrClosed
+	}
+
+	pushed := make(chan bool)
+	go func() {
+		in := r.op.Main().In()
+		in.Push(BindMarker(in, item))
+		<-r.sending
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.closing:
+		return ErrClosed
+	}
+}
+
+// SendAll sends the values one after another, stopping at the first error
+func (r *Runtime) SendAll(ctx context.Context, vs []interface{}) error {
+	for _, v := range vs {
+		if err := r.Send(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Receive returns the next item of the main out port, waiting until there is one or ctx is done
+func (r *Runtime) Receive(ctx context.Context) (interface{}, error) {
+	if err := r.check(); err != nil && err != ErrStopped {
+		return nil, err
+	}
+
+	select {
+	case v, ok := <-r.items:
+		if !ok {
+			if err := r.check(); err != nil {
+				return nil, err
+			}
+			return nil, ErrStopped
+		}
+		return v, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-r.closing:
+		return nil, ErrClosed
+	}
+}
+
+// ReceiveInto receives the next item and stores it in the value pointed to by dst as json.Unmarshal would do
+func (r *Runtime) ReceiveInto(ctx context.Context, dst interface{}) error {
+	v, err := r.Receive(ctx)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+// Call sends the value and receives the next item, for operators producing exactly one item per item. Calls are
+// processed one after another and must not be mixed with Send and Receive.
+func (r *Runtime) Call(ctx context.Context, v interface{}) (interface{}, error) {
+	select {
+	case r.calling <- true:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-r.calling }()
+
+	if err := r.Send(ctx, v); err != nil {
+		return nil, err
+	}
+	return r.Receive(ctx)
+}
+
+// Each calls f for every item received until the runtime is closed, ctx is done or f returns an error. It returns
+// nil if the runtime has been closed.
+func (r *Runtime) Each(ctx context.Context, f func(v interface{}) error) error {
+	for {
+		v, err := r.Receive(ctx)
+		if err == ErrClosed {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(v); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Runtime) check() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+	if !r.started {
+		return ErrNotStarted
+	}
+	if r.op.Stopped() {
+		return ErrStopped
+	}
+	return nil
+}
+
+// pull forwards the items of the main out port until the operator has been stopped
+func (r *Runtime) pull() {
+	defer close(r.pulled)
+	defer close(r.items)
+
+	out := r.op.Main().Out()
+	for {
+		v, ok := out.PullOK()
+		if !ok {
+			return
+		}
+		select {
+		case r.items <- v:
+		case <-r.closing:
+			return
+		}
+	}
+}
+
+// slangValue converts Go values into the values used by operators
+func slangValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, string, float64, bool, core.Binary:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case []byte:
+		return core.Binary(v), nil
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if items[i], err = slangValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			var err error
+			if m[k], err = slangValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var item interface{}
+	if err := json.Unmarshal(b, &item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
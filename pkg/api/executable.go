@@ -0,0 +1,356 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// Executable runs the main operator of a SlangFileDef in this process and serves its main service over
+// stdin/stdout, HTTP or sockets speaking the slangr wire protocol. Items are pulled from the main out port by one
+// of them only, so only one way of serving should be used at a time.
+type Executable struct {
+	SlangFile *core.SlangFileDef
+	// DrainTimeout limits the time for processing remaining items after the input has ended
+	DrainTimeout time.Duration
+	// RequestTimeout limits the time HTTP requests wait for their turn and for their reply
+	RequestTimeout time.Duration
+	// MaxRequestSize limits the size of the bodies of HTTP requests in bytes
+	MaxRequestSize int64
+
+	op       *core.Operator
+	mutex    *sync.Mutex
+	requests chan bool
+	replies  []chan interface{}
+	dispatch *sync.Once
+}
+
+func NewExecutable(slFile *core.SlangFileDef) (*Executable, error) {
+	if err := slFile.Validate(); err != nil {
+		return nil, err
+	}
+	st := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(slFile.Blueprints...))
+
+	op, err := BuildAndCompile(uuid.MustParse(slFile.Main), slFile.Args.Generics, slFile.Args.Properties, *st)
+	if err != nil {
+		return nil, err
+	}
+	op.Main().Out().Bufferize()
+
+	return &Executable{slFile, 10 * time.Second, 30 * time.Second, 1 << 20, op, &sync.Mutex{}, make(chan bool, 1), nil, &sync.Once{}}, nil
+}
+
+func (e *Executable) Operator() *core.Operator {
+	return e.op
+}
+
+func (e *Executable) Start() {
+	e.op.Start(context.Background())
+}
+
+func (e *Executable) Stop() {
+	e.op.Stop()
+}
+
+// ServeStdio pushes each line read from r as JSON value into the main in port and writes each item of the main out
+// port to w as JSON line. After r has ended it waits until each item has produced its output, at most DrainTimeout.
+func (e *Executable) ServeStdio(r io.Reader, w io.Writer) error {
+	in, out := e.op.Main().In(), e.op.Main().Out()
+	inFlight := NewInFlight(in, out)
+
+	pulled := make(chan bool, 1)
+	go func() {
+		wr := bufio.NewWriter(w)
+		for {
+			v, ok := out.PullOK()
+			if !ok {
+				return
+			}
+			m, err := EncodeValue(ENCODING_JSON, v)
+			if err != nil {
+				log.Print(err)
+			} else if err := Wrbuf(wr, string(m)); err != nil {
+				log.Print(err)
+				return
+			}
+			inFlight.Count(out, v)
+			select {
+			case pulled <- true:
+			default:
+			}
+		}
+	}()
+
+	rd := bufio.NewReader(r)
+	for {
+		m, err := Rdbuf(rd)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var v interface{}
+		if len(m) != 0 {
+			if v, err = DecodeValue(ENCODING_JSON, []byte(m)); err != nil {
+				log.Printf("invalid input %s: %s", m, err)
+				continue
+			}
+		}
+		v = BindMarker(in, v)
+		in.Push(v)
+		inFlight.Count(in, v)
+	}
+
+	// Items being processed by an operator are not buffered anywhere, so wait for their output instead
+	deadline := time.After(e.DrainTimeout)
+	for inFlight.Items() > 0 {
+		select {
+		case <-pulled:
+		case <-deadline:
+			return fmt.Errorf("%d items left after %s", inFlight.Items(), e.DrainTimeout)
+		}
+	}
+	return nil
+}
+
+// ServeHTTP pushes the JSON value posted into the main in port and replies with the next item of the main out port,
+// so the operator is expected to produce exactly one item per item. Items are pushed one request after another, each
+// request waiting at most RequestTimeout for its turn and its reply. Replies to requests which have timed out are
+// dropped. GET requests are answered with the port definitions of the main service.
+func (e *Executable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	in, out := e.op.Main().In(), e.op.Main().Out()
+
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]core.TypeDef{"in": in.Define(), "out": out.Define()})
+		return
+	case "POST":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Reading fails for bodies exceeding the limit only, as the client is still connected
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, e.MaxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var v interface{}
+	if len(b) != 0 {
+		if v, err = DecodeValue(ENCODING_JSON, b); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	e.dispatch.Do(func() { go e.dispatchReplies() })
+
+	timeout := time.NewTimer(e.RequestTimeout)
+	defer timeout.Stop()
+
+	// Only pushing and queueing the reply is exclusive, so that items and replies stay in the same order
+	select {
+	case e.requests <- true:
+	case <-timeout.C:
+		http.Error(w, "operator busy", http.StatusServiceUnavailable)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	res := make(chan interface{}, 1)
+	e.mutex.Lock()
+	e.replies = append(e.replies, res)
+	e.mutex.Unlock()
+	in.Push(BindMarker(in, v))
+	<-e.requests
+
+	select {
+	case v := <-res:
+		m, err := EncodeValue(ENCODING_JSON, v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(m)
+	case <-timeout.C:
+		http.Error(w, "no reply from operator", http.StatusGatewayTimeout)
+	case <-r.Context().Done():
+	}
+}
+
+// dispatchReplies passes the items of the main out port to the requests in the order they have been pushed
+func (e *Executable) dispatchReplies() {
+	out := e.op.Main().Out()
+	for {
+		v, ok := out.PullOK()
+		if !ok {
+			return
+		}
+
+		e.mutex.Lock()
+		if len(e.replies) == 0 {
+			e.mutex.Unlock()
+			log.Printf("dropping item %v without request", v)
+			continue
+		}
+		res := e.replies[0]
+		e.replies = e.replies[1:]
+		e.mutex.Unlock()
+
+		res <- v
+	}
+}
+
+// ServeSocket accepts connections of senders on inLn and receivers on outLn, just like a slangr worker aggregating
+// its main ports. It returns once both listeners have been closed.
+func (e *Executable) ServeSocket(inLn net.Listener, outLn net.Listener) error {
+	in, out := e.op.Main().In(), e.op.Main().Out()
+	id := e.op.Id().String()
+
+	serve := func(ln net.Listener, hndl func(wc *WireConn)) error {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return err
+			}
+			go func() {
+				defer conn.Close()
+				wc := NewWireConn(conn)
+				if err := wc.Accept(id); err != nil {
+					log.Printf("handshake failed: %s", err)
+					return
+				}
+				hndl(wc)
+			}()
+		}
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- serve(inLn, func(wc *WireConn) {
+			for {
+				v, err := wc.ReadValue()
+				if _, ok := err.(*DecodeError); ok {
+					continue
+				}
+				if err != nil {
+					return
+				}
+				in.Push(BindMarker(in, v))
+			}
+		})
+	}()
+	go func() {
+		errs <- serve(outLn, func(wc *WireConn) {
+			for !e.op.Stopped() {
+				e.mutex.Lock()
+				v, ok := out.PullOK()
+				e.mutex.Unlock()
+				if !ok {
+					return
+				}
+				if err := wc.WriteValue(v); err != nil {
+					log.Printf("cannot write %v: %s", v, err)
+					return
+				}
+			}
+		})
+	}()
+
+	err := <-errs
+	<-errs
+	return err
+}
+
+// RunExecutable is the entry point of programs built by slang build. It parses the bundled SlangFileDef and serves
+// the operator as selected by the arguments, which are parsed as command line flags.
+func RunExecutable(bundle []byte, args []string) error {
+	var slFile core.SlangFileDef
+	if err := json.Unmarshal(bundle, &slFile); err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	httpAddr := fs.String("http", "", "serve the operator over HTTP at the address, each POST request is one item")
+	socketIn := fs.String("socket-in", "", "accept senders of items speaking the slangr wire protocol at the address")
+	socketOut := fs.String("socket-out", "", "accept receivers of items speaking the slangr wire protocol at the address")
+	drainTimeout := fs.Duration("drain-timeout", 10*time.Second, "time for processing remaining items after stdin has ended")
+	requestTimeout := fs.Duration("request-timeout", 30*time.Second, "time HTTP requests wait for their turn and reply")
+	maxRequestSize := fs.Int64("max-request-size", 1<<20, "maximum size of the bodies of HTTP requests in bytes")
+	printPorts := fs.Bool("print-ports", false, "display the port definitions of the operator")
+	fs.Parse(args)
+
+	exe, err := NewExecutable(&slFile)
+	if err != nil {
+		return err
+	}
+	exe.DrainTimeout = *drainTimeout
+	exe.RequestTimeout = *requestTimeout
+	exe.MaxRequestSize = *maxRequestSize
+
+	if *printPorts {
+		b, _ := json.Marshal(exe.op.Main().In().Define())
+		fmt.Printf("In:\n\t%s\n", b)
+		b, _ = json.Marshal(exe.op.Main().Out().Define())
+		fmt.Printf("Out:\n\t%s\n", b)
+		return nil
+	}
+
+	exe.Start()
+	defer exe.Stop()
+
+	errs := make(chan error, 1)
+	switch {
+	case *httpAddr != "":
+		go func() { errs <- http.ListenAndServe(*httpAddr, exe) }()
+	case *socketIn != "" || *socketOut != "":
+		if *socketIn == "" || *socketOut == "" {
+			return fmt.Errorf("both -socket-in and -socket-out are required")
+		}
+		inLn, err := net.Listen("tcp", *socketIn)
+		if err != nil {
+			return err
+		}
+		outLn, err := net.Listen("tcp", *socketOut)
+		if err != nil {
+			inLn.Close()
+			return err
+		}
+		defer inLn.Close()
+		defer outLn.Close()
+		go func() { errs <- exe.ServeSocket(inLn, outLn) }()
+	default:
+		go func() { errs <- exe.ServeStdio(os.Stdin, os.Stdout) }()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-quit:
+		return nil
+	}
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/google/uuid"
+)
+
+// External operators are elementary operators implemented by a process, which may be written in any language. The
+// process is started along with the operator and exchanges messages with it as JSON objects, one per line, reading
+// them from stdin and writing them to stdout. Stderr is passed through.
+//
+// Messages sent to the process:
+//
+//	{"type":"init","properties":{...}}             once after starting, holding the properties of the instance
+//	{"type":"item","service":"main","value":...}   item arriving at the in port of a service
+//	{"type":"item","delegate":"name","value":...}  item arriving at the in port of a delegate, i.e. its result
+//
+// Messages read from the process:
+//
+//	{"type":"item","service":"main","value":...}   item for the out port of a service
+//	{"type":"item","delegate":"name","value":...}  item for the out port of a delegate, i.e. calling it
+//	{"type":"error","error":"..."}                 error which is logged
+//
+// Values are encoded like EncodeValue does with ENCODING_JSON, e.g. binaries as {"$binary":"<base64>"}. The process
+// has to write exactly one item to the service for each item it has read from it. Markers of streams surrounding the
+// operator are not sent to the process, they are pushed to the out port of the service once the process has written
+// the items for all items before them.
+//
+// Stdin is closed once the operator has been stopped and the process has ExternalStopTimeout to exit before it is
+// killed. In case the process exits on its own, the operator is stopped.
+const (
+	EXTERNAL_INIT  = "init"
+	EXTERNAL_ITEM  = "item"
+	EXTERNAL_ERROR = "error"
+)
+
+var ExternalStopTimeout = 5 * time.Second
+
+type externalMessage struct {
+	Type       string                 `json:"type"`
+	Service    string                 `json:"service,omitempty"`
+	Delegate   string                 `json:"delegate,omitempty"`
+	Value      json.RawMessage        `json:"value,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+type externalOperator struct {
+	def    core.OperatorDef
+	props  core.Properties
+	path   string
+	dir    string
+	wr     *bufio.Writer
+	mutex  *sync.Mutex
+	queues map[string]*markerQueue
+}
+
+// markerQueue keeps the order of items and markers of a service while the items are processed by the process
+type markerQueue struct {
+	out      *core.Port
+	mutex    *sync.Mutex
+	sent     int
+	received int
+	markers  []queuedMarker
+}
+
+type queuedMarker struct {
+	marker interface{}
+	after  int
+}
+
+// NewExternalOperator creates an instance of an operator implemented by an external process. The command is resolved
+// when creating the operator. In case a policy is set, it has to grant the process capability for the command.
+func NewExternalOperator(insDef core.InstanceDef) (*core.Operator, error) {
+	def := insDef.OperatorDef
+	if !def.IsExternal() {
+		return nil, fmt.Errorf("%s is no external operator", def.Id)
+	}
+	if err := def.GenericsSpecified(); err != nil {
+		return nil, err
+	}
+
+	dir := os.ExpandEnv(def.External.Dir)
+	path, err := resolveCommand(os.ExpandEnv(def.External.Command[0]), dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", def.Meta.Name, err)
+	}
+	if policy := elem.GetPolicy(); policy != nil {
+		if err := policy.Allows(elem.Capability{Type: elem.CAP_PROCESS, Value: path}); err != nil {
+			return nil, fmt.Errorf("%s: %s", def.Meta.Name, err)
+		}
+	}
+
+	def.Elementary = def.Id
+	ext := &externalOperator{def: def, props: insDef.Properties, path: path, dir: dir, mutex: &sync.Mutex{}}
+	return core.NewOperator(insDef.Name, ext.run, nil, insDef.Generics, insDef.Properties, def)
+}
+
+// resolveCommand returns the absolute path of the executable. Names are looked up in PATH, relative paths are
+// relative to the working directory of the process.
+func resolveCommand(name string, dir string) (string, error) {
+	if strings.ContainsRune(name, filepath.Separator) && !filepath.IsAbs(name) && dir != "" {
+		name = filepath.Join(dir, name)
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// wrapExternal creates an operator containing the specified external operator as only instance, so that it can be
+// run by itself
+func wrapExternal(opDef core.OperatorDef, gens core.Generics, props core.Properties) (*core.OperatorDef, error) {
+	srvDef, ok := opDef.ServiceDefs[core.MAIN_SERVICE]
+	if !ok || len(opDef.ServiceDefs) != 1 || len(opDef.DelegateDefs) != 0 {
+		return nil, fmt.Errorf("%s: external operators can only be run by themselves if they have a main service only", opDef.Meta.Name)
+	}
+	srvCpy := srvDef.Copy()
+
+	return &core.OperatorDef{
+		Id:          uuid.New().String(),
+		Meta:        opDef.Meta,
+		ServiceDefs: map[string]*core.ServiceDef{core.MAIN_SERVICE: &srvCpy},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "external", Operator: opDef.Id, Generics: gens, Properties: props, OperatorDef: opDef},
+		},
+		Connections: map[string][]string{
+			"(":         {"(external"},
+			"external)": {")"},
+		},
+	}, nil
+}
+
+func (e *externalOperator) run(op *core.Operator) {
+	ext := e.def.External
+	args := make([]string, len(ext.Command)-1)
+	for i, arg := range ext.Command[1:] {
+		args[i] = os.ExpandEnv(arg)
+	}
+
+	cmd := exec.Command(e.path, args...)
+	cmd.Dir = e.dir
+	cmd.Env = os.Environ()
+	for k, v := range ext.Env {
+		cmd.Env = append(cmd.Env, k+"="+os.ExpandEnv(v))
+	}
+	cmd.Stderr = os.Stderr
+
+	stdin, stdout, err := startCommand(cmd)
+	if err != nil {
+		// The operator cannot do anything without its process
+		log.Printf("external operator %s: cannot start %s: %s", op.Name(), e.path, err)
+		op.Stop()
+		return
+	}
+	e.wr = bufio.NewWriter(stdin)
+
+	e.queues = make(map[string]*markerQueue)
+	for name := range e.def.ServiceDefs {
+		e.queues[name] = &markerQueue{out: op.Service(name).Out(), mutex: &sync.Mutex{}}
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		e.receive(op, stdout)
+		exited <- cmd.Wait()
+	}()
+
+	props, _ := toJSONValue(map[string]interface{}(e.props)).(map[string]interface{})
+	e.send(op, externalMessage{Type: EXTERNAL_INIT, Properties: props})
+
+	for name, q := range e.queues {
+		go e.forward(op, op.Service(name).In(), q, externalMessage{Type: EXTERNAL_ITEM, Service: name})
+	}
+	for name := range e.def.DelegateDefs {
+		go e.forward(op, op.Delegate(name).In(), nil, externalMessage{Type: EXTERNAL_ITEM, Delegate: name})
+	}
+
+	select {
+	case err := <-exited:
+		log.Printf("external operator %s exited: %v", op.Name(), err)
+		op.Stop()
+		return
+	case <-op.Done():
+	}
+
+	stdin.Close()
+	select {
+	case <-exited:
+	case <-time.After(ExternalStopTimeout):
+		cmd.Process.Kill()
+		<-exited
+	}
+}
+
+func startCommand(cmd *exec.Cmd) (io.WriteCloser, io.ReadCloser, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return stdin, stdout, nil
+}
+
+// forward sends the items of the in port to the process, markers of services are queued
+func (e *externalOperator) forward(op *core.Operator, in *core.Port, q *markerQueue, msg externalMessage) {
+	for {
+		i, ok := in.PullOK()
+		if !ok {
+			return
+		}
+		if q != nil && core.IsMarker(i) {
+			q.marker(i)
+			continue
+		}
+
+		v, err := EncodeValue(ENCODING_JSON, i)
+		if err != nil {
+			log.Printf("external operator %s: cannot encode %v: %s", op.Name(), i, err)
+			continue
+		}
+		msg.Value = v
+		if q != nil {
+			q.item()
+		}
+		e.send(op, msg)
+	}
+}
+
+func (e *externalOperator) send(op *core.Operator, msg externalMessage) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("external operator %s: %s", op.Name(), err)
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if err := Wrbuf(e.wr, string(b)); err != nil && !op.Stopped() {
+		log.Printf("external operator %s: %s", op.Name(), err)
+	}
+}
+
+// receive pushes the items written by the process into the according out ports until it closes stdout
+func (e *externalOperator) receive(op *core.Operator, stdout io.Reader) {
+	rd := bufio.NewReader(stdout)
+	for {
+		line, err := Rdbuf(rd)
+		if err != nil {
+			return
+		}
+		if line == "" {
+			continue
+		}
+
+		var msg externalMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			log.Printf("external operator %s: invalid message %s", op.Name(), line)
+			continue
+		}
+
+		switch msg.Type {
+		case EXTERNAL_ITEM:
+			var v interface{}
+			if len(msg.Value) != 0 {
+				if v, err = DecodeValue(ENCODING_JSON, msg.Value); err != nil {
+					log.Printf("external operator %s: invalid value %s: %s", op.Name(), msg.Value, err)
+					continue
+				}
+			}
+
+			if msg.Delegate != "" {
+				if dlg := op.Delegate(msg.Delegate); dlg != nil {
+					dlg.Out().Push(v)
+					continue
+				}
+			} else if q, ok := e.queues[msg.Service]; ok {
+				q.output(v)
+				continue
+			}
+			log.Printf("external operator %s: unknown port in %s", op.Name(), line)
+		case EXTERNAL_ERROR:
+			log.Printf("external operator %s: %s", op.Name(), msg.Error)
+		default:
+			log.Printf("external operator %s: unknown message type %s", op.Name(), msg.Type)
+		}
+	}
+}
+
+// item counts an item sent to the process
+func (q *markerQueue) item() {
+	q.mutex.Lock()
+	q.sent++
+	q.mutex.Unlock()
+}
+
+// marker pushes the marker as soon as the outputs for all items sent before have been pushed
+func (q *markerQueue) marker(m interface{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.markers) == 0 && q.received >= q.sent {
+		q.out.Push(m)
+		return
+	}
+	q.markers = append(q.markers, queuedMarker{m, q.sent})
+}
+
+// output pushes an item written by the process followed by the markers waiting for it
+func (q *markerQueue) output(v interface{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.out.Push(v)
+	q.received++
+	for len(q.markers) != 0 && q.markers[0].after <= q.received {
+		q.out.Push(q.markers[0].marker)
+		q.markers = q.markers[1:]
+	}
+}
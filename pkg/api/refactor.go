@@ -0,0 +1,301 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// Extraction is the result of extracting instances into a new operator
+type Extraction struct {
+	Operator  core.OperatorDef `json:"operator"`
+	Extracted core.OperatorDef `json:"extracted"`
+	Instance  string           `json:"instance"`
+}
+
+// cutPort is a port of the original operator whose connections cross the border of the extracted instances
+type cutPort struct {
+	ref   string
+	key   string
+	conns []string
+}
+
+// Extract moves the instances of the operator into a new operator and replaces them by a single instance of it.
+// Connections between the extracted instances move into the new operator. Connections crossing the border are cut
+// and rewired through its main service, whose port types are inferred from the cut ports. Properties and generics
+// used by the extracted instances are passed through. Both operators are stored, the original operator is left
+// untouched in case the result cannot be built.
+func Extract(opId uuid.UUID, insNames []string, name string, insName string, st storage.Storage) (*Extraction, error) {
+	if len(insNames) == 0 {
+		return nil, fmt.Errorf("no instances to extract")
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("name of the new operator may not be empty")
+	}
+
+	opDef, err := st.Load(opId)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDumpable(opId) {
+		return nil, fmt.Errorf("operator %s is not stored locally", opId)
+	}
+
+	names := make(map[string]bool)
+	for _, insDef := range opDef.InstanceDefs {
+		names[insDef.Name] = true
+	}
+	extracted := make(map[string]bool)
+	for _, n := range insNames {
+		if !names[n] {
+			return nil, fmt.Errorf("unknown instance %s", n)
+		}
+		extracted[n] = true
+	}
+
+	if insName == "" {
+		insName = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	}
+	if names[insName] && !extracted[insName] {
+		return nil, fmt.Errorf("instance %s already exists", insName)
+	}
+
+	// Port types are taken from the built operator
+	op, err := Build(opId, nil, nil, st)
+	if err != nil {
+		return nil, fmt.Errorf("cannot infer port types: %s", err)
+	}
+
+	newDef := core.OperatorDef{
+		Id:           uuid.New().String(),
+		Meta:         core.OperatorMetaDef{Name: name, Tags: opDef.Meta.Tags},
+		ServiceDefs:  make(map[string]*core.ServiceDef),
+		PropertyDefs: make(core.TypeDefMap),
+		Connections:  make(map[string][]string),
+	}
+	origDef := opDef.Copy(false)
+	origDef.InstanceDefs = nil
+	origDef.Connections = make(map[string][]string)
+
+	newIns := &core.InstanceDef{
+		Name:       insName,
+		Operator:   newDef.Id,
+		Properties: make(core.Properties),
+		Generics:   make(core.Generics),
+	}
+
+	for _, insDef := range opDef.InstanceDefs {
+		if !extracted[insDef.Name] {
+			origDef.InstanceDefs = append(origDef.InstanceDefs, insDef)
+			continue
+		}
+		newDef.InstanceDefs = append(newDef.InstanceDefs, insDef)
+
+		for _, propVal := range insDef.Properties {
+			if propKey, ok := propVal.(string); ok && strings.HasPrefix(propKey, "$") {
+				propKey = propKey[1:]
+				if propDef, ok := opDef.PropertyDefs[propKey]; ok {
+					newDef.PropertyDefs[propKey] = propDef
+					newIns.Properties[propKey] = "$" + propKey
+				}
+			}
+		}
+		for _, gen := range insDef.Generics {
+			for _, g := range genericIdentifiers(gen) {
+				newIns.Generics[g] = &core.TypeDef{Type: "generic", Generic: g}
+			}
+		}
+	}
+	origDef.InstanceDefs = append(origDef.InstanceDefs, newIns)
+
+	// Sort connections into kept, moved and cut ones
+	var ins, outs []*cutPort
+	cut := func(ports []*cutPort, ref string) *cutPort {
+		for _, p := range ports {
+			if p.ref == ref {
+				return p
+			}
+		}
+		return nil
+	}
+	srcs := make([]string, 0, len(opDef.Connections))
+	for src := range opDef.Connections {
+		srcs = append(srcs, src)
+	}
+	sort.Strings(srcs)
+
+	for _, src := range srcs {
+		srcInside := extracted[refInstance(src)]
+		for _, dst := range opDef.Connections[src] {
+			dstInside := extracted[refInstance(dst)]
+			switch {
+			case srcInside && dstInside:
+				newDef.Connections[src] = append(newDef.Connections[src], dst)
+			case !srcInside && !dstInside:
+				origDef.Connections[src] = append(origDef.Connections[src], dst)
+			case dstInside:
+				p := cut(ins, src)
+				if p == nil {
+					p = &cutPort{ref: src, key: portKey(dst)}
+					ins = append(ins, p)
+				}
+				p.conns = append(p.conns, dst)
+			default:
+				p := cut(outs, src)
+				if p == nil {
+					p = &cutPort{ref: src, key: portKey(src)}
+					outs = append(outs, p)
+				}
+				p.conns = append(p.conns, dst)
+			}
+		}
+	}
+
+	// Cut connections are routed through the main service of the new operator
+	inDef, err := cutType(op, ins, true)
+	if err != nil {
+		return nil, err
+	}
+	outDef, err := cutType(op, outs, false)
+	if err != nil {
+		return nil, err
+	}
+	newDef.ServiceDefs[core.MAIN_SERVICE] = &core.ServiceDef{In: inDef, Out: outDef}
+
+	for _, p := range ins {
+		path := ""
+		if len(ins) > 1 {
+			path = p.key
+		}
+		newDef.Connections[path+"("] = append(newDef.Connections[path+"("], p.conns...)
+		origDef.Connections[p.ref] = append(origDef.Connections[p.ref], path+"("+insName)
+	}
+	for _, p := range outs {
+		path := ""
+		if len(outs) > 1 {
+			path = p.key
+		}
+		newDef.Connections[p.ref] = append(newDef.Connections[p.ref], ")"+path)
+		origDef.Connections[insName+")"+path] = append(origDef.Connections[insName+")"+path], p.conns...)
+	}
+
+	if len(newDef.PropertyDefs) == 0 {
+		newDef.PropertyDefs = nil
+	}
+	if err := newDef.Validate(); err != nil {
+		return nil, err
+	}
+	if err := origDef.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := st.Store(newDef); err != nil {
+		return nil, err
+	}
+	if _, err := st.Store(origDef); err != nil {
+		st.Remove(uuid.MustParse(newDef.Id))
+		return nil, err
+	}
+	if _, err := Build(opId, nil, nil, st); err != nil {
+		st.Store(*opDef)
+		st.Remove(uuid.MustParse(newDef.Id))
+		return nil, fmt.Errorf("extracted operator cannot be built: %s", err)
+	}
+
+	return &Extraction{origDef, newDef, insName}, nil
+}
+
+// refInstance returns the name of the instance a port reference points to, which is empty for the operator itself
+func refInstance(ref string) string {
+	opPart := ""
+	if i := strings.Index(ref, "("); i != -1 {
+		opPart = ref[i+1:]
+	} else if i := strings.Index(ref, ")"); i != -1 {
+		opPart = ref[:i]
+	}
+	if i := strings.Index(opPart, "@"); i != -1 {
+		return opPart[i+1:]
+	}
+	if i := strings.Index(opPart, "."); i != -1 {
+		return opPart[:i]
+	}
+	return opPart
+}
+
+// portKey derives a map entry name for a cut port from its instance and path, e.g. "add_a" for "a(add"
+func portKey(ref string) string {
+	path := ""
+	if i := strings.Index(ref, "("); i != -1 {
+		path = ref[:i]
+	} else if i := strings.Index(ref, ")"); i != -1 {
+		path = ref[i+1:]
+	}
+	key := refInstance(ref)
+	if path != "" {
+		key += "_" + strings.Replace(path, ".", "_", -1)
+	}
+	return key
+}
+
+// cutType returns the type of the in or out port replacing the cut ports, a map in case there are several of them
+func cutType(op *core.Operator, ports []*cutPort, in bool) (core.TypeDef, error) {
+	typeDefs := make(map[string]*core.TypeDef)
+	for _, p := range ports {
+		if strings.Contains(p.ref, "~") || strings.Contains(p.conns[0], "~") {
+			return core.TypeDef{}, fmt.Errorf("cannot cut connection from %s into a stream", p.ref)
+		}
+
+		ref := p.ref
+		if in {
+			ref = p.conns[0]
+		}
+		port, err := core.ParsePortReference(ref, op)
+		if err != nil {
+			return core.TypeDef{}, err
+		}
+		typeDef := port.Define()
+		if len(genericIdentifiers(&typeDef)) != 0 || typeDef.Type == "generic" {
+			return core.TypeDef{}, fmt.Errorf("cannot infer type of %s", ref)
+		}
+
+		key := p.key
+		for i := 2; typeDefs[key] != nil; i++ {
+			key = fmt.Sprintf("%s%d", p.key, i)
+		}
+		p.key = key
+		typeDefs[key] = &typeDef
+	}
+
+	switch len(ports) {
+	case 0:
+		return core.TypeDef{Type: "trigger"}, nil
+	case 1:
+		return *typeDefs[ports[0].key], nil
+	}
+	return core.TypeDef{Type: "map", Map: typeDefs}, nil
+}
+
+func genericIdentifiers(typeDef *core.TypeDef) []string {
+	if typeDef == nil {
+		return nil
+	}
+	switch typeDef.Type {
+	case "generic":
+		if typeDef.Generic != "" {
+			return []string{typeDef.Generic}
+		}
+	case "stream":
+		return genericIdentifiers(typeDef.Stream)
+	case "map":
+		var ids []string
+		for _, sub := range typeDef.Map {
+			ids = append(ids, genericIdentifiers(sub)...)
+		}
+		return ids
+	}
+	return nil
+}
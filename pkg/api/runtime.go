@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/Bitspark/go-funk"
+	"github.com/Bitspark/slang/pkg/core"
 	"io"
 	"net"
 	"strings"
@@ -15,6 +16,7 @@ import (
 type Commander interface {
 	Begin(action func(c Commands) error) error
 	Addr() string
+	Close() error
 }
 
 type Worker interface {
@@ -26,9 +28,115 @@ type Commands interface {
 	Hello() (string, error)
 	Init(a string) (string, error)
 	PrtCfg() (string, error)
+	Status() (string, error)
+	Drain(timeout time.Duration) (string, error)
+	Stop() (string, error)
 	Action() error
 }
 
+// CommandTimeout limits the time the commander waits for the reply to a command. Replies to drain commands may
+// additionally take as long as the drain timeout.
+var CommandTimeout = 10 * time.Second
+
+const (
+	WORKER_IDLE     = "idle"
+	WORKER_RUNNING  = "running"
+	WORKER_DRAINING = "draining"
+	WORKER_DRAINED  = "drained"
+	WORKER_STOPPED  = "stopped"
+)
+
+// WorkerStatus is the reply of a worker to the status command
+type WorkerStatus struct {
+	State    string   `json:"state"`
+	Running  bool     `json:"running"`
+	ItemsIn  int      `json:"itemsIn"`
+	ItemsOut int      `json:"itemsOut"`
+	InFlight int      `json:"inFlight"`
+	Buffered int      `json:"buffered"`
+	Errors   []string `json:"errors"`
+}
+
+func ParseWorkerStatus(msg string) (*WorkerStatus, error) {
+	var status WorkerStatus
+	if err := json.Unmarshal([]byte(msg), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// InFlight counts the items which have entered an operator through the primitive ports of its main in port but not
+// yet left it through those of its main out port. Each primitive port carries its share of every item, items of
+// streams are counted once their stream has ended.
+type InFlight struct {
+	mutex *sync.Mutex
+	ports map[*core.Port]*portItems
+}
+
+type portItems struct {
+	in    bool
+	depth int
+	open  int
+	items int
+}
+
+func NewInFlight(ports ...*core.Port) *InFlight {
+	f := &InFlight{mutex: &sync.Mutex{}, ports: make(map[*core.Port]*portItems)}
+	for _, p := range ports {
+		depth := 0
+		for str := p.ParentStream(); str != nil; str = str.ParentStream() {
+			depth++
+		}
+		f.ports[p] = &portItems{in: p.Direction() == core.DIRECTION_IN, depth: depth}
+	}
+	return f
+}
+
+// Count counts a value which has passed the port
+func (f *InFlight) Count(p *core.Port, v interface{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	pi, ok := f.ports[p]
+	if !ok {
+		return
+	}
+	if pi.depth != 0 {
+		switch v.(type) {
+		case core.BOS:
+			pi.open++
+			return
+		case core.EOS:
+			pi.open--
+		}
+		if pi.open > 0 {
+			return
+		}
+		pi.open = 0
+	}
+	pi.items++
+}
+
+// Items returns the number of items which have partially or completely entered but not completely left
+func (f *InFlight) Items() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	in, out := 0, -1
+	for _, pi := range f.ports {
+		if pi.in && pi.items > in {
+			in = pi.items
+		}
+		if !pi.in && (out == -1 || pi.items < out) {
+			out = pi.items
+		}
+	}
+	if out == -1 {
+		out = 0
+	}
+	return in - out
+}
+
 type cmdr struct {
 	addr string
 	ln   net.Listener
@@ -39,9 +147,10 @@ type wrkr struct {
 }
 
 type cmdrCmdsImpl struct {
-	wr     *bufio.Writer
-	rd     *bufio.Reader
+	wc     *WireConn
+	conn   net.Conn
 	action func(c Commands) error
+	mutex  *sync.Mutex
 }
 
 func NewCommander(addr string) Commander {
@@ -57,6 +166,10 @@ func (m *cmdr) Addr() string {
 	return m.addr
 }
 
+func (m *cmdr) Close() error {
+	return m.ln.Close()
+}
+
 func (m *wrkr) Addr() string {
 	return m.addr
 }
@@ -72,9 +185,10 @@ func (m *cmdr) Begin(action func(c Commands) error) error {
 
 			if err != nil {
 				errors <- err
+				return
 			}
 
-			c := &cmdrCmdsImpl{bufio.NewWriter(conn), bufio.NewReader(conn), action}
+			c := &cmdrCmdsImpl{NewWireConn(conn), conn, action, &sync.Mutex{}}
 			go func() {
 				if err := c.Action(); err != nil {
 					errors <- err
@@ -88,74 +202,108 @@ func (m *cmdr) Begin(action func(c Commands) error) error {
 
 func (m *wrkr) Begin(newWorkerCmds func() Commands) error {
 	errors := make(chan error, 1)
+	report := func(err error) {
+		// Only the first error ends the worker, later ones are dropped
+		select {
+		case errors <- err:
+		default:
+		}
+	}
+	done := make(chan bool)
+	defer close(done)
+
+	// The commands and thereby the operator outlive connections, a reconnecting commander finds them via hello
+	c := newWorkerCmds()
+	go func() {
+		// Action returns once the worker has been stopped
+		report(c.Action())
+	}()
 	go func() {
-		var wg sync.WaitGroup
-		wg.Add(1)
 		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
 			if conn, err := net.Dial("tcp", m.addr); err == nil {
-				c := newWorkerCmds()
-				go m.dispatch(conn, c, errors, &wg)
-				go func() {
-					if err := c.Action(); err != nil {
-						errors <- err
-					}
-				}()
-				wg.Wait()
-				wg.Add(1)
+				m.dispatch(conn, c, report)
 			}
 			time.Sleep(100 * time.Millisecond)
 		}
-
 	}()
 	return <-errors
 }
 
-func (m *wrkr) dispatch(conn net.Conn, c Commands, errors chan error, wg *sync.WaitGroup) {
-	wr := bufio.NewWriter(conn)
-	rd := bufio.NewReader(conn)
+func (m *wrkr) dispatch(conn net.Conn, c Commands, report func(error)) {
+	wc := NewWireConn(conn)
+	handshaken := false
 
-	defer wg.Done()
+	defer conn.Close()
 
 	for {
-		var msg string
-		var rmsg string
-		var err error
-
-		msg, err = Rdbuf(rd)
+		f, err := ReadFrame(wc.rd)
 
 		if err != nil {
 			if err != io.EOF {
-				errors <- err
+				report(err)
 			}
 			break
 		}
 
-		s := strings.SplitN(msg, " ", 2)
-
-		switch s[0] {
-		case "/hello":
-			rmsg, err = c.Hello()
-		case "/init":
-			rmsg, err = c.Init(s[1])
-		case "/ports":
-			rmsg, err = c.PrtCfg()
+		switch f.Type {
+		case FRAME_HELLO:
+			// Failed handshakes have been reported to the commander which may try again
+			handshaken = wc.acceptHello(f, c.Hello) == nil
+			continue
+		case FRAME_COMMAND:
 		default:
 			continue
 		}
 
-		if err != nil {
-			errors <- err
-			break
+		var cmd command
+		stopped := false
+		if !handshaken {
+			err = wc.WriteError(fmt.Errorf("handshake required"))
+		} else if cerr := json.Unmarshal(f.Payload, &cmd); cerr != nil {
+			err = wc.WriteError(cerr)
+		} else if rmsg, cerr := m.execute(c, cmd); cerr != nil {
+			err = wc.WriteError(cerr)
+		} else {
+			err = WriteFrame(wc.wr, Frame{FRAME_REPLY, []byte(rmsg)})
+			stopped = cmd.Cmd == "stop"
 		}
 
-		err = Wrbuf(wr, rmsg)
 		if err != nil {
-			errors <- err
+			report(err)
+			break
+		}
+		if stopped {
+			// The worker ends only after the commander has received the reply
+			conn.Close()
+			report(nil)
 			break
 		}
-
 	}
+}
 
+func (m *wrkr) execute(c Commands, cmd command) (string, error) {
+	switch cmd.Cmd {
+	case "init":
+		return c.Init(cmd.Arg)
+	case "ports":
+		return c.PrtCfg()
+	case "status":
+		return c.Status()
+	case "drain":
+		timeout, err := time.ParseDuration(cmd.Arg)
+		if err != nil {
+			return "", err
+		}
+		return c.Drain(timeout)
+	case "stop":
+		return c.Stop()
+	}
+	return "", fmt.Errorf("unknown command %s", cmd.Cmd)
 }
 
 func Wrbuf(wr *bufio.Writer, msg string) error {
@@ -213,28 +361,64 @@ func (c *cmdrCmdsImpl) Action() error {
 	return c.action(c)
 }
 
-func (c *cmdrCmdsImpl) Hello() (string, error) {
-	err := Wrbuf(c.wr, "/hello")
-
-	if err != nil {
-		return "", err
-	}
+// RemoteAddr returns the address of the worker
+func (c *cmdrCmdsImpl) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
 
-	return Rdbuf(c.rd)
+// Hello performs the handshake with the worker and returns the id of the operator it runs, if any
+func (c *cmdrCmdsImpl) Hello() (string, error) {
+	return c.wc.Handshake()
 }
 
 func (c *cmdrCmdsImpl) Init(a string) (string, error) {
-	if err := Wrbuf(c.wr, "/init "+a); err != nil {
-		return "", err
-	}
-	return Rdbuf(c.rd)
+	return c.command("init", a)
 }
 
 func (c *cmdrCmdsImpl) PrtCfg() (string, error) {
-	if err := Wrbuf(c.wr, "/ports"); err != nil {
+	return c.command("ports", "")
+}
+
+// Status returns the state of the worker and the number of items it has processed, see WorkerStatus
+func (c *cmdrCmdsImpl) Status() (string, error) {
+	return c.command("status", "")
+}
+
+// Drain makes the worker stop accepting input and waits until all items have left its output ports
+func (c *cmdrCmdsImpl) Drain(timeout time.Duration) (string, error) {
+	return c.commandTimeout("drain", timeout.String(), CommandTimeout+timeout)
+}
+
+// Stop stops the operator of the worker, which ends after replying
+func (c *cmdrCmdsImpl) Stop() (string, error) {
+	return c.command("stop", "")
+}
+
+func (c *cmdrCmdsImpl) command(cmd string, arg string) (string, error) {
+	return c.commandTimeout(cmd, arg, CommandTimeout)
+}
+
+func (c *cmdrCmdsImpl) commandTimeout(cmd string, arg string, timeout time.Duration) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := c.wc.writeJSON(FRAME_COMMAND, command{cmd, arg}); err != nil {
 		return "", err
 	}
-	return Rdbuf(c.rd)
+	f, err := c.wc.read()
+	if err, ok := err.(net.Error); ok && err.Timeout() {
+		return "", fmt.Errorf("%s: no reply within %s", cmd, timeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	if f.Type != FRAME_REPLY {
+		return "", fmt.Errorf("unexpected frame %d", f.Type)
+	}
+	return string(f.Payload), nil
 }
 
 type PortConnHandler interface {
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/stretchr/testify/require"
+)
+
+type testWrkCmds struct {
+	state string
+	delay time.Duration
+	done  chan bool
+}
+
+func newTestWrkCmds(delay time.Duration) func() Commands {
+	return func() Commands {
+		return &testWrkCmds{WORKER_IDLE, delay, make(chan bool)}
+	}
+}
+
+func (w *testWrkCmds) Hello() (string, error) {
+	return "", nil
+}
+
+func (w *testWrkCmds) Init(a string) (string, error) {
+	w.state = WORKER_RUNNING
+	return "{}", nil
+}
+
+func (w *testWrkCmds) PrtCfg() (string, error) {
+	return "{}", nil
+}
+
+func (w *testWrkCmds) Status() (string, error) {
+	b, err := json.Marshal(WorkerStatus{State: w.state, Running: w.state == WORKER_RUNNING, ItemsIn: 3})
+	return string(b), err
+}
+
+func (w *testWrkCmds) Drain(timeout time.Duration) (string, error) {
+	time.Sleep(w.delay)
+	w.state = WORKER_DRAINED
+	return w.Status()
+}
+
+func (w *testWrkCmds) Stop() (string, error) {
+	w.state = WORKER_STOPPED
+	return w.Status()
+}
+
+func (w *testWrkCmds) Action() error {
+	<-w.done
+	return nil
+}
+
+func TestRuntime_Lifecycle(t *testing.T) {
+	a := assertions.New(t)
+
+	cmdr := NewCommander("127.0.0.1:0")
+	defer cmdr.Close()
+
+	statuses := make(chan *WorkerStatus, 3)
+	go cmdr.Begin(func(c Commands) error {
+		c.Hello()
+		c.Init("{}")
+		for _, cmd := range []func() (string, error){c.Status, func() (string, error) { return c.Drain(time.Second) }, c.Stop} {
+			msg, err := cmd()
+			if err != nil {
+				return err
+			}
+			status, err := ParseWorkerStatus(msg)
+			if err != nil {
+				return err
+			}
+			statuses <- status
+		}
+		return nil
+	})
+
+	// The worker ends after having been stopped
+	a.NoError(NewWorker(cmdr.Addr()).Begin(newTestWrkCmds(0)))
+
+	status := <-statuses
+	a.Equal(WORKER_RUNNING, status.State)
+	a.True(status.Running)
+	a.Equal(3, status.ItemsIn)
+	a.Equal(WORKER_DRAINED, (<-statuses).State)
+	a.Equal(WORKER_STOPPED, (<-statuses).State)
+}
+
+func TestRuntime_Reconnect(t *testing.T) {
+	a := assertions.New(t)
+
+	cmdr := NewCommander("127.0.0.1:0")
+	defer cmdr.Close()
+
+	first := make(chan bool, 1)
+	first <- true
+	statuses := make(chan *WorkerStatus, 2)
+	go cmdr.Begin(func(c Commands) error {
+		c.Hello()
+		select {
+		case <-first:
+			// The worker dials again after the commander has dropped the connection
+			c.Init("{}")
+			return c.(*cmdrCmdsImpl).conn.Close()
+		default:
+		}
+		for _, cmd := range []func() (string, error){c.Status, c.Stop} {
+			msg, err := cmd()
+			if err != nil {
+				return err
+			}
+			status, err := ParseWorkerStatus(msg)
+			if err != nil {
+				return err
+			}
+			statuses <- status
+		}
+		return nil
+	})
+
+	created := 0
+	newWrkCmds := func() Commands {
+		created++
+		return newTestWrkCmds(0)()
+	}
+	a.NoError(NewWorker(cmdr.Addr()).Begin(newWrkCmds))
+
+	// The worker keeps its commands across connections
+	a.Equal(1, created)
+	a.Equal(WORKER_RUNNING, (<-statuses).State)
+	a.Equal(WORKER_STOPPED, (<-statuses).State)
+}
+
+func TestRuntime_CommandTimeout(t *testing.T) {
+	a := assertions.New(t)
+
+	timeout := CommandTimeout
+	CommandTimeout = 50 * time.Millisecond
+	defer func() { CommandTimeout = timeout }()
+
+	cmdr := NewCommander("127.0.0.1:0")
+	defer cmdr.Close()
+
+	errs := make(chan error, 1)
+	go cmdr.Begin(func(c Commands) error {
+		c.Hello()
+		_, err := c.Drain(50 * time.Millisecond)
+		errs <- err
+		return nil
+	})
+	go NewWorker(cmdr.Addr()).Begin(newTestWrkCmds(time.Second))
+
+	select {
+	case err := <-errs:
+		a.Error(err)
+		a.Contains(err.Error(), "no reply")
+	case <-time.After(time.Second):
+		t.Fatal("commander has not timed out")
+	}
+}
+
+func Test_InFlight(t *testing.T) {
+	a := assertions.New(t)
+
+	in, err := core.NewPort(nil, nil, core.TypeDef{Type: "stream", Stream: &core.TypeDef{Type: "number"}}, core.DIRECTION_IN)
+	require.NoError(t, err)
+	out, err := core.NewPort(nil, nil, core.TypeDef{Type: "number"}, core.DIRECTION_OUT)
+	require.NoError(t, err)
+
+	f := NewInFlight(in.Stream(), out)
+	a.Equal(0, f.Items())
+
+	str := in.Stream().ParentStream()
+	f.Count(in.Stream(), str.NewBOS())
+	a.Equal(0, f.Items())
+	f.Count(in.Stream(), 1.0)
+	f.Count(in.Stream(), 2.0)
+	f.Count(in.Stream(), str.NewEOS())
+	a.Equal(1, f.Items())
+
+	f.Count(in.Stream(), str.NewBOS())
+	f.Count(in.Stream(), str.NewEOS())
+	a.Equal(2, f.Items())
+
+	f.Count(out, 3.0)
+	a.Equal(1, f.Items())
+	f.Count(out, 0.0)
+	a.Equal(0, f.Items())
+}
@@ -19,6 +19,15 @@ func CreateAndConnectOperator(insName string, def core.OperatorDef, ordered bool
 
 	// Recursively create all child operators from top to bottom
 	for _, childOpInsDef := range def.InstanceDefs {
+		if childOpInsDef.OperatorDef.IsExternal() {
+			extOp, err := NewExternalOperator(*childOpInsDef)
+			if err != nil {
+				return nil, err
+			}
+			extOp.SetParent(o)
+			continue
+		}
+
 		if builtinOp, err := elem.MakeOperator(*childOpInsDef); err == nil {
 			// Builtin operator has been found
 			builtinOp.SetParent(o)
@@ -126,11 +135,19 @@ func Build(opId uuid.UUID, gens core.Generics, props core.Properties, st storage
 		return nil, err
 	}
 
+	props = opDef.ApplyPropertyDefaults(props)
 	err = specifyOperator(opDef, gens, props, st, []string{})
 	if err != nil {
 		return nil, err
 	}
 
+	// External operators are processes and have to be wrapped to be run by themselves
+	if opDef.IsExternal() {
+		if opDef, err = wrapExternal(*opDef, gens, props); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create and connect the operator
 	op, err := CreateAndConnectOperator("", *opDef, false)
 	if err != nil {
@@ -173,17 +190,16 @@ func specifyOperator(def *core.OperatorDef, gens core.Generics, props core.Prope
 
 	for _, childInsDef := range def.InstanceDefs {
 
-		// Load OperatorDef for childInsDef
+		// Load OperatorDef for childInsDef, resolving the pinned version if there is one
 		if childInsDef.OperatorDef.Id == "" {
-			childOpId, _ := uuid.Parse(childInsDef.Operator)
-			if childOpDef, err := st.Load(childOpId); err == nil {
+			if childOpDef, err := st.LoadRef(childInsDef.Operator); err == nil {
 				childInsDef.OperatorDef = *childOpDef
 			} else {
 				return err
 			}
 		}
 
-		if funk.ContainsString(dependenyChain, childInsDef.Operator) {
+		if childOpId, _ := core.SplitOperatorRef(childInsDef.Operator); funk.ContainsString(dependenyChain, childOpId) {
 			return fmt.Errorf("recursion in %s", def.Id)
 		}
 
@@ -205,6 +221,8 @@ func specifyOperator(def *core.OperatorDef, gens core.Generics, props core.Prope
 			}
 		}
 
+		childInsDef.Properties = childInsDef.OperatorDef.ApplyPropertyDefaults(childInsDef.Properties)
+
 		for _, gen := range childInsDef.Generics {
 			gen.SpecifyGenerics(gens)
 		}
@@ -217,6 +235,7 @@ func specifyOperator(def *core.OperatorDef, gens core.Generics, props core.Prope
 	}
 
 	def.PropertyDefs = nil
+	def.PropertyDefaults = nil
 
 	return nil
 }
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// SlangFileOptions control which arguments are embedded into a SlangFileDef and what is left out
+type SlangFileOptions struct {
+	// Generics and Properties are embedded as default arguments of the main operator
+	Generics   core.Generics
+	Properties core.Properties
+	// ShakeDelegates removes delegates which are connected neither inside their operator nor by any instance of it
+	ShakeDelegates bool
+	// ShakeTests removes all test cases
+	ShakeTests bool
+}
+
+// CreateSlangFile collects the operator and all non-elementary operators it depends on into a self-contained
+// SlangFileDef. Pinned versions are resolved, instances refer to the blueprints by id only, so an operator may only
+// be used in a single version.
+func CreateSlangFile(opId uuid.UUID, opts SlangFileOptions, st storage.Storage) (*core.SlangFileDef, error) {
+	blueprints := make(map[string]*core.OperatorDef)
+	versions := make(map[string]string)
+	var order []string
+
+	var collect func(ref string) error
+	collect = func(ref string) error {
+		idStr, constraint := core.SplitOperatorRef(ref)
+		opDef, err := st.LoadRef(ref)
+		if err != nil {
+			return err
+		}
+
+		v := ""
+		if constraint != "" {
+			v = opDef.Meta.Version
+		}
+		if other, ok := versions[idStr]; ok {
+			if other != v {
+				return fmt.Errorf("operator %s is used in different versions %q and %q", idStr, other, v)
+			}
+			return nil
+		}
+		versions[idStr] = v
+		blueprints[idStr] = opDef
+		order = append(order, idStr)
+
+		for _, insDef := range opDef.InstanceDefs {
+			if elem.IsRegistered(insDef.Operator) {
+				continue
+			}
+			if err := collect(insDef.Operator); err != nil {
+				return fmt.Errorf("%s: instance %s: %s", opDef.Id, insDef.Name, err)
+			}
+			insDef.Operator, _ = core.SplitOperatorRef(insDef.Operator)
+		}
+		return nil
+	}
+	if err := collect(opId.String()); err != nil {
+		return nil, err
+	}
+
+	if opts.ShakeDelegates {
+		shakeDelegates(blueprints)
+	}
+
+	slFile := &core.SlangFileDef{Main: opId.String()}
+	slFile.Args.Generics = opts.Generics
+	slFile.Args.Properties = opts.Properties
+	for _, id := range order {
+		opDef := blueprints[id]
+		if opts.ShakeTests {
+			opDef.TestCases = nil
+		}
+		slFile.Blueprints = append(slFile.Blueprints, *opDef)
+	}
+
+	if err := slFile.Validate(); err != nil {
+		return nil, err
+	}
+	return slFile, nil
+}
+
+// shakeDelegates removes delegates no connection refers to
+func shakeDelegates(blueprints map[string]*core.OperatorDef) {
+	used := make(map[string]bool)
+	for id, opDef := range blueprints {
+		operators := make(map[string]string)
+		for _, insDef := range opDef.InstanceDefs {
+			operators[insDef.Name] = insDef.Operator
+		}
+		use := func(ref string) {
+			ins, dlg := refDelegate(ref)
+			if dlg == "" {
+				return
+			}
+			if ins == "" {
+				used[id+"."+dlg] = true
+			} else if opId, ok := operators[ins]; ok {
+				used[opId+"."+dlg] = true
+			}
+		}
+		for src, dsts := range opDef.Connections {
+			use(src)
+			for _, dst := range dsts {
+				use(dst)
+			}
+		}
+	}
+
+	for id, opDef := range blueprints {
+		for dlg := range opDef.DelegateDefs {
+			if !used[id+"."+dlg] {
+				delete(opDef.DelegateDefs, dlg)
+			}
+		}
+	}
+}
+
+// refDelegate returns the instance and delegate a port reference points to, e.g. "del" and "cmp" for "(del.cmp"
+func refDelegate(ref string) (string, string) {
+	opPart := ""
+	if i := strings.Index(ref, "("); i != -1 {
+		opPart = ref[i+1:]
+	} else if i := strings.Index(ref, ")"); i != -1 {
+		opPart = ref[:i]
+	}
+	if strings.Contains(opPart, "@") {
+		return "", ""
+	}
+	if i := strings.Index(opPart, "."); i != -1 {
+		return opPart[:i], opPart[i+1:]
+	}
+	return "", ""
+}
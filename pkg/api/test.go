@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -55,7 +56,7 @@ func (t TestBench) Run(opId uuid.UUID, writer io.Writer, failFast bool) (int, in
 		}
 
 		o.Main().Out().Bufferize()
-		o.Start()
+		o.Start(context.Background())
 
 		success := true
 
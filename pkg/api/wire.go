@@ -0,0 +1,474 @@
+package api
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// PROTOCOL_VERSION is the version of the wire protocol spoken between slang and slangr. Peers with different
+// versions refuse to talk to each other.
+const PROTOCOL_VERSION = 1
+
+// MAX_FRAME_SIZE limits the payload of a single frame
+const MAX_FRAME_SIZE = 64 << 20
+
+// Frames consist of a 4 byte big endian payload length, a type byte and the payload
+const (
+	FRAME_HELLO byte = iota + 1
+	FRAME_COMMAND
+	FRAME_REPLY
+	FRAME_DATA
+	FRAME_ERROR
+)
+
+const (
+	ENCODING_JSON = "json"
+	ENCODING_CBOR = "cbor"
+)
+
+var Encodings = []string{ENCODING_CBOR, ENCODING_JSON}
+
+// CBOR tags for values which have no CBOR representation
+const (
+	cborTagBOS = 51001
+	cborTagEOS = 51002
+	cborTagPH  = 51003
+)
+
+// JSON keys of objects representing values which have no JSON representation. Keys of ordinary maps starting with $
+// are escaped by another $.
+const (
+	jsonKeyBinary = "$binary"
+	jsonKeyBOS    = "$bos"
+	jsonKeyEOS    = "$eos"
+	jsonKeyPH     = "$ph"
+)
+
+type Frame struct {
+	Type    byte
+	Payload []byte
+}
+
+// RemoteError is an error reported by the peer
+type RemoteError struct {
+	Msg string
+}
+
+func (e *RemoteError) Error() string {
+	return "remote: " + e.Msg
+}
+
+// DecodeError is returned for data frames which could not be decoded. The connection remains usable.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "cannot decode value: " + e.Err.Error()
+}
+
+type hello struct {
+	Version   int      `json:"version"`
+	Encodings []string `json:"encodings,omitempty"`
+	Encoding  string   `json:"encoding,omitempty"`
+	Id        string   `json:"id,omitempty"`
+}
+
+type command struct {
+	Cmd string `json:"cmd"`
+	Arg string `json:"arg,omitempty"`
+}
+
+func WriteFrame(wr *bufio.Writer, f Frame) error {
+	if len(f.Payload) > MAX_FRAME_SIZE {
+		return fmt.Errorf("frame of %d bytes exceeds maximum size", len(f.Payload))
+	}
+	var header [5]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(f.Payload)))
+	header[4] = f.Type
+	if _, err := wr.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := wr.Write(f.Payload); err != nil {
+		return err
+	}
+	return wr.Flush()
+}
+
+func ReadFrame(rd *bufio.Reader) (Frame, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(rd, header[:]); err != nil {
+		return Frame{}, err
+	}
+	size := binary.BigEndian.Uint32(header[:4])
+	if size > MAX_FRAME_SIZE {
+		return Frame{}, fmt.Errorf("frame of %d bytes exceeds maximum size", size)
+	}
+	f := Frame{header[4], make([]byte, size)}
+	if _, err := io.ReadFull(rd, f.Payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+	return f, nil
+}
+
+// WireConn speaks the framed protocol over a connection. Data frames are encoded with the encoding negotiated during
+// the handshake.
+type WireConn struct {
+	Encoding string
+	rd       *bufio.Reader
+	wr       *bufio.Writer
+}
+
+func NewWireConn(rw io.ReadWriter) *WireConn {
+	return &WireConn{ENCODING_JSON, bufio.NewReader(rw), bufio.NewWriter(rw)}
+}
+
+func (c *WireConn) writeJSON(t byte, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return WriteFrame(c.wr, Frame{t, b})
+}
+
+// read returns the next frame, error frames are returned as RemoteError
+func (c *WireConn) read() (Frame, error) {
+	f, err := ReadFrame(c.rd)
+	if err != nil {
+		return f, err
+	}
+	if f.Type == FRAME_ERROR {
+		return f, &RemoteError{string(f.Payload)}
+	}
+	return f, nil
+}
+
+// Handshake offers the encodings to the peer, which answers with the one it has chosen
+func (c *WireConn) Handshake(encodings ...string) (string, error) {
+	if len(encodings) == 0 {
+		encodings = Encodings
+	}
+	if err := c.writeJSON(FRAME_HELLO, hello{Version: PROTOCOL_VERSION, Encodings: encodings}); err != nil {
+		return "", err
+	}
+
+	f, err := c.read()
+	if err != nil {
+		return "", err
+	}
+	if f.Type != FRAME_HELLO {
+		return "", fmt.Errorf("unexpected frame %d during handshake", f.Type)
+	}
+	var h hello
+	if err := json.Unmarshal(f.Payload, &h); err != nil {
+		return "", err
+	}
+	if h.Version != PROTOCOL_VERSION {
+		return "", fmt.Errorf("peer speaks protocol version %d, expected %d", h.Version, PROTOCOL_VERSION)
+	}
+	if !supportsEncoding(encodings, h.Encoding) {
+		return "", fmt.Errorf("peer chose unknown encoding %s", h.Encoding)
+	}
+	c.Encoding = h.Encoding
+	return h.Id, nil
+}
+
+// Accept answers the handshake of the peer. The id is passed to the peer.
+func (c *WireConn) Accept(id string) error {
+	f, err := c.read()
+	if err != nil {
+		return err
+	}
+	if f.Type != FRAME_HELLO {
+		c.WriteError(fmt.Errorf("handshake required"))
+		return fmt.Errorf("unexpected frame %d during handshake", f.Type)
+	}
+	return c.acceptHello(f, func() (string, error) { return id, nil })
+}
+
+func (c *WireConn) acceptHello(f Frame, id func() (string, error)) error {
+	var h hello
+	if err := json.Unmarshal(f.Payload, &h); err != nil {
+		c.WriteError(err)
+		return err
+	}
+	if h.Version != PROTOCOL_VERSION {
+		err := fmt.Errorf("protocol version %d is not supported, expected %d", h.Version, PROTOCOL_VERSION)
+		c.WriteError(err)
+		return err
+	}
+
+	encoding := ""
+	for _, e := range h.Encodings {
+		if supportsEncoding(Encodings, e) {
+			encoding = e
+			break
+		}
+	}
+	if encoding == "" {
+		err := fmt.Errorf("none of the encodings %s is supported", strings.Join(h.Encodings, ", "))
+		c.WriteError(err)
+		return err
+	}
+
+	idStr, err := id()
+	if err != nil {
+		c.WriteError(err)
+		return err
+	}
+
+	c.Encoding = encoding
+	return c.writeJSON(FRAME_HELLO, hello{Version: PROTOCOL_VERSION, Encoding: encoding, Id: idStr})
+}
+
+func supportsEncoding(encodings []string, encoding string) bool {
+	for _, e := range encodings {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteError reports an error to the peer
+func (c *WireConn) WriteError(err error) error {
+	return WriteFrame(c.wr, Frame{FRAME_ERROR, []byte(err.Error())})
+}
+
+// WriteValue sends a value in a data frame
+func (c *WireConn) WriteValue(v interface{}) error {
+	b, err := EncodeValue(c.Encoding, v)
+	if err != nil {
+		return err
+	}
+	return WriteFrame(c.wr, Frame{FRAME_DATA, b})
+}
+
+// ReadValue receives the value of the next data frame. Values which cannot be decoded are reported back to the peer
+// and returned as DecodeError, errors reported by the peer as RemoteError.
+func (c *WireConn) ReadValue() (interface{}, error) {
+	f, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if f.Type != FRAME_DATA {
+		return nil, fmt.Errorf("unexpected frame %d", f.Type)
+	}
+	v, err := DecodeValue(c.Encoding, f.Payload)
+	if err != nil {
+		c.WriteError(fmt.Errorf("cannot decode value: %s", err))
+		return nil, &DecodeError{err}
+	}
+	return v, nil
+}
+
+// EncodeValue encodes a value including binaries, stream markers and placeholders
+func EncodeValue(encoding string, v interface{}) ([]byte, error) {
+	switch encoding {
+	case ENCODING_JSON:
+		return json.Marshal(toJSONValue(v))
+	case ENCODING_CBOR:
+		return cbor.Marshal(toCBORValue(v))
+	}
+	return nil, fmt.Errorf("unknown encoding %s", encoding)
+}
+
+// DecodeValue decodes a value encoded by EncodeValue. Numbers are always decoded as float64 and stream markers are
+// not bound to a port.
+func DecodeValue(encoding string, b []byte) (interface{}, error) {
+	var v interface{}
+	switch encoding {
+	case ENCODING_JSON:
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		return fromJSONValue(v)
+	case ENCODING_CBOR:
+		if err := cbor.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		return fromCBORValue(v)
+	}
+	return nil, fmt.Errorf("unknown encoding %s", encoding)
+}
+
+func toJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case core.Binary:
+		return map[string]interface{}{jsonKeyBinary: base64.StdEncoding.EncodeToString(v)}
+	case core.BOS:
+		return map[string]interface{}{jsonKeyBOS: true}
+	case core.EOS:
+		return map[string]interface{}{jsonKeyEOS: true}
+	case *core.PH:
+		return map[string]interface{}{jsonKeyPH: v.String()}
+	case []interface{}:
+		vs := make([]interface{}, len(v))
+		for i, e := range v {
+			vs[i] = toJSONValue(e)
+		}
+		return vs
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if strings.HasPrefix(k, "$") {
+				k = "$" + k
+			}
+			m[k] = toJSONValue(e)
+		}
+		return m
+	}
+	return v
+}
+
+func fromJSONValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		for i, e := range v {
+			var err error
+			if v[i], err = fromJSONValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[string]interface{}:
+		if len(v) == 1 {
+			if b, ok := v[jsonKeyBinary]; ok {
+				s, _ := b.(string)
+				decoded, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return nil, fmt.Errorf("invalid binary: %s", err)
+				}
+				return core.Binary(decoded), nil
+			}
+			if _, ok := v[jsonKeyBOS]; ok {
+				return core.BOS{}, nil
+			}
+			if _, ok := v[jsonKeyEOS]; ok {
+				return core.EOS{}, nil
+			}
+			if ph, ok := v[jsonKeyPH]; ok {
+				return parsePH(ph)
+			}
+		}
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if strings.HasPrefix(k, "$$") {
+				k = k[1:]
+			} else if strings.HasPrefix(k, "$") {
+				return nil, fmt.Errorf("unknown key %s", k)
+			}
+			var err error
+			if m[k], err = fromJSONValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return v, nil
+}
+
+func toCBORValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case core.Binary:
+		return []byte(v)
+	case core.BOS:
+		return cbor.Tag{Number: cborTagBOS}
+	case core.EOS:
+		return cbor.Tag{Number: cborTagEOS}
+	case *core.PH:
+		return cbor.Tag{Number: cborTagPH, Content: v.String()}
+	case []interface{}:
+		vs := make([]interface{}, len(v))
+		for i, e := range v {
+			vs[i] = toCBORValue(e)
+		}
+		return vs
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = toCBORValue(e)
+		}
+		return m
+	}
+	return v
+}
+
+func fromCBORValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case []byte:
+		return core.Binary(v), nil
+	case uint64:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case cbor.Tag:
+		switch v.Number {
+		case cborTagBOS:
+			return core.BOS{}, nil
+		case cborTagEOS:
+			return core.EOS{}, nil
+		case cborTagPH:
+			return parsePH(v.Content)
+		}
+		return nil, fmt.Errorf("unknown tag %d", v.Number)
+	case []interface{}:
+		for i, e := range v {
+			var err error
+			if v[i], err = fromCBORValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("map key %v is not a string", k)
+			}
+			var err error
+			if m[ks], err = fromCBORValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return v, nil
+}
+
+// BindMarker binds stream markers received from the wire to the stream the port belongs to
+func BindMarker(p *core.Port, v interface{}) interface{} {
+	str := p.ParentStream()
+	if str == nil {
+		return v
+	}
+	switch v.(type) {
+	case core.BOS:
+		return str.NewBOS()
+	case core.EOS:
+		return str.NewEOS()
+	}
+	return v
+}
+
+func parsePH(v interface{}) (*core.PH, error) {
+	s, _ := v.(string)
+	if ph := core.ParsePH(s); ph != nil {
+		return ph, nil
+	}
+	return nil, fmt.Errorf("invalid placeholder %v", v)
+}
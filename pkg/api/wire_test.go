@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/stretchr/testify/require"
+)
+
+var wireValues = []interface{}{
+	nil,
+	true,
+	1.5,
+	"text",
+	core.Binary{0, 1, 2, 255},
+	core.BOS{},
+	core.EOS{},
+	core.PHSingle,
+	core.PHMultiple,
+	[]interface{}{1.0, "a", core.Binary("b"), []interface{}{}},
+	map[string]interface{}{"a": 1.0, "$b": core.Binary("c"), "$$d": map[string]interface{}{"e": false}},
+	map[string]interface{}{"$binary": "not a binary"},
+}
+
+func TestWire_RoundTrip(t *testing.T) {
+	for _, encoding := range Encodings {
+		t.Run(encoding, func(t *testing.T) {
+			a := assertions.New(t)
+			for _, v := range wireValues {
+				b, err := EncodeValue(encoding, v)
+				require.NoError(t, err)
+				decoded, err := DecodeValue(encoding, b)
+				require.NoError(t, err)
+				a.Equal(v, decoded)
+			}
+		})
+	}
+}
+
+func TestWire_DecodeNumbers(t *testing.T) {
+	a := assertions.New(t)
+	b, err := EncodeValue(ENCODING_CBOR, []interface{}{1, -2, float32(0.5)})
+	require.NoError(t, err)
+	decoded, err := DecodeValue(ENCODING_CBOR, b)
+	require.NoError(t, err)
+	a.Equal([]interface{}{1.0, -2.0, 0.5}, decoded)
+}
+
+func TestWire_Frames(t *testing.T) {
+	a := assertions.New(t)
+	buf := new(bytes.Buffer)
+	wr := bufio.NewWriter(buf)
+	require.NoError(t, WriteFrame(wr, Frame{FRAME_DATA, []byte("first")}))
+	require.NoError(t, WriteFrame(wr, Frame{FRAME_REPLY, nil}))
+
+	rd := bufio.NewReader(buf)
+	f, err := ReadFrame(rd)
+	require.NoError(t, err)
+	a.Equal(Frame{FRAME_DATA, []byte("first")}, f)
+	f, err = ReadFrame(rd)
+	require.NoError(t, err)
+	a.Equal(FRAME_REPLY, f.Type)
+	a.Len(f.Payload, 0)
+
+	// Truncated frames
+	_, err = ReadFrame(bufio.NewReader(bytes.NewReader([]byte{0, 0, 0, 5, FRAME_DATA, 'a'})))
+	a.Error(err)
+}
+
+func TestWire_Handshake(t *testing.T) {
+	a := assertions.New(t)
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	sc := NewWireConn(server)
+	accepted := make(chan error, 1)
+	go func() { accepted <- sc.Accept("op") }()
+
+	cc := NewWireConn(client)
+	id, err := cc.Handshake(ENCODING_JSON)
+	require.NoError(t, err)
+	require.NoError(t, <-accepted)
+	a.Equal("op", id)
+	a.Equal(ENCODING_JSON, cc.Encoding)
+	a.Equal(ENCODING_JSON, sc.Encoding)
+
+	// Values which cannot be decoded are reported back to the sender
+	reported := make(chan error, 1)
+	go func() {
+		_, err := cc.ReadValue()
+		reported <- err
+	}()
+	go func() {
+		WriteFrame(cc.wr, Frame{FRAME_DATA, []byte("{invalid")})
+		cc.WriteValue(core.Binary("ok"))
+	}()
+	_, err = sc.ReadValue()
+	a.IsType(&DecodeError{}, err)
+	v, err := sc.ReadValue()
+	require.NoError(t, err)
+	a.Equal(core.Binary("ok"), v)
+	a.IsType(&RemoteError{}, <-reported)
+}
+
+func TestWire_HandshakeVersionMismatch(t *testing.T) {
+	a := assertions.New(t)
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	sc := NewWireConn(server)
+	accepted := make(chan error, 1)
+	go func() { accepted <- sc.Accept("") }()
+
+	cc := NewWireConn(client)
+	b, _ := json.Marshal(hello{Version: PROTOCOL_VERSION + 1, Encodings: Encodings})
+	require.NoError(t, WriteFrame(cc.wr, Frame{FRAME_HELLO, b}))
+	_, err := cc.read()
+	a.IsType(&RemoteError{}, err)
+	a.Error(<-accepted)
+}
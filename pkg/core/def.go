@@ -58,6 +58,7 @@ type TestCaseDef struct {
 
 type OperatorMetaDef struct {
 	Name             string   `json:"name" yaml:"name"`
+	Version          string   `json:"version,omitempty" yaml:"version,omitempty"`
 	Icon             string   `json:"icon" yaml:"icon"`
 	ShortDescription string   `json:"shortDescription" yaml:"shortDescription"`
 	Description      string   `json:"description" yaml:"description"`
@@ -67,6 +68,9 @@ type OperatorMetaDef struct {
 	valid bool
 }
 
+// ELEMENTARY_EXTERNAL marks operators implemented by an external process
+const ELEMENTARY_EXTERNAL = "external"
+
 type OperatorDef struct {
 	Id string `json:"id" yaml:"id"`
 
@@ -77,6 +81,13 @@ type OperatorDef struct {
 	Connections  map[string][]string     `json:"connections,omitempty" yaml:"connections,omitempty"`
 	Elementary   string                  `json:"-" yaml:"-"`
 
+	// PropertyDefaults holds the values of properties which are optional, see ApplyPropertyDefaults
+	PropertyDefaults Properties `json:"propertyDefaults,omitempty" yaml:"propertyDefaults,omitempty"`
+
+	// ElementaryKind is ELEMENTARY_EXTERNAL for operators implemented by an external process, see ExternalDef
+	ElementaryKind string       `json:"elementary,omitempty" yaml:"elementary,omitempty"`
+	External       *ExternalDef `json:"external,omitempty" yaml:"external,omitempty"`
+
 	Meta      OperatorMetaDef `json:"meta" yaml:"meta"`
 	TestCases []TestCaseDef   `json:"tests,omitempty" yaml:"tests,omitempty"`
 
@@ -90,6 +101,14 @@ type OperatorDef struct {
 	valid bool
 }
 
+// ExternalDef holds the command of an operator implemented by an external process. Environment variables in the
+// command and the directory are expanded.
+type ExternalDef struct {
+	Command []string          `json:"command" yaml:"command"`
+	Dir     string            `json:"dir,omitempty" yaml:"dir,omitempty"`
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+}
+
 type DelegateDef struct {
 	In  TypeDef `json:"in" yaml:"in"`
 	Out TypeDef `json:"out" yaml:"out"`
@@ -118,6 +137,19 @@ type TypeDef struct {
 	valid bool
 }
 
+// EXTERNAL DEFINITION
+
+func (d *ExternalDef) Copy() *ExternalDef {
+	if d == nil {
+		return nil
+	}
+	env := make(map[string]string, len(d.Env))
+	for k, v := range d.Env {
+		env[k] = v
+	}
+	return &ExternalDef{append([]string{}, d.Command...), d.Dir, env}
+}
+
 // INSTANCE DEFINITION
 
 func (d InstanceDef) Valid() bool {
@@ -137,18 +169,31 @@ func (d *InstanceDef) Validate() error {
 		return errors.New(`operator may not be empty`)
 	}
 
-	if strings.Contains(d.Operator, " ") {
+	// Version constraints such as ">= 1.2, < 2" may contain spaces
+	opId, constraint := SplitOperatorRef(d.Operator)
+	if strings.Contains(opId, " ") {
 		return fmt.Errorf(`operator may not contain spaces: "%s"`, d.Operator)
 	}
-
-	if _, err := uuid.Parse(d.Operator); err != nil {
+	if _, err := uuid.Parse(opId); err != nil {
 		return fmt.Errorf(`operator id is not a valid UUID v4: "%s" --> "%s"`, d.Operator, err)
 	}
+	if strings.Contains(d.Operator, "@") && constraint == "" {
+		return fmt.Errorf(`operator version may not be empty: "%s"`, d.Operator)
+	}
 
 	d.valid = true
 	return nil
 }
 
+// SplitOperatorRef splits an operator reference of the form id@version into id and version constraint. The
+// constraint is empty in case the reference is not pinned to a version.
+func SplitOperatorRef(ref string) (string, string) {
+	if i := strings.Index(ref, "@"); i != -1 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
 func (d InstanceDef) Copy(recursive bool) InstanceDef {
 	var properties Properties = nil
 	if d.Properties != nil {
@@ -215,6 +260,20 @@ func (d *OperatorDef) Validate() error {
 		}
 	}
 
+	if err := d.validateExternal(); err != nil {
+		return err
+	}
+
+	for prop, val := range d.PropertyDefaults {
+		propDef, ok := d.PropertyDefs[prop]
+		if !ok {
+			return fmt.Errorf(`default for unknown property "%s"`, prop)
+		}
+		if err := propDef.VerifyData(val); err != nil {
+			return fmt.Errorf(`default of property "%s": %s`, prop, err)
+		}
+	}
+
 	alreadyUsedInsNames := make(map[string]bool)
 	for _, insDef := range d.InstanceDefs {
 		if err := insDef.Validate(); err != nil {
@@ -231,6 +290,45 @@ func (d *OperatorDef) Validate() error {
 	return nil
 }
 
+func (d *OperatorDef) validateExternal() error {
+	if d.ElementaryKind == "" {
+		if d.External != nil {
+			return fmt.Errorf(`external operators require "elementary: %s"`, ELEMENTARY_EXTERNAL)
+		}
+		return nil
+	}
+	if d.ElementaryKind != ELEMENTARY_EXTERNAL {
+		return fmt.Errorf(`unknown elementary kind "%s"`, d.ElementaryKind)
+	}
+	if d.External == nil || len(d.External.Command) == 0 {
+		return errors.New("external operator requires a command")
+	}
+	if len(d.InstanceDefs) != 0 || len(d.Connections) != 0 {
+		return errors.New("external operator must not contain instances or connections")
+	}
+	return nil
+}
+
+// ApplyPropertyDefaults returns the properties completed by the defaults of the properties which are missing. The
+// properties are changed in place unless they are nil.
+func (d OperatorDef) ApplyPropertyDefaults(props Properties) Properties {
+	for prop, val := range d.PropertyDefaults {
+		if _, ok := props[prop]; ok {
+			continue
+		}
+		if props == nil {
+			props = Properties{}
+		}
+		props[prop] = val
+	}
+	return props
+}
+
+// IsExternal returns true for operators implemented by an external process
+func (d OperatorDef) IsExternal() bool {
+	return d.ElementaryKind == ELEMENTARY_EXTERNAL
+}
+
 // SpecifyGenerics replaces generic types in the operator definition with the types given in the generics map.
 // The values of the map are the according identifiers. It does not touch referenced values such as *TypeDef but
 // replaces them with a reference on a copy.
@@ -316,6 +414,14 @@ func (d OperatorDef) Copy(recursive bool) OperatorDef {
 		propDefs[k] = &c
 	}
 
+	var propDefaults Properties = nil
+	if d.PropertyDefaults != nil {
+		propDefaults = Properties{}
+		for k, v := range d.PropertyDefaults {
+			propDefaults[k] = v
+		}
+	}
+
 	var connDefs map[string][]string = nil
 	var insDefs InstanceDefList = nil
 
@@ -344,6 +450,9 @@ func (d OperatorDef) Copy(recursive bool) OperatorDef {
 		propDefs,
 		connDefs,
 		d.Elementary,
+		propDefaults,
+		d.ElementaryKind,
+		d.External.Copy(),
 		d.Meta,
 		d.TestCases,
 		d.Geometry,
@@ -365,6 +474,7 @@ func (def *OperatorDef) SpecifyOperator(gens Generics, props Properties) error {
 	}
 
 	def.PropertyDefs = nil
+	def.PropertyDefaults = nil
 
 	return nil
 }
@@ -921,16 +1031,38 @@ func (sf *SlangFileDef) Validate() error {
 
 func expandExpressionPart(exprPart string, props Properties, propDefs map[string]*TypeDef) ([]string, error) {
 	var vals []string
-	prop, ok := props[exprPart]
+
+	// Expressions such as {routes.name} access an entry of each map contained in a stream property
+	propName := exprPart
+	entry := ""
+	if dot := strings.Index(exprPart, "."); dot != -1 {
+		propName = exprPart[:dot]
+		entry = exprPart[dot+1:]
+	}
+
+	prop, ok := props[propName]
 	if !ok {
-		return nil, errors.New("missing property " + exprPart)
+		return nil, errors.New("missing property " + propName)
 	}
-	propDef := propDefs[exprPart]
+	propDef := propDefs[propName]
 	if propDef.Type == "stream" {
-		els := prop.([]interface{})
+		els, _ := prop.([]interface{})
 		for _, el := range els {
+			if entry != "" {
+				elMap, ok := el.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("cannot access %s of %v", entry, el)
+				}
+				el = elMap[entry]
+			}
 			vals = append(vals, fmt.Sprintf("%v", el))
 		}
+	} else if entry != "" {
+		propMap, ok := prop.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot access %s of %v", entry, prop)
+		}
+		vals = []string{fmt.Sprintf("%v", propMap[entry])}
 	} else {
 		vals = []string{fmt.Sprintf("%v", prop)}
 	}
@@ -1,10 +1,13 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"github.com/google/uuid"
 	"log"
+	"sync"
+
+	"github.com/google/uuid"
 )
 
 type OFunc func(op *Operator)
@@ -26,7 +29,10 @@ type Operator struct {
 	properties  Properties
 	connectFunc CFunc
 	elementary  string
-	stopChannel chan bool
+	external    *ExternalDef
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mutex       *sync.Mutex
 	stopped     bool
 }
 
@@ -54,9 +60,11 @@ func NewOperator(name string, f OFunc, c CFunc, gens Generics, props Properties,
 	o.connectFunc = c
 	o.name = name
 	o.elementary = def.Elementary
+	o.external = def.External
 	o.generics = gens
 	o.properties = props
 	o.children = make(map[string]*Operator)
+	o.mutex = &sync.Mutex{}
 
 	var err error
 	if err := def.PropertyDefs.GenericsSpecified(); err != nil {
@@ -140,9 +148,12 @@ func (o *Operator) Child(name string) *Operator {
 	return c
 }
 
-func (o *Operator) Start() {
-	o.stopChannel = make(chan bool, 1)
+// Start starts the operator and all of its children. The operator stops once ctx is done or Stop is called.
+func (o *Operator) Start(ctx context.Context) {
+	o.mutex.Lock()
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	o.stopped = false
+	o.mutex.Unlock()
 
 	for _, srv := range o.services {
 		srv.outPort.Open()
@@ -163,18 +174,32 @@ func (o *Operator) Start() {
 		}()
 	} else {
 		for _, c := range o.children {
-			c.Start()
+			c.Start(o.ctx)
 		}
 	}
+
+	if o.parent == nil {
+		// Children are stopped along with their parent
+		ctx := o.ctx
+		go func() {
+			<-ctx.Done()
+			o.Stop()
+		}()
+	}
 }
 
+// Stop cancels the context of the operator and closes its out ports, stopping its children and its parent as well.
 func (o *Operator) Stop() {
+	o.mutex.Lock()
 	if o.stopped {
+		o.mutex.Unlock()
 		return
 	}
-
-	o.stopChannel <- true
 	o.stopped = true
+	if o.cancel != nil {
+		o.cancel()
+	}
+	o.mutex.Unlock()
 
 	for _, srv := range o.services {
 		srv.outPort.Close()
@@ -192,22 +217,60 @@ func (o *Operator) Stop() {
 	}
 }
 
-func (o *Operator) WaitForStop() {
-	<-o.stopChannel
-	o.stopChannel <- true
+// Context returns the context of the running operator, which is done once the operator has been stopped. Builtins
+// should release their resources when it is done.
+func (o *Operator) Context() context.Context {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// Done returns a channel which is closed once the operator has been stopped, nil if it has not been started yet
+func (o *Operator) Done() <-chan struct{} {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.ctx == nil {
+		return nil
+	}
+	return o.ctx.Done()
 }
 
+// CheckStop reports whether the operator has been stopped without blocking
 func (o *Operator) CheckStop() bool {
 	select {
-	case <-o.stopChannel:
+	case <-o.Done():
 		return true
 	default:
 		return false
 	}
 }
 
+// Stopped reports whether the operator has been stopped or its context is done
 func (o *Operator) Stopped() bool {
-	return o.stopped
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.stopped || o.ctx != nil && o.ctx.Err() != nil
+}
+
+// Buffered returns the number of items waiting in the buffers of the ports of the operator and its children
+func (o *Operator) Buffered() int {
+	n := 0
+	for _, srv := range o.services {
+		n += srv.inPort.Buffered() + srv.outPort.Buffered()
+	}
+	for _, dlg := range o.delegates {
+		n += dlg.inPort.Buffered() + dlg.outPort.Buffered()
+	}
+	for _, c := range o.children {
+		n += c.Buffered()
+	}
+	return n
 }
 
 func (o *Operator) Builtin() bool {
@@ -293,6 +356,10 @@ func (o *Operator) Define() (OperatorDef, error) {
 	def.DelegateDefs = make(map[string]*DelegateDef)
 	def.Connections = make(map[string][]string)
 	def.InstanceDefs = InstanceDefList{}
+	if o.external != nil {
+		def.ElementaryKind = ELEMENTARY_EXTERNAL
+		def.External = o.external
+	}
 
 	for insName, child := range o.children {
 		insDef := &InstanceDef{}
@@ -274,15 +274,16 @@ func (p *Port) Connected(q *Port) bool {
 
 // Opens the port by opening all channels
 func (p *Port) Open() {
-	if !p.closed {
+	if !p.isClosed() {
 		return
 	}
 
+	p.mutex.Lock()
 	p.closed = false
-
 	if p.buf != nil {
 		p.buf = make(chan interface{}, CHANNEL_SIZE)
 	}
+	p.mutex.Unlock()
 
 	if p.sub != nil {
 		p.sub.Open()
@@ -295,17 +296,16 @@ func (p *Port) Open() {
 	}
 }
 
-// Closes the port by closing all channels
+// Closes the port so that pushed items are dropped. The buffer is left open as pushers may still be sending to it,
+// pullers are released by the operator having been stopped.
 func (p *Port) Close() {
+	p.mutex.Lock()
 	if p.closed {
+		p.mutex.Unlock()
 		return
 	}
-
 	p.closed = true
-
-	if p.buf != nil {
-		close(p.buf)
-	}
+	p.mutex.Unlock()
 
 	if p.sub != nil {
 		p.sub.Close()
@@ -422,7 +422,7 @@ func (p *Port) assertChannelSpace() {
 
 // Push an item to this port.
 func (p *Port) Push(item interface{}) {
-	if p.closed {
+	if p.isClosed() {
 		return
 	}
 
@@ -434,7 +434,10 @@ func (p *Port) Push(item interface{}) {
 			p.buf <- item
 			p.mutex.Unlock()
 		} else {
-			p.buf <- item
+			select {
+			case p.buffer() <- item:
+			case <-p.done():
+			}
 		}
 	}
 
@@ -500,27 +503,45 @@ func (p *Port) PushEOS() {
 	p.sub.Push(EOS{p.strSrc})
 }
 
-// Pull an item from this port
+// Pull an item from this port, waiting until there is one. Once the operator of the port has been stopped it returns
+// nil, use PullOK to tell that apart from nil items.
 func (p *Port) Pull() interface{} {
+	i, _ := p.PullOK()
+	return i
+}
+
+// PullOK pulls an item from this port like Pull. The boolean is false in case the operator of the port has been
+// stopped before an item arrived, builtins have to return then.
+func (p *Port) PullOK() (interface{}, bool) {
 	if p.itemType == TYPE_GENERIC {
 		panic("cannot pull from generic")
 	}
 
 	if p.buf != nil {
+		done := p.done()
 		if CHANNEL_DYNAMIC {
 			for {
 				p.mutex.Lock()
 				select {
 				case i := <-p.buf:
 					p.mutex.Unlock()
-					return i
+					return i, true
 				default:
 					p.mutex.Unlock()
 				}
-				time.Sleep(1 * time.Millisecond)
+				select {
+				case <-done:
+					return nil, false
+				case <-time.After(1 * time.Millisecond):
+				}
 			}
 		} else {
-			return <-p.buf
+			select {
+			case i := <-p.buffer():
+				return i, true
+			case <-done:
+				return nil, false
+			}
 		}
 	}
 
@@ -533,7 +554,10 @@ func (p *Port) Pull() interface{} {
 		itemMap := make(map[string]interface{})
 
 		for k, sub := range p.subs {
-			i := sub.Pull()
+			i, ok := sub.PullOK()
+			if !ok {
+				return nil, false
+			}
 
 			if i == PHMultiple {
 				mi = PHMultiple
@@ -551,25 +575,28 @@ func (p *Port) Pull() interface{} {
 		}
 
 		if mi != nil {
-			return mi
+			return mi, true
 		}
-		return itemMap
+		return itemMap, true
 	}
 
 	if p.itemType == TYPE_STREAM {
-		i := p.sub.Pull()
-
-		if !p.OwnBOS(i) {
-			return i
+		i, ok := p.sub.PullOK()
+		if !ok || !p.OwnBOS(i) {
+			return i, ok
 		}
 
 		items := []interface{}{}
 
 		for {
-			i := p.sub.Pull()
+			i, ok := p.sub.PullOK()
+			if !ok {
+				// A stopped operator never delivers the end of the stream
+				return nil, false
+			}
 
 			if p.OwnEOS(i) {
-				return items
+				return items, true
 			}
 
 			items = append(items, i)
@@ -630,16 +657,45 @@ func (p *Port) PullBinary() (Binary, interface{}) {
 	return nil, item
 }
 
+// PullBOS pulls the beginning of the stream, returning false in case the operator has been stopped
 func (p *Port) PullBOS() bool {
-	i := p.sub.Pull()
+	i, ok := p.sub.PullOK()
+	if !ok {
+		return false
+	}
 	if !p.OwnBOS(i) {
 		panic(p.operator.Name() + ": expected own BOS: " + i.(BOS).src.StringifyComplete() + " != " + p.strSrc.StringifyComplete())
 	}
 	return true
 }
 
+func (p *Port) isClosed() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.closed
+}
+
+func (p *Port) buffer() chan interface{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.buf
+}
+
+// done returns a channel which is closed once the operator of the port has been stopped
+func (p *Port) done() <-chan struct{} {
+	if p.operator == nil {
+		return nil
+	}
+	return p.operator.Done()
+}
+
+// PullEOS pulls the end of the stream, returning false in case the operator has been stopped
 func (p *Port) PullEOS() bool {
-	if !p.OwnEOS(p.sub.Pull()) {
+	i, ok := p.sub.PullOK()
+	if !ok {
+		return false
+	}
+	if !p.OwnEOS(i) {
 		panic("expected own EOS")
 	}
 	return true
@@ -784,6 +840,23 @@ func (p *Port) Bufferize() {
 	}
 }
 
+// Buffered returns the number of items waiting in the buffers of this port and its sub ports
+func (p *Port) Buffered() int {
+	if p.buf != nil {
+		return len(p.buf)
+	}
+
+	n := 0
+	if p.itemType == TYPE_MAP {
+		for _, sub := range p.subs {
+			n += sub.Buffered()
+		}
+	} else if p.itemType == TYPE_STREAM {
+		n += p.sub.Buffered()
+	}
+	return n
+}
+
 // PRIVATE METHODS
 
 func setParentStreams(p *Port, parent *Port) {
@@ -993,6 +1066,22 @@ func (p *Port) defineConnections(def *OperatorDef) {
 	}
 }
 
+// String returns the notation of the placeholder, "..." or "[...]"
+func (ph *PH) String() string {
+	return ph.t
+}
+
+// ParsePH returns the placeholder with the given notation or nil if there is none
+func ParsePH(s string) *PH {
+	switch s {
+	case PHSingle.t:
+		return PHSingle
+	case PHMultiple.t:
+		return PHMultiple
+	}
+	return nil
+}
+
 func (ph *PH) MarshalJSON() ([]byte, error) {
 	return []byte("\"@PH " + ph.t + "\""), nil
 }
@@ -5,13 +5,14 @@ import (
 )
 
 type Synchronizer struct {
-	out     *Port
-	in      *Port
-	queue   chan int64
-	tasks   map[int64]chan pullFunc
-	done    map[int64]chan bool
-	mutex   *sync.Mutex
-	counter int64
+	out        *Port
+	in         *Port
+	queue      chan int64
+	tasks      map[int64]chan pullFunc
+	done       map[int64]chan bool
+	mutex      *sync.Mutex
+	tasksMutex *sync.Mutex
+	counter    int64
 }
 
 type pushFunc func(port *Port)
@@ -24,6 +25,14 @@ func (s *Synchronizer) Init(in, out *Port) {
 	s.tasks = make(map[int64]chan pullFunc)
 	s.done = make(map[int64]chan bool)
 	s.mutex = &sync.Mutex{}
+	s.tasksMutex = &sync.Mutex{}
+}
+
+// channels returns the channels of the token, the push mutex cannot be used as it is held while waiting for the worker
+func (s *Synchronizer) channels(token int64) (chan pullFunc, chan bool) {
+	s.tasksMutex.Lock()
+	defer s.tasksMutex.Unlock()
+	return s.tasks[token], s.done[token]
 }
 
 func (s *Synchronizer) Push(push pushFunc) int64 {
@@ -31,26 +40,58 @@ func (s *Synchronizer) Push(push pushFunc) int64 {
 	s.counter++
 	token := s.counter
 	push(s.out)
+	s.tasksMutex.Lock()
 	s.tasks[token] = make(chan pullFunc)
 	s.done[token] = make(chan bool)
-	s.queue <- token // order is important! worker expects to have s.tasks[token] once it can pull the token from queue
+	s.tasksMutex.Unlock()
+	select {
+	case s.queue <- token: // order is important! worker expects to have s.tasks[token] once it can pull the token from queue
+	case <-s.in.done():
+	}
 	s.mutex.Unlock()
 
 	return token
 }
 
 func (s *Synchronizer) Pull(token int64, pull pullFunc) {
-	s.tasks[token] <- pull
-	<-s.done[token]
+	stop := s.in.done()
+	task, done := s.channels(token)
+	select {
+	case task <- pull:
+		select {
+		case <-done:
+		case <-stop:
+		}
+	case <-stop:
+	}
+	s.tasksMutex.Lock()
 	delete(s.tasks, token)
 	delete(s.done, token)
+	s.tasksMutex.Unlock()
 }
 
+// Worker processes the pulls in the order of the pushes until the operator has been stopped
 func (s *Synchronizer) Worker() {
+	stop := s.in.done()
 	for {
-		token := <-s.queue
-		pull := <-s.tasks[token]
+		var token int64
+		select {
+		case token = <-s.queue:
+		case <-stop:
+			return
+		}
+		task, done := s.channels(token)
+		var pull pullFunc
+		select {
+		case pull = <-task:
+		case <-stop:
+			return
+		}
 		pull(s.in)
-		s.done[token] <- true
+		select {
+		case done <- true:
+		case <-stop:
+			return
+		}
 	}
 }
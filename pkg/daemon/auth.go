@@ -0,0 +1,206 @@
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Permissions which can be granted to users of the daemon API
+const (
+	PERM_READ  = "read"  // list and export operator definitions
+	PERM_RUN   = "run"   // start, stop and access operator instances
+	PERM_STORE = "store" // store and import operator definitions
+)
+
+var allPermissions = []string{PERM_READ, PERM_RUN, PERM_STORE}
+
+type User struct {
+	Name        string   `yaml:"name"`
+	TokenHash   string   `yaml:"tokenHash"`
+	Permissions []string `yaml:"permissions"`
+}
+
+// anonymousUser is used in case authentication is disabled, it may only read unless granted further permissions
+var anonymousUser = &User{Name: "anonymous", Permissions: []string{PERM_READ}}
+
+type userFile struct {
+	Users []*User `yaml:"users"`
+}
+
+type contextKey string
+
+const userContextKey = contextKey("user")
+
+// Authenticator maps tokens to users. As long as no API keys or users have been added, authentication is disabled
+// and requests are made by the anonymous user.
+type Authenticator struct {
+	users     map[string]*User
+	anonymous *User
+	mutex     *sync.RWMutex
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{make(map[string]*User), anonymousUser, &sync.RWMutex{}}
+}
+
+// SetAnonymousPermissions sets the permissions of the anonymous user, which only has PERM_READ by default
+func (a *Authenticator) SetAnonymousPermissions(permissions []string) error {
+	for _, perm := range permissions {
+		if !hasPermission(allPermissions, perm) {
+			return fmt.Errorf("unknown permission %s", perm)
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.anonymous = &User{Name: anonymousUser.Name, Permissions: permissions}
+	return nil
+}
+
+// Anonymous returns the user requests are made by while authentication is disabled
+func (a *Authenticator) Anonymous() *User {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.anonymous
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddAPIKey adds a static API key which is granted all permissions
+func (a *Authenticator) AddAPIKey(name string, key string) {
+	a.AddUser(&User{Name: name, TokenHash: HashToken(key), Permissions: allPermissions})
+}
+
+func (a *Authenticator) AddUser(user *User) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.users[strings.ToLower(user.TokenHash)] = user
+}
+
+// LoadUserFile adds the users of a YAML file of the following form. Token hashes are hex encoded SHA-256 hashes.
+//
+//	users:
+//	- name: alice
+//	  tokenHash: 2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+//	  permissions: [read, run]
+func (a *Authenticator) LoadUserFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var uf userFile
+	if err := yaml.Unmarshal(b, &uf); err != nil {
+		return fmt.Errorf("invalid user file %s: %s", path, err)
+	}
+
+	for _, user := range uf.Users {
+		if user.Name == "" || user.TokenHash == "" {
+			return fmt.Errorf("invalid user file %s: users require name and tokenHash", path)
+		}
+		for _, perm := range user.Permissions {
+			if !hasPermission(allPermissions, perm) {
+				return fmt.Errorf("invalid user file %s: unknown permission %s", path, perm)
+			}
+		}
+		a.AddUser(user)
+	}
+	return nil
+}
+
+func (a *Authenticator) Enabled() bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return len(a.users) != 0
+}
+
+// Authenticate returns the user the request has been authenticated as or nil. Tokens are passed either as bearer
+// token in the Authorization header or as token query parameter.
+func (a *Authenticator) Authenticate(r *http.Request) *User {
+	if !a.Enabled() {
+		return a.Anonymous()
+	}
+
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimSpace(auth[len("Bearer "):])
+	}
+	if token == "" {
+		return nil
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.users[HashToken(token)]
+}
+
+// Authorize wraps the handler so that it is only called for authenticated users with the given permission
+func (a *Authenticator) Authorize(permission string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Preflights are answered by the CORS handler of the server, unauthenticated OPTIONS requests must neither
+		// reach services nor be proxied into instances
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		user := a.Authenticate(r)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, &Error{Msg: "authentication required", Code: "E000X"})
+			return
+		}
+		if !hasPermission(user.Permissions, permission) {
+			sendError(w, http.StatusForbidden, &Error{Msg: fmt.Sprintf("permission %s required", permission), Code: "E000X"})
+			return
+		}
+
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, user)))
+	})
+}
+
+// requirePermission answers the request with 403 unless its user has the permission. It is used by endpoints
+// requiring further permissions for some methods.
+func requirePermission(w http.ResponseWriter, r *http.Request, permission string) bool {
+	if hasPermission(UserFromRequest(r).Permissions, permission) {
+		return true
+	}
+	sendError(w, http.StatusForbidden, &Error{Msg: fmt.Sprintf("permission %s required", permission), Code: "E000X"})
+	return false
+}
+
+// UserFromRequest returns the user of an authorized request, otherwise the anonymous user of the server
+func UserFromRequest(r *http.Request) *User {
+	if user, ok := r.Context().Value(userContextKey).(*User); ok {
+		return user
+	}
+	if s := serverFromRequest(r); s != nil {
+		return s.Auth.Anonymous()
+	}
+	return anonymousUser
+}
+
+// audit logs an action on behalf of the user of the request
+func audit(r *http.Request, format string, v ...interface{}) {
+	log.Printf("[AUDIT] user %s (%s): %s", UserFromRequest(r).Name, r.RemoteAddr, fmt.Sprintf(format, v...))
+}
+
+func hasPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
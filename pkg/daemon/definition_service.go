@@ -2,23 +2,95 @@ package daemon
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/Bitspark/go-funk"
+	"github.com/Bitspark/slang/pkg/api"
 	"github.com/Bitspark/slang/pkg/core"
 	"github.com/Bitspark/slang/pkg/elem"
 	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
-var DefinitionService = &Service{map[string]*Endpoint{
-	"/": {func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
-		type operatorDefJSON struct {
-			Def  core.OperatorDef `json:"def"`
-			Type string           `json:"type"`
+type operatorDefJSON struct {
+	Def  core.OperatorDef `json:"def"`
+	Type string           `json:"type"`
+}
+
+// operatorFilter selects operators by the query parameters tag, type (elementary, library or local) and name, which
+// matches case-insensitive substrings of operator names
+type operatorFilter struct {
+	tag    string
+	opType string
+	name   string
+}
+
+func newOperatorFilter(r *http.Request) operatorFilter {
+	return operatorFilter{r.FormValue("tag"), r.FormValue("type"), strings.ToLower(r.FormValue("name"))}
+}
+
+func (f operatorFilter) matchesType(opType string) bool {
+	return f.opType == "" || f.opType == opType
+}
+
+func (f operatorFilter) matches(opDef core.OperatorDef, opType string) bool {
+	if !f.matchesType(opType) {
+		return false
+	}
+	if f.name != "" && !strings.Contains(strings.ToLower(opDef.Meta.Name), f.name) {
+		return false
+	}
+	if f.tag != "" && !funk.ContainsString(opDef.Meta.Tags, f.tag) {
+		return false
+	}
+	return true
+}
+
+// paginate returns the page of the list selected by the query parameters offset and limit
+func paginate(r *http.Request, list []operatorDefJSON) ([]operatorDefJSON, error) {
+	offset, limit := 0, len(list)
+	var err error
+	if v := r.FormValue("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid offset %s", v)
+		}
+	}
+	if v := r.FormValue("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit %s", v)
 		}
+	}
+
+	if offset > len(list) {
+		offset = len(list)
+	}
+	if limit > len(list)-offset {
+		limit = len(list) - offset
+	}
+	return list[offset : offset+limit], nil
+}
+
+func operatorType(st storage.Storage, opId uuid.UUID) string {
+	if elem.IsRegistered(opId.String()) {
+		return "elementary"
+	}
+	if st.IsDumpable(opId) {
+		return "local"
+	}
+	return "library"
+}
 
+var DefinitionService = &Service{map[string]*Endpoint{
+	"/": {PERM_READ, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
 		type outJSON struct {
 			Objects []operatorDefJSON `json:"objects"`
+			Total   int               `json:"total"`
 			Status  string            `json:"status"`
 			Error   *Error            `json:"error,omitempty"`
 		}
@@ -26,10 +98,11 @@ var DefinitionService = &Service{map[string]*Endpoint{
 		var dataOut outJSON
 		var err error
 		opDefList := make([]operatorDefJSON, 0)
+		filter := newOperatorFilter(r)
 
 		opIds, err := st.List()
 
-		if err == nil {
+		if err == nil && filter.matchesType("elementary") {
 			builtinOpIds := elem.GetBuiltinIds()
 
 			// Gather builtin/elementary opDefs
@@ -40,25 +113,25 @@ var DefinitionService = &Service{map[string]*Endpoint{
 					break
 				}
 
-				opDefList = append(opDefList, operatorDefJSON{
-					Type: "elementary",
-					Def:  *opDef,
-				})
+				if filter.matches(*opDef, "elementary") {
+					opDefList = append(opDefList, operatorDefJSON{
+						Type: "elementary",
+						Def:  *opDef,
+					})
+				}
 			}
+		}
 
-			if err == nil {
-				// Gather opDefs from local & lib
-				for _, opId := range opIds {
-					opDef, err := st.Load(opId)
-					if err != nil {
-						continue
-					}
-
-					opType := "library"
-					if st.IsDumpable(opId) {
-						opType = "local"
-					}
+		if err == nil {
+			// Gather opDefs from local & lib
+			for _, opId := range opIds {
+				opDef, err := st.Load(opId)
+				if err != nil {
+					continue
+				}
 
+				opType := operatorType(st, opId)
+				if filter.matches(*opDef, opType) {
 					opDefList = append(opDefList, operatorDefJSON{
 						Type: opType,
 						Def:  *opDef,
@@ -67,8 +140,13 @@ var DefinitionService = &Service{map[string]*Endpoint{
 			}
 		}
 
+		var page []operatorDefJSON
+		if err == nil {
+			page, err = paginate(r, opDefList)
+		}
+
 		if err == nil {
-			dataOut = outJSON{Status: "success", Objects: opDefList}
+			dataOut = outJSON{Status: "success", Objects: page, Total: len(opDefList)}
 		} else {
 			dataOut = outJSON{Status: "error", Error: &Error{Msg: err.Error(), Code: "E000X"}}
 		}
@@ -79,7 +157,35 @@ var DefinitionService = &Service{map[string]*Endpoint{
 			log.Print(err)
 		}
 	}},
-	"/def/": {func(e storage.Storage, w http.ResponseWriter, r *http.Request) {
+	"/changes/": {PERM_READ, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+		// Stream changes of operators as server-sent events
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			sendFailure(w, &responseBad{&Error{Msg: "streaming not supported", Code: "E000X"}})
+			return
+		}
+
+		changes := make(chan storage.OperatorChange, 64)
+		st.Subscribe(changes)
+		defer st.Unsubscribe(changes)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(200)
+		flusher.Flush()
+
+		for {
+			select {
+			case change := <-changes:
+				b, _ := json.Marshal(change)
+				fmt.Fprintf(w, "data: %s\n\n", b)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}},
+	"/def/": {PERM_STORE, func(e storage.Storage, w http.ResponseWriter, r *http.Request) {
 		fail := func(err *Error) {
 			sendFailure(w, &responseBad{err})
 		}
@@ -108,4 +214,214 @@ var DefinitionService = &Service{map[string]*Endpoint{
 			sendSuccess(w, nil)
 		}
 	}},
+	"/def/{id}": {PERM_READ, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+		fail := func(err *Error) {
+			sendFailure(w, &responseBad{err})
+		}
+
+		opId, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			fail(&Error{Msg: err.Error(), Code: "E000X"})
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			opDef, err := st.Load(opId)
+			if err != nil {
+				sendError(w, http.StatusNotFound, &Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+			sendSuccess(w, &responseOK{operatorDefJSON{*opDef, operatorType(st, opId)}})
+
+		case "DELETE":
+			if !requirePermission(w, r, PERM_STORE) {
+				return
+			}
+			graph, err := st.DependencyGraph()
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+			if dependents := graph.Dependents(opId, false); len(dependents) != 0 {
+				names := make([]string, len(dependents))
+				for i, depId := range dependents {
+					names[i] = fmt.Sprintf("%s (%s)", graph.Name(depId), depId)
+				}
+				sendError(w, http.StatusConflict, &Error{Msg: "operator is still used by " + strings.Join(names, ", "), Code: "E000X"})
+				return
+			}
+			if err := st.Remove(opId); err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+			audit(r, "removed operator %s", opId)
+			sendSuccess(w, nil)
+
+		case "PATCH":
+			// Renames the operator
+			if !requirePermission(w, r, PERM_STORE) {
+				return
+			}
+
+			var patch struct {
+				Name string `json:"name"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+			if strings.TrimSpace(patch.Name) == "" {
+				fail(&Error{Msg: "name may not be empty", Code: "E000X"})
+				return
+			}
+			if !st.IsDumpable(opId) {
+				fail(&Error{Msg: fmt.Sprintf("operator %s is not stored locally", opId), Code: "E000X"})
+				return
+			}
+
+			opDef, err := st.Load(opId)
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+			opDef.Meta.Name = patch.Name
+			if _, err := st.Store(*opDef); err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+			sendSuccess(w, &responseOK{operatorDefJSON{*opDef, operatorType(st, opId)}})
+		}
+	}},
+	"/def/{id}/deps/": {PERM_READ, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+		type depJSON struct {
+			Id   string `json:"id"`
+			Name string `json:"name"`
+		}
+		type depsJSON struct {
+			Dependencies []depJSON `json:"dependencies"`
+			Dependents   []depJSON `json:"dependents"`
+		}
+
+		opId, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			sendFailure(w, &responseBad{&Error{Msg: err.Error(), Code: "E000X"}})
+			return
+		}
+
+		graph, err := st.DependencyGraph()
+		if err != nil {
+			sendFailure(w, &responseBad{&Error{Msg: err.Error(), Code: "E000X"}})
+			return
+		}
+		if !graph.Has(opId) {
+			sendError(w, http.StatusNotFound, &Error{Msg: fmt.Sprintf("unknown operator for id: %s", opId), Code: "E000X"})
+			return
+		}
+
+		toJSON := func(ids []uuid.UUID) []depJSON {
+			deps := make([]depJSON, len(ids))
+			for i, id := range ids {
+				deps[i] = depJSON{id.String(), graph.Name(id)}
+			}
+			return deps
+		}
+
+		transitive := r.FormValue("transitive") != "false"
+		sendSuccess(w, &responseOK{depsJSON{
+			toJSON(graph.Dependencies(opId, transitive)),
+			toJSON(graph.Dependents(opId, transitive)),
+		}})
+	}},
+	"/def/{id}/slangfile/": {PERM_READ, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		fail := func(err error) {
+			sendFailure(w, &responseBad{&Error{Msg: err.Error(), Code: "E000X"}})
+		}
+
+		opId, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		shake := r.FormValue("shake") == "true"
+		opts := api.SlangFileOptions{ShakeDelegates: shake, ShakeTests: shake}
+		if props := r.FormValue("properties"); props != "" {
+			if err := json.Unmarshal([]byte(props), &opts.Properties); err != nil {
+				fail(err)
+				return
+			}
+		}
+		if gens := r.FormValue("generics"); gens != "" {
+			if err := json.Unmarshal([]byte(gens), &opts.Generics); err != nil {
+				fail(err)
+				return
+			}
+		}
+
+		slFile, err := api.CreateSlangFile(opId, opts, st)
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		var b []byte
+		format := r.FormValue("format")
+		switch format {
+		case "", "json":
+			format = "json"
+			b, err = json.MarshalIndent(slFile, "", "  ")
+		case "yaml":
+			b, err = yaml.Marshal(slFile)
+		default:
+			err = fmt.Errorf("unknown format %s", format)
+		}
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/"+format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.slang.%s\"", opId, format))
+		w.Write(b)
+	}},
+	"/def/{id}/extract/": {PERM_STORE, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		fail := func(err *Error) {
+			sendFailure(w, &responseBad{err})
+		}
+
+		opId, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			fail(&Error{Msg: err.Error(), Code: "E000X"})
+			return
+		}
+
+		var req struct {
+			Instances []string `json:"instances"`
+			Name      string   `json:"name"`
+			Instance  string   `json:"instance"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			fail(&Error{Msg: err.Error(), Code: "E000X"})
+			return
+		}
+
+		extraction, err := api.Extract(opId, req.Instances, req.Name, req.Instance, st)
+		if err != nil {
+			fail(&Error{Msg: err.Error(), Code: "E000X"})
+			return
+		}
+		audit(r, "extracted %v of operator %s into new operator %s", req.Instances, opId, extraction.Extracted.Id)
+		sendSuccess(w, &responseOK{extraction})
+	}},
 }}
@@ -0,0 +1,95 @@
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/google/uuid"
+)
+
+var HistoryService = &Service{map[string]*Endpoint{
+	"/": {PERM_READ, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+		fail := func(err *Error) {
+			sendFailure(w, &responseBad{err})
+		}
+		/*
+		 * GET
+		 */
+		if r.Method == "GET" {
+			opId, err := uuid.Parse(r.FormValue("id"))
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+
+			revs, err := st.Revisions(opId)
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+
+			sendSuccess(w, &responseOK{revs})
+		}
+	}},
+	"/diff/": {PERM_READ, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+		fail := func(err *Error) {
+			sendFailure(w, &responseBad{err})
+		}
+		/*
+		 * GET
+		 */
+		if r.Method == "GET" {
+			opId, err := uuid.Parse(r.FormValue("id"))
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+
+			// Omitted revisions denote the current operator
+			from, err := st.LoadRevision(opId, r.FormValue("from"))
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+			to, err := st.LoadRevision(opId, r.FormValue("to"))
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+
+			changes, err := storage.Diff(from, to)
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+
+			sendSuccess(w, &responseOK{changes})
+		}
+	}},
+	"/restore/": {PERM_STORE, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+		fail := func(err *Error) {
+			sendFailure(w, &responseBad{err})
+		}
+		/*
+		 * POST
+		 */
+		if r.Method == "POST" {
+			opId, err := uuid.Parse(r.FormValue("id"))
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+
+			rev := r.FormValue("rev")
+			opDef, err := st.Restore(opId, rev)
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
+			}
+
+			audit(r, "restored operator %s from revision %s", opId, rev)
+
+			sendSuccess(w, &responseOK{opDef})
+		}
+	}},
+}}
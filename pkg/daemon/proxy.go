@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -35,7 +36,7 @@ func proxyRequestToOperator(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	operator, ok := runningInstances[handleID]
+	operator, ok := getRunningInstance(handleID)
 	if !ok {
 		w.WriteHeader(404)
 		return
@@ -44,7 +45,7 @@ func proxyRequestToOperator(w http.ResponseWriter, r *http.Request) {
 	newPort := operator.port
 	newURL := url.URL{}
 	newURL.Scheme = "http"
-	newURL.Host = "localhost:" + strconv.Itoa(newPort)
+	newURL.Host = net.JoinHostPort(operator.host, strconv.Itoa(newPort))
 	newURL.Path = newPath
 	newURL.RawQuery = r.URL.RawQuery
 
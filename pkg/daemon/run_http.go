@@ -10,7 +10,7 @@ import (
 
 // Constructs an executable operator
 // TODO: Make safer (maybe require an API key?)
-func constructHttpEndpoint(st storage.Storage, port int, opId uuid.UUID, gens core.Generics, props core.Properties) (*core.OperatorDef, error) {
+func constructHttpEndpoint(st storage.Storage, host string, port int, opId uuid.UUID, gens core.Generics, props core.Properties) (*core.OperatorDef, error) {
 	httpDef := &core.OperatorDef{
 		Id:   "caff9fef-01fa-4ef8-bb11-aabbccddeeff",
 		Meta: core.OperatorMetaDef{Name: "httpWrapper"},
@@ -52,6 +52,14 @@ func constructHttpEndpoint(st storage.Storage, port int, opId uuid.UUID, gens co
 	httpIns := &core.InstanceDef{
 		Name:     "httpServer",
 		Operator: elem.GetId("HTTP server").String(),
+		Properties: core.Properties{
+			"host": host,
+			"cors": map[string]interface{}{
+				"origins": []interface{}{"*"},
+				"methods": []interface{}{},
+				"headers": []interface{}{},
+			},
+		},
 	}
 	httpDef.InstanceDefs = append(httpDef.InstanceDefs, httpIns)
 	httpDef.Connections["port)"] = []string{"(httpServer"}
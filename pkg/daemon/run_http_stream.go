@@ -10,7 +10,7 @@ import (
 
 // Constructs an executable operator
 // TODO: Make safer (maybe require an API key?)
-func constructHttpStreamEndpoint(st storage.Storage, port int, opId uuid.UUID, gens core.Generics, props core.Properties) (*core.OperatorDef, error) {
+func constructHttpStreamEndpoint(st storage.Storage, host string, port int, opId uuid.UUID, gens core.Generics, props core.Properties) (*core.OperatorDef, error) {
 	httpDef := &core.OperatorDef{
 		Id: "caff9fef-01fa-4ef8-bb11-aabbccddeeff",
 		ServiceDefs: map[string]*core.ServiceDef{
@@ -51,6 +51,14 @@ func constructHttpStreamEndpoint(st storage.Storage, port int, opId uuid.UUID, g
 	httpIns := &core.InstanceDef{
 		Name:     "httpServer",
 		Operator: elem.GetId("HTTP server").String(),
+		Properties: core.Properties{
+			"host": host,
+			"cors": map[string]interface{}{
+				"origins": []interface{}{"*"},
+				"methods": []interface{}{},
+				"headers": []interface{}{},
+			},
+		},
 	}
 	httpDef.InstanceDefs = append(httpDef.InstanceDefs, httpIns)
 	httpDef.Connections["port)"] = []string{"(httpServer"}
@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -8,6 +9,8 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/Bitspark/slang/pkg/api"
 	"github.com/Bitspark/slang/pkg/core"
@@ -15,12 +18,27 @@ import (
 	"github.com/google/uuid"
 )
 
-var runningInstances = make(map[int64]struct {
-	port int
-	op   *core.Operator
-})
+type runningInstance struct {
+	host    string
+	port    int
+	op      *core.Operator
+	deps    map[uuid.UUID]bool // operators the instance is built from, for hot reloading
+	stop    chan bool
+	stopped bool
+	mutex   *sync.Mutex
+}
+
+var runningInstances = make(map[int64]*runningInstance)
+var instancesMutex = &sync.Mutex{}
 var rnd = rand.New(rand.NewSource(99))
 
+func getRunningInstance(handle int64) (*runningInstance, bool) {
+	instancesMutex.Lock()
+	defer instancesMutex.Unlock()
+	ii, ok := runningInstances[handle]
+	return ii, ok
+}
+
 type httpDefLoader struct {
 	httpDef *core.OperatorDef
 }
@@ -42,15 +60,128 @@ func (l *httpDefLoader) Load(opId uuid.UUID) (*core.OperatorDef, error) {
 	return l.httpDef, nil
 }
 
+type runInstructionJSON struct {
+	Id        string          `json:"id"`
+	Props     core.Properties `json:"props"`
+	Gens      core.Generics   `json:"gens"`
+	Stream    bool            `json:"stream"`
+	HotReload bool            `json:"hotReload"`
+}
+
+// buildInstance builds the operator wrapped into an HTTP endpoint listening on the given host and port
+func buildInstance(st storage.Storage, host string, port int, opId uuid.UUID, ri runInstructionJSON) (*core.Operator, error) {
+	var httpDef *core.OperatorDef
+	var err error
+	if ri.Stream {
+		httpDef, err = constructHttpStreamEndpoint(st, host, port, opId, ri.Gens, ri.Props)
+	} else {
+		httpDef, err = constructHttpEndpoint(st, host, port, opId, ri.Gens, ri.Props)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	httpDefId, _ := uuid.Parse(httpDef.Id)
+	return api.BuildAndCompile(httpDefId, nil, nil, *st.WithLoader(&httpDefLoader{httpDef}))
+}
+
+func startInstance(op *core.Operator, port int, handle int64) {
+	op.Main().Out().Bufferize()
+	op.Start(context.Background())
+	op.Main().In().Push(nil) // Start server
+
+	go func() {
+		oprlt := op.Main().Out().Pull()
+		log.Printf("operator %s (port: %d, id: %s) terminated: %v", op.Name(), port, strconv.FormatInt(handle, 16), oprlt)
+	}()
+}
+
+func instanceDependencies(st storage.Storage, opId uuid.UUID) map[uuid.UUID]bool {
+	deps := map[uuid.UUID]bool{opId: true}
+	depIds, err := st.Dependencies(opId)
+	if err != nil {
+		log.Printf("cannot determine dependencies of %s: %s", opId, err)
+	}
+	for _, depId := range depIds {
+		deps[depId] = true
+	}
+	return deps
+}
+
+// waitForPort waits until the port has been released by a stopped instance
+func waitForPort(host string, port int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port))); err == nil {
+			ln.Close()
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// hotReload rebuilds and swaps the instance whenever its operator or one of its dependencies changes. In case the
+// changed operator cannot be built, the instance keeps running unchanged.
+func hotReload(st storage.Storage, handle int64, opId uuid.UUID, ri runInstructionJSON, ii *runningInstance) {
+	changes := make(chan storage.OperatorChange, 64)
+	st.Subscribe(changes)
+	defer st.Unsubscribe(changes)
+
+	for {
+		select {
+		case <-ii.stop:
+			return
+		case change := <-changes:
+			ii.mutex.Lock()
+			affected := change.Kind == storage.CHANGE_RESYNC || ii.deps[change.Id]
+			ii.mutex.Unlock()
+			if !affected {
+				continue
+			}
+
+			// Editors tend to write files several times in a row
+			timeout := time.After(200 * time.Millisecond)
+		debounce:
+			for {
+				select {
+				case <-changes:
+				case <-timeout:
+					break debounce
+				}
+			}
+
+			op, err := buildInstance(st, ii.host, ii.port, opId, ri)
+			if err != nil {
+				log.Printf("cannot reload instance %s: %s", strconv.FormatInt(handle, 16), err)
+				continue
+			}
+
+			// Waiting for the port must not block stopping the instance
+			ii.mutex.Lock()
+			oldOp := ii.op
+			stopped := ii.stopped
+			ii.mutex.Unlock()
+			if stopped {
+				return
+			}
+			oldOp.Stop()
+			waitForPort(ii.host, ii.port)
+
+			ii.mutex.Lock()
+			if !ii.stopped {
+				startInstance(op, ii.port, handle)
+				ii.op = op
+				ii.deps = instanceDependencies(st, opId)
+				log.Printf("reloaded instance %s of operator %s", strconv.FormatInt(handle, 16), opId)
+			}
+			ii.mutex.Unlock()
+		}
+	}
+}
+
 var RunnerService = &Service{map[string]*Endpoint{
-	"/": {func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+	"/": {PERM_RUN, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" {
-			type runInstructionJSON struct {
-				Id     string          `json:"id"`
-				Props  core.Properties `json:"props"`
-				Gens   core.Generics   `json:"gens"`
-				Stream bool            `json:"stream"`
-			}
 
 			type outJSON struct {
 				URL    string `json:"url,omitempty"`
@@ -70,12 +201,13 @@ var RunnerService = &Service{map[string]*Endpoint{
 				return
 			}
 
+			host := serverFromRequest(r).instanceHost()
 			port := 50000
 			portUsed := true
 			for portUsed {
 				port++
 				portUsed = false
-				ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+				ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 				if err != nil {
 					portUsed = true
 				} else {
@@ -91,49 +223,33 @@ var RunnerService = &Service{map[string]*Endpoint{
 				return
 			}
 
-			var httpDef *core.OperatorDef
-			if ri.Stream {
-				httpDef, err = constructHttpStreamEndpoint(st, port, opId, ri.Gens, ri.Props)
-			} else {
-				httpDef, err = constructHttpEndpoint(st, port, opId, ri.Gens, ri.Props)
-			}
-
+			op, err := buildInstance(st, host, port, opId, ri)
 			if err != nil {
 				data = outJSON{Status: "error", Error: &Error{Msg: err.Error(), Code: "E000X"}}
 				writeJSON(w, &data)
 				return
 			}
 
-			st.AddLoader(&httpDefLoader{httpDef})
-			httpDefId, _ := uuid.Parse(httpDef.Id)
-			op, err := api.BuildAndCompile(httpDefId, nil, nil, st)
-			if err != nil {
-				data = outJSON{Status: "error", Error: &Error{Msg: err.Error(), Code: "E000X"}}
-				writeJSON(w, &data)
-				return
-			}
+			ii := &runningInstance{host: host, port: port, op: op, stop: make(chan bool), mutex: &sync.Mutex{}}
 
+			instancesMutex.Lock()
 			handle := rnd.Int63()
-			runningInstances[handle] = struct {
-				port int
-				op   *core.Operator
-			}{port, op}
+			runningInstances[handle] = ii
+			instancesMutex.Unlock()
 
-			op.Main().Out().Bufferize()
-			op.Start()
-			log.Printf("operator %s (port: %d, id: %s) started", op.Name(), port, strconv.FormatInt(handle, 16))
-			op.Main().In().Push(nil) // Start server
+			startInstance(op, port, handle)
+			audit(r, "started operator %s (port: %d, id: %s)", ri.Id, port, strconv.FormatInt(handle, 16))
+
+			if ri.HotReload {
+				ii.deps = instanceDependencies(st, opId)
+				go hotReload(st, handle, opId, ri, ii)
+			}
 
 			data.Status = "success"
 			data.Handle = strconv.FormatInt(handle, 16)
 			data.URL = "/instance/" + strconv.FormatInt(handle, 16)
 
 			writeJSON(w, &data)
-
-			go func() {
-				oprlt := op.Main().Out().Pull()
-				log.Printf("operator %s (port: %d, id: %s) terminated: %v", op.Name(), port, strconv.FormatInt(handle, 16), oprlt)
-			}()
 		} else if r.Method == "DELETE" {
 			type stopInstructionJSON struct {
 				Handle string `json:"handle"`
@@ -157,13 +273,22 @@ var RunnerService = &Service{map[string]*Endpoint{
 
 			handle, _ := strconv.ParseInt(si.Handle, 16, 64)
 
-			if ii, ok := runningInstances[handle]; !ok {
+			if ii, ok := getRunningInstance(handle); !ok {
 				data = outJSON{Status: "error", Error: &Error{Msg: "Unknown handle", Code: "E000X"}}
 				writeJSON(w, &data)
 				return
 			} else {
-				go ii.op.Stop()
+				instancesMutex.Lock()
 				delete(runningInstances, handle)
+				instancesMutex.Unlock()
+
+				ii.mutex.Lock()
+				ii.stopped = true
+				close(ii.stop)
+				go ii.op.Stop()
+				ii.mutex.Unlock()
+
+				audit(r, "stopped instance %s (port: %d)", si.Handle, ii.port)
 
 				data.Status = "success"
 				writeJSON(w, &data)
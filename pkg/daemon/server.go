@@ -1,9 +1,11 @@
 package daemon
 
 import (
+	"context"
 	"fmt"
 	"github.com/Bitspark/slang/pkg/storage"
 	"github.com/rs/cors"
+	"net"
 	"net/http"
 	"path/filepath"
 	"regexp"
@@ -17,13 +19,41 @@ type Server struct {
 	Storage storage.Storage
 	Host    string
 	Port    int
-	router  *mux.Router
+	Auth    *Authenticator
+	// AllowedOrigins are the origins allowed to access the API from a browser in addition to the origin of the
+	// daemon itself, "*" allows all origins
+	AllowedOrigins []string
+	router         *mux.Router
 }
 
+const serverContextKey = contextKey("server")
+
 func New(s storage.Storage, host string, port int) *Server {
 	r := mux.NewRouter()
-	http.Handle("/", r)
-	return &Server{s, host, port, r}
+	return &Server{s, host, port, NewAuthenticator(), nil, r}
+}
+
+// serverFromRequest returns the server handling the request of a service
+func serverFromRequest(r *http.Request) *Server {
+	s, _ := r.Context().Value(serverContextKey).(*Server)
+	return s
+}
+
+// instanceHost returns the host operator instances listen on. They are bound to the host of the daemon, except for
+// all interfaces, in which case they are bound to the loopback interface as they are accessed through the daemon.
+func (s *Server) instanceHost() string {
+	if s == nil || s.Host == "" {
+		return "localhost"
+	}
+	if ip := net.ParseIP(s.Host); ip != nil && ip.IsUnspecified() {
+		return "localhost"
+	}
+	return s.Host
+}
+
+// Handler returns the handler serving all services of the server
+func (s *Server) Handler() http.Handler {
+	return s.router
 }
 
 func (s *Server) AddService(pathPrefix string, services *Service) {
@@ -31,7 +61,9 @@ func (s *Server) AddService(pathPrefix string, services *Service) {
 	r := s.router.PathPrefix(pathPrefix).Subrouter()
 	for path, endpoint := range services.Routes {
 		(func(endpoint *Endpoint) {
-			r.HandleFunc(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { endpoint.Handle(s.Storage, w, r) }))
+			r.Handle(path, s.Auth.Authorize(endpoint.Permission, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				endpoint.Handle(s.Storage, w, r.WithContext(context.WithValue(r.Context(), serverContextKey, s)))
+			})))
 		})(endpoint)
 	}
 }
@@ -53,10 +85,10 @@ func (s *Server) AddAppServer(pathPrefix string, directory http.Dir) {
 
 func (s *Server) AddOperatorProxy(pathPrefix string) {
 	r := s.router.PathPrefix(pathPrefix)
-	r.Handler(http.StripPrefix(pathPrefix,
+	r.Handler(s.Auth.Authorize(PERM_RUN, http.StripPrefix(pathPrefix,
 		r.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			proxyRequestToOperator(w, r)
-		}).GetHandler()))
+		}).GetHandler())))
 }
 
 func (s *Server) AddStaticServer(pathPrefix string, directory http.Dir) {
@@ -70,9 +102,16 @@ func (s *Server) AddRedirect(path string, redirectTo string) {
 	r.Handler(http.RedirectHandler(redirectTo, http.StatusSeeOther))
 }
 
+// Run serves the API. Cross-origin requests are only answered with CORS headers for the allowed origins, so browsers
+// restrict other websites to the same-origin policy.
 func (s *Server) Run() error {
-	handler := cors.New(cors.Options{
-		AllowedMethods: []string{"GET", "POST", "DELETE"},
-	}).Handler(s.router)
-	return http.ListenAndServe(fmt.Sprintf(":%d", s.Port), handler)
+	var handler http.Handler = s.router
+	if len(s.AllowedOrigins) != 0 {
+		handler = cors.New(cors.Options{
+			AllowedOrigins: s.AllowedOrigins,
+			AllowedMethods: []string{"GET", "POST", "DELETE", "PATCH"},
+			AllowedHeaders: []string{"Content-Type", "Authorization"},
+		}).Handler(s.router)
+	}
+	return http.ListenAndServe(fmt.Sprintf("%s:%d", s.Host, s.Port), handler)
 }
@@ -13,7 +13,8 @@ type Service struct {
 }
 
 type Endpoint struct {
-	Handle func(st storage.Storage, w http.ResponseWriter, r *http.Request)
+	Permission string
+	Handle     func(st storage.Storage, w http.ResponseWriter, r *http.Request)
 }
 
 func writeJSON(w io.Writer, dat interface{}) error {
@@ -41,6 +42,15 @@ func sendSuccess(w http.ResponseWriter, resp *responseOK) {
 	}
 }
 
+func sendError(w http.ResponseWriter, status int, e *Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := writeJSON(w, &responseBad{e})
+	if err != nil {
+		log.Printf("[ERROR] %v\n", err)
+	}
+}
+
 func sendFailure(w http.ResponseWriter, resp *responseBad) {
 	w.WriteHeader(400)
 	err := writeJSON(w, resp)
@@ -1,33 +1,18 @@
 package daemon
 
 import (
-	"archive/zip"
 	"bytes"
 	"fmt"
+	"io"
+	"net/http"
+
 	"github.com/Bitspark/go-version"
 	"github.com/Bitspark/slang/pkg/storage"
 	"github.com/google/uuid"
-	"gopkg.in/yaml.v2"
-	"io"
-	"io/ioutil"
-	"net/http"
-	"os"
-	"path/filepath"
-	"time"
 )
 
-type manifest struct {
-	SlangVersion string `yaml:"slangVersion"`
-	TimeUnix     int64  `yaml:"timeUnix"`
-}
-
-/* TODO re-implemet this service
- */
-
-var suffixes = []string{"_visual.yaml"}
-
 var SharingService = &Service{map[string]*Endpoint{
-	"/export": {func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+	"/export": {PERM_READ, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
 		fail := func(err *Error) {
 			sendFailure(w, &responseBad{err})
 		}
@@ -43,26 +28,10 @@ var SharingService = &Service{map[string]*Endpoint{
 			}
 
 			buf := new(bytes.Buffer)
-			zipWriter := zip.NewWriter(buf)
-
-			fileWriter, _ := zipWriter.Create("manifest.yaml")
-			manifestBytes, _ := yaml.Marshal(&manifest{
-				SlangVersion: SlangVersion,
-				TimeUnix:     time.Now().Unix(),
-			})
-
-			fileWriter.Write(manifestBytes)
-
-			/* TODO
-			read := make(map[uuid.UUID]bool)
-			err = st.PackOperator(zipWriter, opId, read)
-			if err != nil {
+			if _, err := st.PackBundle(buf, opId, SlangVersion); err != nil {
 				fail(&Error{Msg: err.Error(), Code: "E000X"})
 				return
 			}
-			*/
-
-			zipWriter.Close()
 
 			w.Header().Set("Pragma", "public")
 			w.Header().Set("Expires", "0")
@@ -76,44 +45,39 @@ var SharingService = &Service{map[string]*Endpoint{
 			w.Write(buf.Bytes())
 		}
 	}},
-	"/import": {func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
+	"/import": {PERM_STORE, func(st storage.Storage, w http.ResponseWriter, r *http.Request) {
 		fail := func(err *Error) {
 			sendFailure(w, &responseBad{err})
 		}
 		/*
-		 * GET
+		 * POST
 		 */
 		if r.Method == "POST" {
+			// Leave room for the multipart encoding of the bundle
+			r.Body = http.MaxBytesReader(w, r.Body, storage.MaxBundleSize+1<<20)
+
 			var buf bytes.Buffer
-			file, header, err := r.FormFile("file")
+			file, _, err := r.FormFile("file")
 			if err != nil {
 				fail(&Error{Msg: err.Error(), Code: "E000X"})
 				return
 			}
 			defer file.Close()
 
-			io.Copy(&buf, file)
-
-			zipReader, err := zip.NewReader(file, header.Size)
-			if err != nil {
+			if _, err := io.Copy(&buf, file); err != nil {
 				fail(&Error{Msg: err.Error(), Code: "E000X"})
 				return
 			}
 
-			manifest := manifest{}
-			for _, file := range zipReader.File {
-				if file.Name == "manifest.yaml" {
-					fileReader, _ := file.Open()
-					buf := new(bytes.Buffer)
-					buf.ReadFrom(fileReader)
-					yaml.Unmarshal(buf.Bytes(), &manifest)
-					fileReader.Close()
-				}
+			bundle, err := storage.ReadBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				fail(&Error{Msg: err.Error(), Code: "E000X"})
+				return
 			}
 
 			myVersion, err := version.NewVersion(SlangVersion)
 			if err == nil {
-				manifestVersion, err := version.NewVersion(manifest.SlangVersion)
+				manifestVersion, err := version.NewVersion(bundle.Manifest.SlangVersion)
 				if err == nil {
 					if myVersion.LessThan(manifestVersion) {
 						fail(&Error{Msg: "Please upgrade your slang version", Code: "E000X"})
@@ -122,27 +86,27 @@ var SharingService = &Service{map[string]*Endpoint{
 				}
 			}
 
-			fail(&Error{Msg: "not implemented yet", Code: "E000X"})
-			return
-
-			baseDir := "" // st.WorkingDir()
-			for _, file := range zipReader.File {
-				if file.Name == "manifest.yaml" {
-					continue
-				}
-
-				fileReader, _ := file.Open()
-				buf := new(bytes.Buffer)
-				buf.ReadFrom(fileReader)
-				fpath := filepath.Join(baseDir, filepath.FromSlash(file.Name))
-				os.MkdirAll(filepath.Dir(fpath), os.ModePerm)
-				ioutil.WriteFile(fpath, buf.Bytes(), os.ModePerm)
-				fileReader.Close()
+			result, err := st.ImportBundle(bundle, r.FormValue("overwrite") == "true")
+			if err != nil && (result == nil || len(result.Conflicts) == 0) {
+				sendError(w, http.StatusInternalServerError, &Error{Msg: err.Error(), Code: "E000X"})
+				return
 			}
+			if err != nil {
+				w.WriteHeader(http.StatusConflict)
+				writeJSON(w, &struct {
+					Success bool                  `json:"success"`
+					Result  *storage.ImportResult `json:"result,omitempty"`
+					Error   *Error                `json:"error"`
+				}{false, result, &Error{Msg: err.Error(), Code: "E000X"}})
+				return
+			}
+
+			audit(r, "imported bundle of operator %s (%d operators stored)", bundle.Manifest.Main, len(result.Imported))
 
 			writeJSON(w, &struct {
-				Success bool `json:"success"`
-			}{true})
+				Success bool                  `json:"success"`
+				Result  *storage.ImportResult `json:"result"`
+			}{true, result})
 		}
 	}},
 }}
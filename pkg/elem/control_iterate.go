@@ -90,22 +90,34 @@ var controlIterateCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			state := in.Map("initial").Pull()
+			state, ok := in.Map("initial").PullOK()
+			if !ok {
+				return
+			}
 
 			// Redirect all markers
 			if core.IsMarker(state) {
-				if !core.IsMarker(in.Map("items").Stream().Pull()) {
+				marker, ok := in.Map("items").Stream().PullOK()
+				if !ok {
+					return
+				}
+				if !core.IsMarker(marker) {
 					panic("should be marker")
 				}
 				out.Push(state)
 				continue
 			}
 
-			in.Map("items").PullBOS()
+			if !in.Map("items").PullBOS() {
+				return
+			}
 			out.Map("items").PushBOS()
 
 			for {
-				inItem := in.Map("items").Stream().Pull()
+				inItem, ok := in.Map("items").Stream().PullOK()
+				if !ok {
+					return
+				}
 				if core.IsMarker(inItem) {
 					if in.Map("items").OwnEOS(inItem) {
 						break
@@ -116,8 +128,14 @@ var controlIterateCfg = &builtinConfig{
 				iterOut.Map("item").Push(inItem)
 				iterOut.Map("state").Push(state)
 
-				state = iterIn.Map("state").Pull()
-				out.Map("items").Push(iterIn.Map("item").Pull())
+				if state, ok = iterIn.Map("state").PullOK(); !ok {
+					return
+				}
+				item, ok := iterIn.Map("item").PullOK()
+				if !ok {
+					return
+				}
+				out.Map("items").Push(item)
 			}
 
 			out.Map("result").Push(state)
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -71,7 +72,7 @@ func Test_CtrlIterate__PassOtherMarkers(t *testing.T) {
 
 	do.Main().Out().Bufferize()
 
-	do.Start()
+	do.Start(context.Background())
 
 	do.Main().In().Push([]interface{}{map[string]interface{}{"init": 0.0, "items": []interface{}{}}})
 	a.PortPushesAll([]interface{}{[]interface{}{map[string]interface{}{"result": 0.0, "items": []interface{}{}}}}, do.Main().Out())
@@ -147,8 +148,8 @@ func Test_CtrlIterate__SimpleAggregation(t *testing.T) {
 	ao.Main().In().Map("initial").Push(4.0)
 	ao.Main().In().Map("items").Push([]interface{}{1.0, 2.0, 3.0})
 
-	ao.Start()
-	fo.Start()
+	ao.Start(context.Background())
+	fo.Start(context.Background())
 
 	a.PortPushesAll([]interface{}{
 		map[string]interface{}{"result": 6.0, "items": []interface{}{0.0, -1.0, -3.0}},
@@ -78,7 +78,10 @@ var controlLoopCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			s := in.Pull()
+			s, ok := in.PullOK()
+			if !ok {
+				return
+			}
 
 			// Redirect all markers
 			if core.IsMarker(s) {
@@ -91,8 +94,11 @@ var controlLoopCfg = &builtinConfig{
 			for {
 				// Ask controller whether to continue
 				ctrlOut.Push(s)
-				cont, _ := ctrlIn.PullBoolean()
-				if !cont {
+				c, ok := ctrlIn.PullOK()
+				if !ok {
+					return
+				}
+				if cont, _ := c.(bool); !cont {
 					break
 				}
 
@@ -100,7 +106,11 @@ var controlLoopCfg = &builtinConfig{
 				iterOut.Push(s)
 
 				// Retrieve new state from iterator
-				ns := iterIn.Pull().(map[string]interface{})
+				i, ok := iterIn.PullOK()
+				if !ok {
+					return
+				}
+				ns := i.(map[string]interface{})
 
 				// Set state for next iteration
 				s = ns["state"]
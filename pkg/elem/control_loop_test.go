@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -175,7 +176,7 @@ func Test_CtrlLoop__Behavior(t *testing.T) {
 	dlgIter.Out().Bufferize()
 	dlgCtrl.Out().Bufferize()
 
-	lop.Start()
+	lop.Start(context.Background())
 
 	// Values we push into the loop at its in port
 	inVals := []interface{}{
@@ -67,7 +67,10 @@ var controlReduceCfg = &builtinConfig{
 		sOut := op.Delegate("reducer").Out()
 		nullValue := op.Property("emptyValue")
 		for !op.CheckStop() {
-			i := in.Stream().Pull()
+			i, ok := in.Stream().PullOK()
+			if !ok {
+				return
+			}
 
 			if !in.OwnBOS(i) {
 				out.Push(i)
@@ -99,7 +102,10 @@ var controlReduceCfg = &builtinConfig{
 					}
 					mutex.Unlock()
 
-					i := sIn.Pull()
+					i, ok := sIn.PullOK()
+					if !ok {
+						return
+					}
 
 					mutex.Lock()
 					pool = append(pool, i)
@@ -110,9 +116,13 @@ var controlReduceCfg = &builtinConfig{
 			for {
 				// Stream items
 
-				i = in.Stream().Pull()
+				if i, ok = in.Stream().PullOK(); !ok {
+					return
+				}
 				if in.OwnEOS(i) {
+					mutex.Lock()
 					done = true
+					mutex.Unlock()
 					break
 				}
 
@@ -121,7 +131,11 @@ var controlReduceCfg = &builtinConfig{
 				mutex.Unlock()
 			}
 
-			<-doneChan
+			select {
+			case <-doneChan:
+			case <-op.Done():
+				return
+			}
 
 			if len(pool) == 1 {
 				out.Push(pool[0])
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -77,7 +78,7 @@ func Test_CtrlReduce__PassMarkers(t *testing.T) {
 
 	o.Main().Out().Bufferize()
 	o.Delegate("reducer").Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	bos := core.BOS{}
 	eos := core.BOS{}
@@ -100,7 +101,7 @@ func Test_CtrlReduce__SelectionFromItemsEmpty(t *testing.T) {
 
 	o.Main().Out().Bufferize()
 	o.Delegate("reducer").Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push([]interface{}{})
 
@@ -117,7 +118,7 @@ func Test_CtrlReduce__SelectionFromItemsSingle(t *testing.T) {
 
 	o.Main().Out().Bufferize()
 	o.Delegate("reducer").Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push([]interface{}{123.0})
 
@@ -134,7 +135,7 @@ func Test_CtrlReduce__SelectionFromItemsMultiple(t *testing.T) {
 
 	o.Main().Out().Bufferize()
 	o.Delegate("reducer").Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push([]interface{}{1.0, 2.0})
 	o.Delegate("reducer").In().Push(3.0)
@@ -155,7 +156,7 @@ func Test_CtrlReduce__SelectionFromPool(t *testing.T) {
 
 	o.Main().Out().Bufferize()
 	o.Delegate("reducer").Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push([]interface{}{1.0, 1.0, 1.0, 1.0})
 	o.Delegate("reducer").In().Push(2.0)
@@ -41,7 +41,10 @@ var controlSemaphoreVCfg = &builtinConfig{
 		semStore := getSemaphoreStore(sem)
 
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
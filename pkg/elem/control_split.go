@@ -71,7 +71,10 @@ var controlSplitCfg = &builtinConfig{
 		dlgIn := dlg.In()
 
 		for !op.CheckStop() {
-			i := in.Stream().Pull()
+			i, ok := in.Stream().PullOK()
+			if !ok {
+				return
+			}
 			if !in.OwnBOS(i) {
 				out.Push(i)
 				continue
@@ -82,13 +85,18 @@ var controlSplitCfg = &builtinConfig{
 			out.Map("control").PushBOS()
 
 			for {
-				i = in.Stream().Pull()
+				if i, ok = in.Stream().PullOK(); !ok {
+					return
+				}
 				if in.OwnEOS(i) {
 					break
 				}
 
 				dlgOut.Push(i)
-				c := dlgIn.Pull()
+				c, ok := dlgIn.PullOK()
+				if !ok {
+					return
+				}
 
 				cb, ok := c.(bool)
 				if !ok {
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -138,7 +139,7 @@ func Test_CtrlSplit__Forking(t *testing.T) {
 	fop.Main().Out().Bufferize()
 	dlg.Out().Bufferize()
 
-	fop.Start()
+	fop.Start(context.Background())
 
 	items := []interface{}{1.0, 2.0, 3.0, 4.0, 5.0}
 	control := []interface{}{true, false, false, true, true}
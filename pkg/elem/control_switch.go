@@ -81,7 +81,10 @@ var controlSwitchCfg = &builtinConfig{
 			cases[cs] = op.Delegate(cs)
 		}
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -94,7 +97,10 @@ var controlSwitchCfg = &builtinConfig{
 				cs = dflt
 			}
 			cs.Out().Push(im["item"])
-			out.Push(cs.In().Pull())
+			if i, ok = cs.In().PullOK(); !ok {
+				return
+			}
+			out.Push(i)
 		}
 	},
 }
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -121,7 +122,7 @@ func Test_CtrlSwitch__Redirect_Number(t *testing.T) {
 	o.Delegate("5").Out().Bufferize()
 	o.Delegate("12").Out().Bufferize()
 
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Map("item").Push("hallo")
 	o.Main().In().Map("item").Push("slang")
@@ -72,14 +72,23 @@ var controlTakeCfg = &builtinConfig{
 		cIn := op.Delegate("compare").In()
 		cOut := op.Delegate("compare").Out()
 		for !op.CheckStop() {
-			t := in.Map("true").Stream().Pull()
-			f := in.Map("false").Stream().Pull()
+			t, ok := in.Map("true").Stream().PullOK()
+			if !ok {
+				return
+			}
+			f, ok := in.Map("false").Stream().PullOK()
+			if !ok {
+				return
+			}
 
 			if !in.Map("true").OwnBOS(t) {
 				if core.IsMarker(t) {
 					if t == f {
 						cOut.Push(t)
-						sel := cIn.Pull()
+						sel, ok := cIn.PullOK()
+						if !ok {
+							return
+						}
 
 						if sel != t {
 							panic("expected marker")
@@ -104,10 +113,14 @@ var controlTakeCfg = &builtinConfig{
 			f = nil
 			for {
 				if t == nil {
-					t = in.Map("true").Stream().Pull()
+					if t, ok = in.Map("true").Stream().PullOK(); !ok {
+						return
+					}
 				}
 				if f == nil {
-					f = in.Map("false").Stream().Pull()
+					if f, ok = in.Map("false").Stream().PullOK(); !ok {
+						return
+					}
 				}
 
 				if core.IsMarker(t) {
@@ -122,7 +135,9 @@ var controlTakeCfg = &builtinConfig{
 							goto end
 						}
 						out.Stream().Push(f)
-						f = in.Map("false").Stream().Pull()
+						if f, ok = in.Map("false").Stream().PullOK(); !ok {
+							return
+						}
 					}
 				} else if core.IsMarker(f) {
 					if !in.Map("false").OwnEOS(f) {
@@ -136,7 +151,9 @@ var controlTakeCfg = &builtinConfig{
 							goto end
 						}
 						out.Stream().Push(t)
-						t = in.Map("true").Stream().Pull()
+						if t, ok = in.Map("true").Stream().PullOK(); !ok {
+							return
+						}
 					}
 				} else {
 					// Send to comparator
@@ -144,8 +161,11 @@ var controlTakeCfg = &builtinConfig{
 					cOut.Map("false").Push(f)
 
 					// Get result
-					s, ok := cIn.Pull().(bool)
-
+					c, ok := cIn.PullOK()
+					if !ok {
+						return
+					}
+					s, ok := c.(bool)
 					if !ok {
 						panic("expected boolean")
 					}
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -88,7 +89,7 @@ func Test_CtrlTake__Simple1(t *testing.T) {
 
 	to.Main().Out().Bufferize()
 	to.Delegate("compare").Out().Bufferize()
-	to.Start()
+	to.Start(context.Background())
 
 	// Push data
 	to.Main().In().Map("true").Push([]interface{}{1, 2, 3})
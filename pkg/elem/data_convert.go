@@ -37,7 +37,10 @@ var dataConvertCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) || i == nil {
 				out.Push(i)
 				continue
@@ -125,7 +125,10 @@ var dataEvaluateCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 
 			if core.IsMarker(i) {
 				out.Push(i)
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -51,7 +52,7 @@ func Test_DataEvaluate__Add(t *testing.T) {
 	a.NotNil(fo)
 	fo.Main().Out().Bufferize()
 
-	go fo.Start()
+	go fo.Start(context.Background())
 
 	fo.Main().In().Push(map[string]interface{}{"a": 1.0, "b": 2.0})
 	fo.Main().In().Push(map[string]interface{}{"a": -5.0, "b": 2.5})
@@ -73,7 +74,7 @@ func Test_DataEvaluate__Floor(t *testing.T) {
 	a.NotNil(fo)
 	fo.Main().Out().Bufferize()
 
-	go fo.Start()
+	go fo.Start(context.Background())
 
 	fo.Main().In().Push(map[string]interface{}{"a": 1.0})
 	fo.Main().In().Push(map[string]interface{}{"a": 1.1})
@@ -95,7 +96,7 @@ func Test_DataEvaluate__Ceil(t *testing.T) {
 	a.NotNil(fo)
 	fo.Main().Out().Bufferize()
 
-	go fo.Start()
+	go fo.Start(context.Background())
 
 	fo.Main().In().Push(map[string]interface{}{"a": 1.0})
 	fo.Main().In().Push(map[string]interface{}{"a": 1.1})
@@ -117,7 +118,7 @@ func Test_DataEvaluate__IsNull(t *testing.T) {
 	a.NotNil(fo)
 	fo.Main().Out().Bufferize()
 
-	go fo.Start()
+	go fo.Start(context.Background())
 
 	fo.Main().In().Push(map[string]interface{}{"a": 1.0})
 	fo.Main().In().Push(map[string]interface{}{"a": nil})
@@ -139,7 +140,7 @@ func Test_DataEvaluate__Pow(t *testing.T) {
 	a.NotNil(fo)
 	fo.Main().Out().Bufferize()
 
-	go fo.Start()
+	go fo.Start(context.Background())
 
 	fo.Main().In().Push(map[string]interface{}{"a": 2.0, "b": 0.0})
 	fo.Main().In().Push(map[string]interface{}{"a": 1.1, "b": 1.0})
@@ -162,7 +163,7 @@ func Test_DataEvaluate__BoolArith(t *testing.T) {
 	a.NotNil(fo)
 	fo.Main().Out().Bufferize()
 
-	go fo.Start()
+	go fo.Start(context.Background())
 
 	fo.Main().In().Push(map[string]interface{}{"a": true, "b": 1.0, "c": 2.0})
 	fo.Main().In().Push(map[string]interface{}{"a": false, "b": 8.0, "c": 8.0})
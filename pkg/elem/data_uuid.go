@@ -32,7 +32,11 @@ var dataUUIDCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			if i := in.Pull(); !core.IsMarker(i) {
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
+			if !core.IsMarker(i) {
 				out.Push(uuid.New().String())
 			} else {
 				out.Push(i)
@@ -38,7 +38,11 @@ var dataValueCfg = &builtinConfig{
 		out := op.Main().Out()
 		v := op.Property("value")
 		for !op.CheckStop() {
-			if i := in.Pull(); !core.IsMarker(i) {
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
+			if !core.IsMarker(i) {
 				out.Push(v)
 			} else {
 				out.Push(i)
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -82,7 +83,7 @@ func TestBuiltinConst__PushBoolean(t *testing.T) {
 	a.Equal(core.TYPE_BOOLEAN, ao.Main().Out().Type())
 
 	ao.Main().Out().Bufferize()
-	ao.Start()
+	ao.Start(context.Background())
 
 	for i := 0; i < 20; i++ {
 		ao.Main().In().Push(1)
@@ -115,7 +116,7 @@ func TestBuiltinConst__PushStream(t *testing.T) {
 	a.Equal(core.TYPE_STREAM, ao.Main().Out().Type())
 
 	ao.Main().Out().Bufferize()
-	ao.Start()
+	ao.Start(context.Background())
 
 	for i := 0; i < 3; i++ {
 		ao.Main().In().Push(1)
@@ -154,7 +155,7 @@ func TestBuiltinConst__PushMap(t *testing.T) {
 	a.Equal(core.TYPE_MAP, ao.Main().Out().Type())
 
 	ao.Main().Out().Bufferize()
-	ao.Start()
+	ao.Start(context.Background())
 
 	for i := 0; i < 3; i++ {
 		ao.Main().In().Push(1)
@@ -185,7 +186,7 @@ func Test_DataConstant__SimpleNumber(t *testing.T) {
 	a.NotNil(co)
 
 	co.Main().Out().Bufferize()
-	co.Start()
+	co.Start(context.Background())
 
 	co.Main().In().Push(true)
 	a.PortPushesAll([]interface{}{5.0}, co.Main().Out())
@@ -221,7 +222,7 @@ func Test_DataConstant__ComplexStreamMap(t *testing.T) {
 	a.NotNil(co)
 
 	co.Main().Out().Bufferize()
-	co.Start()
+	co.Start(context.Background())
 
 	co.Main().In().Push(true)
 	a.PortPushesAll([]interface{}{[]interface{}{1.0, 2.0, 3.0}}, co.Main().Out().Map("a"))
@@ -247,7 +248,7 @@ func Test_DataConstant__PassMarkers(t *testing.T) {
 	a.NotNil(co)
 
 	co.Main().Out().Bufferize()
-	co.Start()
+	co.Start(context.Background())
 
 	bos := core.BOS{}
 	eos := core.BOS{}
@@ -63,6 +63,7 @@ var databaseExecuteCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork("url"),
 	opFunc: func(op *core.Operator) {
 		query := op.Property("query").(string)
 
@@ -94,7 +95,10 @@ var databaseExecuteCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -53,6 +53,7 @@ var databaseKafkaSubscribeCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork("brokers"),
 	opFunc: func(op *core.Operator) {
 		topic := op.Property("topic").(string)
 		brokers := []string{}
@@ -68,7 +69,10 @@ var databaseKafkaSubscribeCfg = &builtinConfig{
 		outValueStream := out.Stream().Map("value")
 
 		for {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -88,7 +88,10 @@ var databaseMemoryReadCfg = &builtinConfig{
 		ms := getMemoryStore(store)
 
 		for {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -103,7 +106,11 @@ var databaseMemoryReadCfg = &builtinConfig{
 				out.Push(value)
 			} else {
 				creatorOut.Push(keyValue)
-				value := creatorIn.Pull()
+				value, ok := creatorIn.PullOK()
+				if !ok {
+					ms.mutex.Unlock()
+					return
+				}
 				ms.items[key] = value
 				out.Push(value)
 			}
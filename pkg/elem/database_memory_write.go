@@ -50,7 +50,10 @@ var databaseMemoryWriteCfg = &builtinConfig{
 		ms := getMemoryStore(store)
 
 		for {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -71,6 +71,7 @@ var databaseQueryCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork("url"),
 	opFunc: func(op *core.Operator) {
 		query := op.Property("query").(string)
 
@@ -107,7 +108,10 @@ var databaseQueryCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -35,6 +35,7 @@ var databaseRedisGetCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork("host"),
 	opFunc: func(op *core.Operator) {
 		host := op.Property("host").(string)
 		password := op.Property("password").(string)
@@ -48,7 +49,10 @@ var databaseRedisGetCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -60,6 +60,7 @@ var databaseRedisHGetCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork("host"),
 	opFunc: func(op *core.Operator) {
 		host := op.Property("host").(string)
 		password := op.Property("password").(string)
@@ -73,7 +74,10 @@ var databaseRedisHGetCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -89,7 +93,10 @@ var databaseRedisHGetCfg = &builtinConfig{
 				out.Push(value)
 			} else {
 				op.Delegate("creator").Out().Push(pair)
-				value := op.Delegate("creator").In().Pull()
+				value, ok := op.Delegate("creator").In().PullOK()
+				if !ok {
+					return
+				}
 
 				boolCmd := client.HSet(key, field, value)
 
@@ -46,6 +46,7 @@ var databaseRedisHIncrByCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork("host"),
 	opFunc: func(op *core.Operator) {
 		host := op.Property("host").(string)
 		password := op.Property("password").(string)
@@ -59,7 +60,10 @@ var databaseRedisHIncrByCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
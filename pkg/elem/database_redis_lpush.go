@@ -43,6 +43,7 @@ var databaseRedisLPushCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork("host"),
 	opFunc: func(op *core.Operator) {
 		host := op.Property("host").(string)
 		password := op.Property("password").(string)
@@ -56,7 +57,10 @@ var databaseRedisLPushCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -56,6 +56,9 @@ var encodingCSVReadCfg = &builtinConfig{
 		out := op.Main().Out()
 		for !op.CheckStop() {
 			csvText, marker := in.PullString()
+			if op.CheckStop() {
+				return
+			}
 			if marker != nil {
 				out.Push(marker)
 				continue
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -47,7 +48,7 @@ func TestOperatorCSVRead__3Lines(t *testing.T) {
 	r.NotNil(co)
 
 	co.Main().Out().Bufferize()
-	co.Start()
+	co.Start(context.Background())
 
 	co.Main().In().Push("a,b,c\ne,f,g\nh,i,j")
 
@@ -89,7 +90,7 @@ func TestOperatorCSVRead__DifferentOrder(t *testing.T) {
 	r.NotNil(co)
 
 	co.Main().Out().Bufferize()
-	co.Start()
+	co.Start(context.Background())
 
 	co.Main().In().Push("b,c,a\ne,f,g\nh,i,j")
 
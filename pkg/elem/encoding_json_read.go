@@ -44,7 +44,10 @@ var encodingJSONReadCfg = &builtinConfig{
 		def, _ := op.Define()
 		itemDef := def.ServiceDefs[core.MAIN_SERVICE].Out.Map["item"]
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -31,7 +32,7 @@ func Test_JsonRead__String(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 	o.Main().In().Push(core.Binary("\"test\""))
 	a.PortPushes("test", o.Main().Out().Map("item"))
 	a.PortPushes(true, o.Main().Out().Map("valid"))
@@ -61,7 +62,7 @@ func Test_JsonRead__Invalid(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 	o.Main().In().Push(core.Binary("\"test\""))
 	a.PortPushes(nil, o.Main().Out().Map("item").Map("a"))
 	a.PortPushes(nil, o.Main().Out().Map("item").Map("b"))
@@ -95,7 +96,7 @@ func Test_JsonRead__Complex(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 	o.Main().In().Push(core.Binary("{\"a\":[1,2,3],\"b\":true}"))
 	a.PortPushes(map[string]interface{}{"a": []interface{}{1.0, 2.0, 3.0}, "b": true}, o.Main().Out().Map("item"))
 	a.PortPushes(true, o.Main().Out().Map("valid"))
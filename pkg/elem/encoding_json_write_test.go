@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -31,7 +32,7 @@ func Test_JsonWrite__String(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 	o.Main().In().Push("test")
 	a.PortPushes(core.Binary("\"test\""), o.Main().Out())
 }
@@ -49,6 +49,9 @@ var encodingXLSXReadCfg = &builtinConfig{
 		out := op.Main().Out()
 		for !op.CheckStop() {
 			b, i := in.PullBinary()
+			if op.CheckStop() {
+				return
+			}
 			if i != nil {
 				out.Push(i)
 				continue
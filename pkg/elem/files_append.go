@@ -40,12 +40,16 @@ var filesAppendCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresFilesystem,
 	opFunc: func(op *core.Operator) {
 		in := op.Main().In()
 		out := op.Main().Out()
 		newLine := op.Property("newLine").(bool)
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -61,6 +65,11 @@ var filesAppendCfg = &builtinConfig{
 			}
 			filename := data["filename"].(string)
 
+			if err := checkPath(filename); err != nil {
+				out.Push(err.Error())
+				continue
+			}
+
 			f, err := os.OpenFile(filepath.Clean(filename), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
 			if err != nil {
 				f.Close()
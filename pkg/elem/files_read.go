@@ -39,11 +39,15 @@ var filesReadCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresFilesystem,
 	opFunc: func(op *core.Operator) {
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
 			file, marker := in.PullString()
+			if op.CheckStop() {
+				return
+			}
 			if marker != nil {
 				out.Push(marker)
 				continue
@@ -55,6 +59,11 @@ var filesReadCfg = &builtinConfig{
 				dir := usr.HomeDir
 				path = filepath.Join(dir, path[1:])
 			}
+			if err := checkPath(path); err != nil {
+				out.Map("content").Push(nil)
+				out.Map("error").Push(err.Error())
+				continue
+			}
 			content, err := ioutil.ReadFile(path)
 			if err != nil {
 				out.Map("content").Push(nil)
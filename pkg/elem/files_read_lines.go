@@ -28,19 +28,31 @@ var filesReadLinesCfg = &builtinConfig{
 					},
 				},
 				Out: core.TypeDef{
-					Type: "stream",
-					Stream: &core.TypeDef{
-						Type: "string",
+					Type: "map",
+					Map: map[string]*core.TypeDef{
+						"lines": {
+							Type: "stream",
+							Stream: &core.TypeDef{
+								Type: "string",
+							},
+						},
+						"error": {
+							Type: "string",
+						},
 					},
 				},
 			},
 		},
 	},
+	opCapsFunc: requiresFilesystem,
 	opFunc: func(op *core.Operator) {
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -49,20 +61,28 @@ var filesReadLinesCfg = &builtinConfig{
 			data := i.(map[string]interface{})
 			filename := data["filename"].(string)
 
+			if err := checkPath(filename); err != nil {
+				out.Map("lines").Push(nil)
+				out.Map("error").Push(err.Error())
+				continue
+			}
+
 			f, err := os.Open(filepath.Clean(filename))
 			if err != nil {
-				f.Close()
-				out.Push(err.Error())
+				out.Map("lines").Push(nil)
+				out.Map("error").Push(err.Error())
 				continue
 			}
 
 			buf := bufio.NewReader(f)
 
-			out.PushBOS()
+			lines := out.Map("lines")
+			lines.PushBOS()
 			for line, _, err := buf.ReadLine(); err == nil; line, _, err = buf.ReadLine() {
-				out.Stream().Push(string(line))
+				lines.Stream().Push(string(line))
 			}
-			out.PushEOS()
+			lines.PushEOS()
+			out.Map("error").Push(nil)
 
 			f.Close()
 		}
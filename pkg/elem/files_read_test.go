@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -56,7 +57,7 @@ func Test_FileRead__Simple(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push("../../tests/test_data/hello.txt")
 	a.Equal(core.Binary("hello slang"), o.Main().Out().Map("content").Pull())
@@ -74,7 +75,7 @@ func Test_FileRead__NotFound(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push("./tests/test_data/nonexistentfile")
 	a.Nil(o.Main().Out().Map("content").Pull())
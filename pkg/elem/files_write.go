@@ -36,11 +36,15 @@ var filesWriteCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresFilesystem,
 	opFunc: func(op *core.Operator) {
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -56,6 +60,11 @@ var filesWriteCfg = &builtinConfig{
 			}
 			filename := data["filename"].(string)
 
+			if err := checkPath(filename); err != nil {
+				out.Push(err.Error())
+				continue
+			}
+
 			err := ioutil.WriteFile(filepath.Clean(filename), content, os.ModePerm)
 
 			if err == nil {
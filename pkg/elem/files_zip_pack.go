@@ -42,7 +42,10 @@ var filesZIPPackCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Stream().Pull()
+			i, ok := in.Stream().PullOK()
+			if !ok {
+				return
+			}
 			if !in.OwnBOS(i) {
 				out.Push(i)
 				continue
@@ -52,7 +55,9 @@ var filesZIPPackCfg = &builtinConfig{
 			zipWriter := zip.NewWriter(buf)
 
 			for {
-				i = in.Pull()
+				if i, ok = in.PullOK(); !ok {
+					return
+				}
 				if in.OwnEOS(i) {
 					break
 				}
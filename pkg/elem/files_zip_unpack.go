@@ -42,7 +42,10 @@ var filesZIPUnpackCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) || i == nil {
 				out.Push(i)
 				continue
@@ -71,7 +71,10 @@ var imageEncodeCfg = &builtinConfig{
 		out := op.Main().Out()
 		formats := []string{"jpeg", "png"}
 		for !op.CheckStop() {
-			i := in.Map("height").Pull()
+			i, ok := in.Map("height").PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) || i == nil {
 				in.Map("width").Pull()
 				in.Map("format").Pull()
@@ -81,8 +84,16 @@ var imageEncodeCfg = &builtinConfig{
 			}
 
 			height := i.(float64)
-			width := in.Map("width").Pull().(float64)
-			format := in.Map("format").Pull().(string)
+			w, ok := in.Map("width").PullOK()
+			if !ok {
+				return
+			}
+			f, ok := in.Map("format").PullOK()
+			if !ok {
+				return
+			}
+			width := w.(float64)
+			format := f.(string)
 
 			if !funk.Contains(formats, format) {
 				in.Map("pixels").Pull()
@@ -90,12 +101,18 @@ var imageEncodeCfg = &builtinConfig{
 				continue
 			}
 
-			in.Map("pixels").PullBOS()
+			if !in.Map("pixels").PullBOS() {
+				return
+			}
 			pixelStream := in.Map("pixels").Stream()
 			img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
 			for y := 0; y < int(height); y++ {
 				for x := 0; x < int(width); x++ {
-					pixel := pixelStream.Pull().(map[string]interface{})
+					p, ok := pixelStream.PullOK()
+					if !ok {
+						return
+					}
+					pixel := p.(map[string]interface{})
 					img.Set(x, y,
 						color.RGBA64{
 							R: uint16(pixel["red"].(float64)),
@@ -106,7 +123,9 @@ var imageEncodeCfg = &builtinConfig{
 					)
 				}
 			}
-			in.Map("pixels").PullEOS()
+			if !in.Map("pixels").PullEOS() {
+				return
+			}
 
 			var b bytes.Buffer
 			writer := bufio.NewWriter(&b)
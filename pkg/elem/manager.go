@@ -2,20 +2,28 @@ package elem
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
 	"github.com/Bitspark/go-funk"
 	"github.com/Bitspark/slang/pkg/core"
 	"github.com/google/uuid"
-	"sync"
 )
 
 type builtinConfig struct {
 	opConnFunc core.CFunc
 	opFunc     core.OFunc
+	opCapsFunc capsFunc
 	opDef      core.OperatorDef
 }
 
 var cfgs map[uuid.UUID]*builtinConfig
 var name2Id map[string]uuid.UUID
+var disabled map[uuid.UUID]bool
+
+// cfgsMutex guards cfgs, name2Id and disabled, as plugins may register operators while others are built
+var cfgsMutex *sync.RWMutex
 
 func MakeOperator(def core.InstanceDef) (*core.Operator, error) {
 	cfg := getBuiltinCfg(def.Operator)
@@ -24,6 +32,14 @@ func MakeOperator(def core.InstanceDef) (*core.Operator, error) {
 		return nil, errors.New("unknown builtin operator")
 	}
 
+	if IsDisabled(def.Operator) {
+		return nil, errors.New("builtin operator disabled: " + cfg.opDef.Meta.Name)
+	}
+
+	if err := checkPolicy(cfg, def); err != nil {
+		return nil, err
+	}
+
 	if err := def.OperatorDef.GenericsSpecified(); err != nil {
 		return nil, err
 	}
@@ -37,6 +53,12 @@ func MakeOperator(def core.InstanceDef) (*core.Operator, error) {
 }
 
 func GetId(idOrName string) uuid.UUID {
+	cfgsMutex.RLock()
+	defer cfgsMutex.RUnlock()
+	return getId(idOrName)
+}
+
+func getId(idOrName string) uuid.UUID {
 	if id, ok := name2Id[idOrName]; ok {
 		return id
 	}
@@ -45,9 +67,9 @@ func GetId(idOrName string) uuid.UUID {
 }
 
 func GetOperatorDef(idOrName string) (*core.OperatorDef, error) {
-	cfg, ok := cfgs[GetId(idOrName)]
+	cfg := getBuiltinCfg(idOrName)
 
-	if !ok {
+	if cfg == nil {
 		return nil, errors.New("builtin operator not found")
 	}
 
@@ -56,107 +78,203 @@ func GetOperatorDef(idOrName string) (*core.OperatorDef, error) {
 }
 
 func IsRegistered(idOrName string) bool {
-	_, b := cfgs[GetId(idOrName)]
-	return b
+	return getBuiltinCfg(idOrName) != nil
+}
+
+// Register adds an elementary operator implemented in Go, e.g. by a plugin. The definition needs an id and a name
+// not used by any other elementary operator, a main service and must not contain instances. The connect function c
+// is optional. Operators have to be registered before operators using them are built, typically at startup.
+// Operators accessing the host system have to declare their capabilities with RegisterCapabilities right after.
+func Register(def core.OperatorDef, f core.OFunc, c core.CFunc) error {
+	if f == nil {
+		return errors.New("operator function missing")
+	}
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	if strings.TrimSpace(def.Meta.Name) == "" {
+		return errors.New("operator name missing")
+	}
+	if _, ok := def.ServiceDefs[core.MAIN_SERVICE]; !ok {
+		return fmt.Errorf("%s: main service missing", def.Meta.Name)
+	}
+	if len(def.InstanceDefs) != 0 || len(def.Connections) != 0 {
+		return fmt.Errorf("%s: elementary operators must not contain instances or connections", def.Meta.Name)
+	}
+
+	cfgsMutex.Lock()
+	defer cfgsMutex.Unlock()
+
+	id := uuid.MustParse(def.Id)
+	if other, ok := cfgs[id]; ok {
+		return fmt.Errorf("%s: id %s already registered for %s", def.Meta.Name, id, other.opDef.Meta.Name)
+	}
+	if _, ok := name2Id[def.Meta.Name]; ok {
+		return fmt.Errorf("%s: name already registered", def.Meta.Name)
+	}
+
+	register(&builtinConfig{
+		opDef:      def.Copy(true),
+		opFunc:     f,
+		opConnFunc: c,
+	})
+	return nil
+}
+
+// RegisterCapabilities declares capabilities of an elementary operator, they are checked against the policy when
+// building the operator, in addition to capabilities declared before. Values of capabilities are fixed, so operators
+// accessing paths or hosts only known later have to declare the type only and access them after checking them with
+// CheckPath or CheckURL.
+func RegisterCapabilities(idOrName string, caps ...Capability) error {
+	for _, c := range caps {
+		switch c.Type {
+		case CAP_FILESYSTEM, CAP_NETWORK, CAP_PROCESS:
+		default:
+			return fmt.Errorf("%s: unknown capability %s", idOrName, c.Type)
+		}
+	}
+
+	cfgsMutex.Lock()
+	defer cfgsMutex.Unlock()
+
+	cfg, ok := cfgs[getId(idOrName)]
+	if !ok {
+		return errors.New("builtin operator not found")
+	}
+
+	caps = append([]Capability(nil), caps...)
+	declared := cfg.opCapsFunc
+	cfg.opCapsFunc = func(props core.Properties) []Capability {
+		if declared == nil {
+			return caps
+		}
+		return append(declared(props), caps...)
+	}
+	return nil
 }
 
-func Register(cfg *builtinConfig) {
+func register(cfg *builtinConfig) {
 	cfg.opDef.Elementary = cfg.opDef.Id
 
-	id := GetId(cfg.opDef.Id)
+	id := getId(cfg.opDef.Id)
 	cfgs[id] = cfg
 	name2Id[cfg.opDef.Meta.Name] = id
 }
 
+// Disable prevents the builtin operator from being instantiated, e.g. to deny access to the host system
+func Disable(idOrName string) error {
+	cfgsMutex.Lock()
+	defer cfgsMutex.Unlock()
+
+	id := getId(idOrName)
+	if _, ok := cfgs[id]; !ok {
+		return errors.New("builtin operator not found")
+	}
+	disabled[id] = true
+	return nil
+}
+
+func IsDisabled(idOrName string) bool {
+	cfgsMutex.RLock()
+	defer cfgsMutex.RUnlock()
+	return disabled[getId(idOrName)]
+}
+
 func GetBuiltinIds() []uuid.UUID {
+	cfgsMutex.RLock()
+	defer cfgsMutex.RUnlock()
 	return funk.Keys(cfgs).([]uuid.UUID)
 }
 
 func init() {
+	cfgsMutex = &sync.RWMutex{}
 	cfgs = make(map[uuid.UUID]*builtinConfig)
 	name2Id = make(map[string]uuid.UUID)
+	disabled = make(map[uuid.UUID]bool)
 
-	Register(metaStoreCfg)
+	register(metaStoreCfg)
 
 	// Data manipulating operators
-	Register(dataValueCfg)
-	Register(dataEvaluateCfg)
-	Register(dataConvertCfg)
-	Register(dataUUIDCfg)
+	register(dataValueCfg)
+	register(dataEvaluateCfg)
+	register(dataConvertCfg)
+	register(dataUUIDCfg)
 
 	// Flow control operators
-	Register(controlSplitCfg)
-	Register(controlSwitchCfg)
-	Register(controlTakeCfg)
-	Register(controlLoopCfg)
-	Register(controlIterateCfg)
-	Register(controlReduceCfg)
-	Register(controlSemaphorePCfg)
-	Register(controlSemaphoreVCfg)
+	register(controlSplitCfg)
+	register(controlSwitchCfg)
+	register(controlTakeCfg)
+	register(controlLoopCfg)
+	register(controlIterateCfg)
+	register(controlReduceCfg)
+	register(controlSemaphorePCfg)
+	register(controlSemaphoreVCfg)
 
 	// Stream accessing and processing operators
-	Register(streamSerializeCfg)
-	Register(streamParallelizeCfg)
-	Register(streamConcatenateCfg)
-	Register(streamMapAccessCfg)
-	Register(streamWindowCfg)
-	Register(streamWindowCollectCfg)
-	Register(streamWindowReleaseCfg)
-	Register(streamMapToStreamCfg)
-	Register(streamStreamToMapCfg)
-	Register(streamSliceCfg)
-	Register(streamTransformCfg)
-	Register(streamDistinctCfg)
+	register(streamSerializeCfg)
+	register(streamParallelizeCfg)
+	register(streamConcatenateCfg)
+	register(streamMapAccessCfg)
+	register(streamWindowCfg)
+	register(streamWindowCollectCfg)
+	register(streamWindowReleaseCfg)
+	register(streamMapToStreamCfg)
+	register(streamStreamToMapCfg)
+	register(streamSliceCfg)
+	register(streamTransformCfg)
+	register(streamDistinctCfg)
 
 	// Miscellaneous operators
-	Register(netHTTPServerCfg)
-	Register(netHTTPClientCfg)
-	Register(netSendEmailCfg)
-	Register(netMQTTPublishCfg)
-	Register(netMQTTSubscribeCfg)
-
-	Register(filesReadCfg)
-	Register(filesWriteCfg)
-	Register(filesAppendCfg)
-	Register(filesReadLinesCfg)
-	Register(filesZIPPackCfg)
-	Register(filesZIPUnpackCfg)
-
-	Register(encodingCSVReadCfg)
-	Register(encodingJSONReadCfg)
-	Register(encodingJSONWriteCfg)
-	Register(encodingXLSXReadCfg)
-	Register(encodingURLWriteCfg)
-
-	Register(timeDelayCfg)
-	Register(timeCrontabCfg)
-	Register(timeParseDateCfg)
-	Register(timeDateNowCfg)
-	Register(timeUNIXMillisCfg)
-
-	Register(stringTemplateCfg)
-	Register(stringFormatCfg)
-	Register(stringSplitCfg)
-	Register(stringBeginswithCfg)
-	Register(stringContainsCfg)
-	Register(stringEndswithCfg)
-
-	Register(databaseQueryCfg)
-	Register(databaseExecuteCfg)
-	Register(databaseKafkaSubscribeCfg)
-	Register(databaseRedisGetCfg)
-	Register(databaseRedisSetCfg)
-	Register(databaseRedisHGetCfg)
-	Register(databaseRedisHSetCfg)
-	Register(databaseRedisLPushCfg)
-	Register(databaseRedisHIncrByCfg)
-	Register(databaseMemoryReadCfg)
-	Register(databaseMemoryWriteCfg)
-
-	Register(imageDecodeCfg)
-	Register(imageEncodeCfg)
-
-	Register(shellExecuteCfg)
+	register(netHTTPServerCfg)
+	register(netHTTPClientCfg)
+	register(netWebSocketServerCfg)
+	register(netWebSocketClientCfg)
+	register(netSendEmailCfg)
+	register(netMQTTPublishCfg)
+	register(netMQTTSubscribeCfg)
+
+	register(filesReadCfg)
+	register(filesWriteCfg)
+	register(filesAppendCfg)
+	register(filesReadLinesCfg)
+	register(filesZIPPackCfg)
+	register(filesZIPUnpackCfg)
+
+	register(encodingCSVReadCfg)
+	register(encodingJSONReadCfg)
+	register(encodingJSONWriteCfg)
+	register(encodingXLSXReadCfg)
+	register(encodingURLWriteCfg)
+
+	register(timeDelayCfg)
+	register(timeCrontabCfg)
+	register(timeParseDateCfg)
+	register(timeDateNowCfg)
+	register(timeUNIXMillisCfg)
+
+	register(stringTemplateCfg)
+	register(stringFormatCfg)
+	register(stringSplitCfg)
+	register(stringBeginswithCfg)
+	register(stringContainsCfg)
+	register(stringEndswithCfg)
+
+	register(databaseQueryCfg)
+	register(databaseExecuteCfg)
+	register(databaseKafkaSubscribeCfg)
+	register(databaseRedisGetCfg)
+	register(databaseRedisSetCfg)
+	register(databaseRedisHGetCfg)
+	register(databaseRedisHSetCfg)
+	register(databaseRedisLPushCfg)
+	register(databaseRedisHIncrByCfg)
+	register(databaseMemoryReadCfg)
+	register(databaseMemoryWriteCfg)
+
+	register(imageDecodeCfg)
+	register(imageEncodeCfg)
+
+	register(shellExecuteCfg)
 
 	windowStores = make(map[string]*windowStore)
 	windowMutex = &sync.Mutex{}
@@ -169,7 +287,9 @@ func init() {
 }
 
 func getBuiltinCfg(id string) *builtinConfig {
-	c, _ := cfgs[GetId(id)]
+	cfgsMutex.RLock()
+	defer cfgsMutex.RUnlock()
+	c, _ := cfgs[getId(id)]
 	return c
 }
 
@@ -182,6 +302,7 @@ func buildOperator(insDef core.InstanceDef) (*core.Operator, error) {
 		return nil, err
 	}
 
+	insDef.Properties = opDef.ApplyPropertyDefaults(insDef.Properties)
 	if err = opDef.SpecifyOperator(insDef.Generics, insDef.Properties); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,155 @@
+package elem
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func doubleOperatorDef() core.OperatorDef {
+	return core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "test double"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {In: core.TypeDef{Type: "number"}, Out: core.TypeDef{Type: "number"}},
+		},
+	}
+}
+
+func doubleOperator(op *core.Operator) {
+	in := op.Main().In()
+	out := op.Main().Out()
+	for !op.CheckStop() {
+		f, marker := in.PullFloat64()
+		if marker != nil {
+			out.Push(marker)
+			continue
+		}
+		out.Push(2 * f)
+	}
+}
+
+func unregister(id string) {
+	cfg := getBuiltinCfg(id)
+	cfgsMutex.Lock()
+	defer cfgsMutex.Unlock()
+	delete(name2Id, cfg.opDef.Meta.Name)
+	delete(cfgs, getId(id))
+}
+
+func Test_Manager__Register(t *testing.T) {
+	a := assertions.New(t)
+
+	def := doubleOperatorDef()
+	require.NoError(t, Register(def, doubleOperator, nil))
+	defer unregister(def.Id)
+
+	a.True(IsRegistered(def.Id))
+	a.True(IsRegistered("test double"))
+
+	o, err := buildOperator(core.InstanceDef{Operator: def.Id})
+	require.NoError(t, err)
+	o.Main().Out().Bufferize()
+	o.Start(context.Background())
+	defer o.Stop()
+
+	o.Main().In().Push(21.0)
+	a.Equal(42.0, o.Main().Out().Pull())
+}
+
+func Test_Manager__RegisterConflicts(t *testing.T) {
+	a := assertions.New(t)
+
+	def := doubleOperatorDef()
+	require.NoError(t, Register(def, doubleOperator, nil))
+	defer unregister(def.Id)
+
+	a.Error(Register(def, doubleOperator, nil))
+
+	sameName := doubleOperatorDef()
+	a.Error(Register(sameName, doubleOperator, nil))
+
+	builtinId := doubleOperatorDef()
+	builtinId.Id = dataEvaluateId
+	builtinId.Meta.Name = "evaluate again"
+	a.Error(Register(builtinId, doubleOperator, nil))
+}
+
+func Test_Manager__RegisterInvalid(t *testing.T) {
+	a := assertions.New(t)
+
+	a.Error(Register(doubleOperatorDef(), nil, nil))
+
+	noId := doubleOperatorDef()
+	noId.Id = "double"
+	a.Error(Register(noId, doubleOperator, nil))
+
+	noName := doubleOperatorDef()
+	noName.Meta.Name = " "
+	a.Error(Register(noName, doubleOperator, nil))
+
+	noMain := doubleOperatorDef()
+	noMain.ServiceDefs = map[string]*core.ServiceDef{"other": noMain.ServiceDefs[core.MAIN_SERVICE]}
+	a.Error(Register(noMain, doubleOperator, nil))
+
+	instances := doubleOperatorDef()
+	instances.InstanceDefs = core.InstanceDefList{{Name: "eval", Operator: dataEvaluateId}}
+	a.Error(Register(instances, doubleOperator, nil))
+
+	a.Error(RegisterCapabilities(dataEvaluateId, Capability{Type: "everything"}))
+	a.Error(RegisterCapabilities(uuid.New().String(), Capability{Type: CAP_NETWORK}))
+}
+
+func Test_Manager__RegisterCapabilities(t *testing.T) {
+	a := assertions.New(t)
+
+	def := doubleOperatorDef()
+	require.NoError(t, Register(def, doubleOperator, nil))
+	defer unregister(def.Id)
+	require.NoError(t, RegisterCapabilities(def.Id, Capability{CAP_NETWORK, "api.example.com"}))
+
+	SetPolicy(&Policy{Network: []string{"other.example.com"}})
+	defer SetPolicy(nil)
+	_, err := buildOperator(core.InstanceDef{Operator: def.Id})
+	a.Error(err)
+
+	SetPolicy(&Policy{Network: []string{"api.example.com"}})
+	_, err = buildOperator(core.InstanceDef{Operator: def.Id})
+	a.NoError(err)
+}
+
+func Test_Manager__RegisterConcurrently(t *testing.T) {
+	a := assertions.New(t)
+
+	defs := make([]core.OperatorDef, 10)
+	for i := range defs {
+		defs[i] = doubleOperatorDef()
+		defs[i].Meta.Name = fmt.Sprintf("test double %d", i)
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, def := range defs {
+		wg.Add(2)
+		go func(def core.OperatorDef) {
+			defer wg.Done()
+			a.NoError(Register(def, doubleOperator, nil))
+		}(def)
+		go func() {
+			defer wg.Done()
+			a.True(IsRegistered("evaluate"))
+			GetBuiltinIds()
+		}()
+	}
+	wg.Wait()
+
+	for _, def := range defs {
+		a.True(IsRegistered(def.Meta.Name))
+		unregister(def.Id)
+	}
+}
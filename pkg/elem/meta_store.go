@@ -20,8 +20,12 @@ func (s store) attachPort(p *core.Port) {
 			items: []interface{}{},
 		}
 		go func() {
-			for !p.Operator().Stopped() {
-				s[p].items = append(s[p].items, p.Pull())
+			for {
+				i, ok := p.PullOK()
+				if !ok {
+					return
+				}
+				s[p].items = append(s[p].items, i)
 			}
 		}()
 	} else if p.Type() == core.TYPE_MAP {
@@ -153,7 +157,9 @@ var metaStoreCfg = &builtinConfig{
 		store.attachPort(in)
 
 		for !op.CheckStop() {
-			queryIn.Pull()
+			if _, ok := queryIn.PullOK(); !ok {
+				return
+			}
 			store.resetIndexes()
 			obj := []interface{}{}
 			for {
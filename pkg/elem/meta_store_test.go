@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -30,7 +31,7 @@ func Test_MetaStore__Single(t *testing.T) {
 		},
 	)
 	require.NoError(t, err)
-	o.Start()
+	o.Start(context.Background())
 
 	querySrv := o.Service("query")
 
@@ -73,7 +74,7 @@ func Test_MetaStore__Stream(t *testing.T) {
 		},
 	)
 	require.NoError(t, err)
-	o.Start()
+	o.Start(context.Background())
 
 	querySrv := o.Service("query")
 
@@ -131,7 +132,7 @@ func Test_MetaStore__Map(t *testing.T) {
 		},
 	)
 	require.NoError(t, err)
-	o.Start()
+	o.Start(context.Background())
 
 	querySrv := o.Service("query")
 
@@ -207,7 +208,7 @@ func Test_MetaStore__StreamMap(t *testing.T) {
 		},
 	)
 	require.NoError(t, err)
-	o.Start()
+	o.Start(context.Background())
 
 	querySrv := o.Service("query")
 
@@ -298,7 +299,7 @@ func Test_MetaStore__MapStream(t *testing.T) {
 		},
 	)
 	require.NoError(t, err)
-	o.Start()
+	o.Start(context.Background())
 
 	querySrv := o.Service("query")
 
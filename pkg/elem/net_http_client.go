@@ -2,6 +2,7 @@ package elem
 
 import (
 	"bytes"
+	"errors"
 	"github.com/Bitspark/slang/pkg/core"
 	"io/ioutil"
 	"net/http"
@@ -27,15 +28,39 @@ var netHTTPClientCfg = &builtinConfig{
 					req.Map["url"] = &core.TypeDef{Type: "string"}
 					return req
 				}(),
-				Out: HTTP_RESPONSE_DEF.Copy(),
+				Out: func() core.TypeDef {
+					resp := HTTP_RESPONSE_DEF.Copy()
+					resp.Map["error"] = &core.TypeDef{Type: "string"}
+					return resp
+				}(),
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork(),
 	opFunc: func(op *core.Operator) {
 		in := op.Main().In()
 		out := op.Main().Out()
+		client := &http.Client{
+			// Redirects must not lead to hosts forbidden by the policy
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return errors.New("stopped after 10 redirects")
+				}
+				return checkURL(req.URL.String())
+			},
+		}
+		pushError := func(err error) {
+			out.Map("status").Push(nil)
+			out.Map("headers").PushBOS()
+			out.Map("headers").PushEOS()
+			out.Map("body").Push(nil)
+			out.Map("error").Push(err.Error())
+		}
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -49,7 +74,7 @@ var netHTTPClientCfg = &builtinConfig{
 
 			r, err := http.NewRequest(method, url, bytes.NewReader(body))
 			if err != nil {
-				out.Push(nil)
+				pushError(err)
 				continue
 			}
 			for _, header := range headers {
@@ -59,15 +84,21 @@ var netHTTPClientCfg = &builtinConfig{
 				}
 			}
 
-			resp, err := http.DefaultClient.Do(r)
+			if err := checkURL(url); err != nil {
+				pushError(err)
+				continue
+			}
+
+			resp, err := client.Do(r)
 			if err != nil {
-				out.Push(nil)
+				pushError(err)
 				continue
 			}
 
 			respBody, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
 			if err != nil {
-				out.Push(nil)
+				pushError(err)
 				continue
 			}
 
@@ -80,6 +111,7 @@ var netHTTPClientCfg = &builtinConfig{
 				out.Map("headers").Stream().Map("value").Push(resp.Header.Get(key))
 			}
 			out.Map("headers").PushEOS()
+			out.Map("error").Push(nil)
 		}
 	},
 }
@@ -2,33 +2,168 @@ package elem
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Bitspark/slang/pkg/core"
 )
 
+// Time granted to running requests when the operator is stopped
+var httpShutdownTimeout = 5 * time.Second
+
+type httpRoute struct {
+	name    string
+	method  string
+	pattern []string
+	sync    *core.Synchronizer
+}
+
+type httpPathParam struct {
+	key   string
+	value string
+}
+
+type httpResponse struct {
+	status  int
+	headers http.Header
+	body    []byte
+}
+
+type corsConfig struct {
+	origins []string
+	methods []string
+	headers []string
+}
+
 type requestHandler struct {
-	hOut *core.Port
-	hIn  *core.Port
-	sync *core.Synchronizer
+	routes      []*httpRoute
+	fallback    *core.Synchronizer
+	cors        *corsConfig
+	maxBodySize int64
+	timeout     time.Duration
+	stop        <-chan struct{}
+}
+
+func newHTTPRoute(name, method, path string) *httpRoute {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "*" {
+		method = ""
+	}
+	return &httpRoute{name: name, method: method, pattern: splitPath(path)}
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// match checks if method and path match this route and returns the path parameters. Path patterns consist of
+// literal segments, parameter segments such as {id} and an optional trailing * matching the rest of the path.
+func (r *httpRoute) match(method string, path string) ([]httpPathParam, bool) {
+	if r.method != "" && r.method != method {
+		return nil, false
+	}
+
+	segments := splitPath(path)
+	params := []httpPathParam{}
+
+	for i, pattern := range r.pattern {
+		if pattern == "*" && i == len(r.pattern)-1 {
+			params = append(params, httpPathParam{"*", strings.Join(segments[i:], "/")})
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(pattern, "{") && strings.HasSuffix(pattern, "}") {
+			params = append(params, httpPathParam{pattern[1 : len(pattern)-1], segments[i]})
+			continue
+		}
+		if pattern != segments[i] {
+			return nil, false
+		}
+	}
+
+	if len(segments) != len(r.pattern) {
+		return nil, false
+	}
+	return params, true
+}
+
+func (c *corsConfig) allowedOrigin(origin string) string {
+	for _, o := range c.origins {
+		if o == "*" {
+			return "*"
+		}
+		if o == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
+// handleCORS sets the CORS headers and returns true in case the request was a preflight request and has been answered
+func (c *corsConfig) handleCORS(resp http.ResponseWriter, req *http.Request) bool {
+	origin := c.allowedOrigin(req.Header.Get("Origin"))
+	if origin == "" {
+		return false
+	}
+
+	resp.Header().Set("Access-Control-Allow-Origin", origin)
+	if origin != "*" {
+		resp.Header().Add("Vary", "Origin")
+	}
+
+	if req.Method != "OPTIONS" {
+		return false
+	}
+
+	methods := "*"
+	if len(c.methods) != 0 {
+		methods = strings.Join(c.methods, ", ")
+	}
+	headers := "Content-Type"
+	if len(c.headers) != 0 {
+		headers = strings.Join(c.headers, ", ")
+	}
+	resp.Header().Set("Access-Control-Allow-Methods", methods)
+	resp.Header().Set("Access-Control-Allow-Headers", headers)
+	resp.WriteHeader(200)
+	resp.Write([]byte{})
+	return true
 }
 
 func (r *requestHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
-	req.ParseForm()
+	if r.cors != nil && r.cors.handleCORS(resp, req) {
+		return
+	}
 
-	// CORS
-	if req.Method == "OPTIONS" {
-		resp.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		resp.Header().Set("Access-Control-Allow-Methods", "*")
-		resp.Header().Set("Access-Control-Allow-Origin", "*")
-		resp.WriteHeader(200)
-		resp.Write([]byte{})
+	body, ok := r.readBody(resp, req)
+	if !ok {
 		return
 	}
+	req.ParseForm()
+
+	sync := r.fallback
+	pathParams := []httpPathParam{}
+	for _, route := range r.routes {
+		if params, ok := route.match(req.Method, req.URL.Path); ok {
+			sync = route.sync
+			pathParams = params
+			break
+		}
+	}
 
-	token := r.sync.Push(func(out *core.Port) {
+	token := sync.Push(func(out *core.Port) {
 		// Push out all request information
 		out.Map("method").Push(req.Method)
 		out.Map("path").Push(req.URL.Path)
@@ -49,6 +184,12 @@ func (r *requestHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request)
 
 		out.Map("params").PushBOS()
 		paramsOut := out.Map("params").Stream()
+		for _, param := range pathParams {
+			paramsOut.Map("key").Push(param.key)
+			paramsOut.Map("values").PushBOS()
+			paramsOut.Map("values").Stream().Push(param.value)
+			paramsOut.Map("values").PushEOS()
+		}
 		for key, vals := range req.Form {
 			paramsOut.Map("key").Push(key)
 			paramsOut.Map("values").PushBOS()
@@ -60,25 +201,163 @@ func (r *requestHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request)
 		}
 		out.Map("params").PushEOS()
 
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(req.Body)
-		out.Map("body").Push(core.Binary(buf.Bytes()))
+		out.Map("body").Push(core.Binary(body))
 	})
 
-	r.sync.Pull(token, func(in *core.Port) {
+	responses := make(chan httpResponse, 1)
+	go sync.Pull(token, func(in *core.Port) {
 		// Gather all response information
 		statusCode, _ := in.Map("status").PullInt()
 
-		headers := in.Map("headers").Pull().([]interface{})
+		header := http.Header{}
+		headersIn, ok := in.Map("headers").PullOK()
+		if !ok {
+			return
+		}
+		headers, _ := headersIn.([]interface{})
 		for _, entry := range headers {
-			header := entry.(map[string]interface{})
-			resp.Header().Set(header["key"].(string), header["value"].(string))
+			h := entry.(map[string]interface{})
+			header.Set(h["key"].(string), h["value"].(string))
 		}
 
-		resp.WriteHeader(statusCode)
 		body, _ := in.Map("body").PullBinary()
-		resp.Write([]byte(body))
+		if in.Operator().CheckStop() {
+			return
+		}
+		responses <- httpResponse{statusCode, header, body}
 	})
+
+	var timeout <-chan time.Time
+	if r.timeout > 0 {
+		timeout = time.After(r.timeout)
+	}
+
+	select {
+	case response := <-responses:
+		for key, vals := range response.headers {
+			resp.Header()[key] = vals
+		}
+		resp.WriteHeader(response.status)
+		resp.Write(response.body)
+	case <-timeout:
+		// The response of the delegate is discarded as soon as it arrives
+		resp.WriteHeader(http.StatusGatewayTimeout)
+	case <-r.stop:
+		resp.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// readBody reads the request body and answers with 413 in case it exceeds the size limit
+func (r *requestHandler) readBody(resp http.ResponseWriter, req *http.Request) ([]byte, bool) {
+	var body io.Reader = req.Body
+	if r.maxBodySize > 0 {
+		body = io.LimitReader(req.Body, r.maxBodySize+1)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(body); err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	if r.maxBodySize > 0 && int64(buf.Len()) > r.maxBodySize {
+		resp.WriteHeader(http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+
+	// Form values of POST requests are parsed from the body
+	req.Body = ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
+	return buf.Bytes(), true
+}
+
+func stringsProperty(v interface{}) []string {
+	strs := []string{}
+	if items, ok := v.([]interface{}); ok {
+		for _, item := range items {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+	}
+	return strs
+}
+
+// corsProperty returns the CORS configuration or nil in case no origins are allowed
+func corsProperty(v interface{}) *corsConfig {
+	cors, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	cfg := &corsConfig{
+		origins: stringsProperty(cors["origins"]),
+		methods: stringsProperty(cors["methods"]),
+		headers: stringsProperty(cors["headers"]),
+	}
+	if len(cfg.origins) == 0 {
+		return nil
+	}
+	return cfg
+}
+
+// serveHTTP runs the server until it fails or the operator is stopped. It serves via TLS in case the operator has
+// certificate and key files set.
+func serveHTTP(op *core.Operator, s *http.Server) error {
+	certFile, _ := op.Property("certFile").(string)
+	keyFile, _ := op.Property("keyFile").(string)
+
+	served := make(chan bool)
+	defer close(served)
+
+	go func() {
+		select {
+		case <-op.Done():
+		case <-served:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			s.Close()
+		}
+	}()
+
+	if certFile != "" && keyFile != "" {
+		return s.ListenAndServeTLS(certFile, keyFile)
+	}
+	return s.ListenAndServe()
+}
+
+func newRequestHandler(op *core.Operator) *requestHandler {
+	handler := &requestHandler{stop: op.Done()}
+
+	slangHandler := op.Delegate("handler")
+	handler.fallback = &core.Synchronizer{}
+	handler.fallback.Init(slangHandler.In(), slangHandler.Out())
+
+	routes, _ := op.Property("routes").([]interface{})
+	for _, r := range routes {
+		rm := r.(map[string]interface{})
+		method, _ := rm["method"].(string)
+		path, _ := rm["path"].(string)
+		route := newHTTPRoute(rm["name"].(string), method, path)
+
+		dlg := op.Delegate(route.name)
+		route.sync = &core.Synchronizer{}
+		route.sync.Init(dlg.In(), dlg.Out())
+		handler.routes = append(handler.routes, route)
+	}
+
+	handler.cors = corsProperty(op.Property("cors"))
+
+	if maxBodySize, ok := op.Property("maxBodySize").(float64); ok {
+		handler.maxBodySize = int64(maxBodySize)
+	}
+
+	if timeout, ok := op.Property("timeout").(float64); ok {
+		handler.timeout = time.Duration(timeout) * time.Millisecond
+	}
+
+	return handler
 }
 
 var netHTTPServerId = "241cc7ef-c6d6-49c1-8729-c5e3c0be8188"
@@ -107,39 +386,120 @@ var netHTTPServerCfg = &builtinConfig{
 				In:  HTTP_RESPONSE_DEF.Copy(),
 				Out: HTTP_REQUEST_DEF.Copy(),
 			},
+			"{routes.name}": {
+				In:  HTTP_RESPONSE_DEF.Copy(),
+				Out: HTTP_REQUEST_DEF.Copy(),
+			},
+		},
+		PropertyDefs: map[string]*core.TypeDef{
+			"routes": {
+				Type: "stream",
+				Stream: &core.TypeDef{
+					Type: "map",
+					Map: map[string]*core.TypeDef{
+						"name": {
+							Type: "string",
+						},
+						"method": {
+							Type: "string",
+						},
+						"path": {
+							Type: "string",
+						},
+					},
+				},
+			},
+			"host": {
+				Type: "string",
+			},
+			"certFile": {
+				Type: "string",
+			},
+			"keyFile": {
+				Type: "string",
+			},
+			"cors": {
+				Type: "map",
+				Map: map[string]*core.TypeDef{
+					"origins": {
+						Type: "stream",
+						Stream: &core.TypeDef{
+							Type: "string",
+						},
+					},
+					"methods": {
+						Type: "stream",
+						Stream: &core.TypeDef{
+							Type: "string",
+						},
+					},
+					"headers": {
+						Type: "stream",
+						Stream: &core.TypeDef{
+							Type: "string",
+						},
+					},
+				},
+			},
+			"maxBodySize": {
+				Type: "number",
+			},
+			"timeout": {
+				Type: "number",
+			},
+		},
+		PropertyDefaults: core.Properties{
+			"routes":   []interface{}{},
+			"host":     "",
+			"certFile": "",
+			"keyFile":  "",
+			// Preflights are answered for all origins unless configured otherwise
+			"cors": map[string]interface{}{
+				"origins": []interface{}{"*"},
+				"methods": []interface{}{},
+				"headers": []interface{}{},
+			},
+			"maxBodySize": 0,
+			"timeout":     0,
 		},
 	},
+	opCapsFunc: requiresNetwork("host"),
 	opFunc: func(op *core.Operator) {
 		in := op.Main().In()
 		out := op.Main().Out()
-		slangHandler := op.Delegate("handler")
-		sync := &core.Synchronizer{}
-		sync.Init(
-			slangHandler.In(),
-			slangHandler.Out())
-		go sync.Worker()
+		handler := newRequestHandler(op)
+
+		go handler.fallback.Worker()
+		for _, route := range handler.routes {
+			go route.sync.Worker()
+		}
+
+		host, _ := op.Property("host").(string)
+
+		writeTimeout := 10 * time.Second
+		if handler.timeout >= writeTimeout {
+			writeTimeout = handler.timeout + time.Second
+		}
 
 		for !op.CheckStop() {
 			port, marker := in.PullInt()
+			if op.CheckStop() {
+				return
+			}
 			if marker != nil {
 				out.Push(marker)
 				continue
 			}
 
 			s := &http.Server{
-				Addr:           ":" + strconv.Itoa(port),
-				Handler:        &requestHandler{sync: sync},
+				Addr:           net.JoinHostPort(host, strconv.Itoa(port)),
+				Handler:        handler,
 				ReadTimeout:    10 * time.Second,
-				WriteTimeout:   10 * time.Second,
+				WriteTimeout:   writeTimeout,
 				MaxHeaderBytes: 1 << 20,
 			}
 
-			go func() {
-				op.WaitForStop()
-				s.Close()
-			}()
-
-			err := s.ListenAndServe()
+			err := serveHTTP(op, s)
 			out.Push(err.Error())
 		}
 	},
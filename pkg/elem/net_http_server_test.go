@@ -2,6 +2,8 @@ package elem
 
 import (
 	"bytes"
+	"context"
+	"net"
 	"net/http"
 	"testing"
 	"time"
@@ -96,7 +98,7 @@ func Test_HTTP__Request(t *testing.T) {
 	handler := o.Delegate("handler")
 	handler.Out().Bufferize()
 
-	o.Start()
+	o.Start(context.Background())
 	o.Main().In().Push(9438)
 
 	done := false
@@ -131,7 +133,7 @@ func Test_HTTP__Response200(t *testing.T) {
 	handler := o.Delegate("handler")
 	handler.Out().Bufferize()
 
-	o.Start()
+	o.Start(context.Background())
 	o.Main().In().Push(9439)
 	handler.In().Push(map[string]interface{}{"status": 200, "headers": []interface{}{}, "body": core.Binary("hallo slang!")})
 
@@ -164,7 +166,7 @@ func Test_HTTP__Response404(t *testing.T) {
 	handler := o.Delegate("handler")
 	handler.Out().Bufferize()
 
-	o.Start()
+	o.Start(context.Background())
 	o.Main().In().Push(9440)
 	handler.In().Push(map[string]interface{}{"status": 404, "headers": []interface{}{}, "body": core.Binary("bye slang!")})
 
@@ -182,3 +184,259 @@ func Test_HTTP__Response404(t *testing.T) {
 	}
 	a.Fail("no response")
 }
+
+func Test_HTTP__RouteMatch(t *testing.T) {
+	a := assertions.New(t)
+
+	r := newHTTPRoute("user", "get", "/users/{id}/files/*")
+
+	params, ok := r.match("GET", "/users/42/files/a/b.txt")
+	a.True(ok)
+	a.Equal([]httpPathParam{{"id", "42"}, {"*", "a/b.txt"}}, params)
+
+	_, ok = r.match("POST", "/users/42/files/a/b.txt")
+	a.False(ok)
+
+	_, ok = r.match("GET", "/users/42")
+	a.False(ok)
+
+	r = newHTTPRoute("users", "*", "/users")
+	_, ok = r.match("DELETE", "/users/")
+	a.True(ok)
+	_, ok = r.match("DELETE", "/users/42")
+	a.False(ok)
+}
+
+func Test_HTTP__RouteDelegates(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator: netHTTPServerId,
+			Properties: core.Properties{"routes": []interface{}{
+				map[string]interface{}{"name": "getUser", "method": "GET", "path": "/users/{id}"},
+				map[string]interface{}{"name": "addUser", "method": "POST", "path": "/users"},
+			}},
+		},
+	)
+	require.NoError(t, err)
+
+	a.NotNil(o.Delegate("handler"))
+	a.NotNil(o.Delegate("getUser"))
+	a.NotNil(o.Delegate("addUser"))
+	a.Equal(core.TYPE_BINARY, o.Delegate("getUser").In().Map("body").Type())
+	a.Equal(core.TYPE_STRING, o.Delegate("addUser").Out().Map("method").Type())
+}
+
+func Test_HTTP__RoutePathParams(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator: netHTTPServerId,
+			Properties: core.Properties{"routes": []interface{}{
+				map[string]interface{}{"name": "getUser", "method": "GET", "path": "/users/{id}"},
+			}},
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Delegate("handler").Out().Bufferize()
+	route := o.Delegate("getUser")
+	route.Out().Bufferize()
+
+	o.Start(context.Background())
+	o.Main().In().Push(9441)
+	route.In().Push(map[string]interface{}{"status": 200, "headers": []interface{}{}, "body": core.Binary("user")})
+
+	for i := 0; i < 5; i++ {
+		resp, _ := http.Get("http://127.0.0.1:9441/users/42")
+		if resp == nil {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		a.Equal([]byte("user"), buf.Bytes())
+		break
+	}
+
+	a.Equal("GET", route.Out().Map("method").Pull())
+	a.Equal("/users/42", route.Out().Map("path").Pull())
+	a.Equal([]interface{}{map[string]interface{}{"key": "id", "values": []interface{}{"42"}}}, route.Out().Map("params").Pull())
+}
+
+func Test_HTTP__Timeout(t *testing.T) {
+	a := assertions.New(t)
+
+	props := core.Properties{"timeout": 50}
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator:   netHTTPServerId,
+			Properties: props,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Delegate("handler").Out().Bufferize()
+
+	o.Start(context.Background())
+	o.Main().In().Push(9442)
+
+	for i := 0; i < 5; i++ {
+		resp, _ := http.Get("http://127.0.0.1:9442/slow")
+		if resp == nil {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		a.Equal(http.StatusGatewayTimeout, resp.StatusCode)
+		return
+	}
+	a.Fail("no response")
+}
+
+func Test_HTTP__MaxBodySize(t *testing.T) {
+	a := assertions.New(t)
+
+	props := core.Properties{"maxBodySize": 4}
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator:   netHTTPServerId,
+			Properties: props,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Delegate("handler").Out().Bufferize()
+
+	o.Start(context.Background())
+	o.Main().In().Push(9443)
+
+	for i := 0; i < 5; i++ {
+		resp, _ := http.Post("http://127.0.0.1:9443/upload", "text/plain", bytes.NewReader([]byte("too large")))
+		if resp == nil {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		a.Equal(http.StatusRequestEntityTooLarge, resp.StatusCode)
+		return
+	}
+	a.Fail("no response")
+}
+
+func Test_HTTP__CORSPreflight(t *testing.T) {
+	a := assertions.New(t)
+
+	props := core.Properties{
+		"cors": map[string]interface{}{
+			"origins": []interface{}{"http://example.com"},
+			"methods": []interface{}{"GET", "POST"},
+			"headers": []interface{}{},
+		},
+	}
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator:   netHTTPServerId,
+			Properties: props,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Delegate("handler").Out().Bufferize()
+
+	o.Start(context.Background())
+	o.Main().In().Push(9444)
+
+	req, _ := http.NewRequest("OPTIONS", "http://127.0.0.1:9444/users", nil)
+	req.Header.Set("Origin", "http://example.com")
+
+	for i := 0; i < 5; i++ {
+		resp, _ := http.DefaultClient.Do(req)
+		if resp == nil {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		a.Equal(200, resp.StatusCode)
+		a.Equal("http://example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+		a.Equal("GET, POST", resp.Header.Get("Access-Control-Allow-Methods"))
+		return
+	}
+	a.Fail("no response")
+}
+
+func Test_HTTP__CORSPreflightDefault(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator: netHTTPServerId,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Delegate("handler").Out().Bufferize()
+
+	o.Start(context.Background())
+	o.Main().In().Push(9451)
+
+	req, _ := http.NewRequest("OPTIONS", "http://127.0.0.1:9451/", nil)
+	req.Header.Set("Origin", "http://example.com")
+
+	for i := 0; i < 5; i++ {
+		resp, _ := http.DefaultClient.Do(req)
+		if resp == nil {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		a.Equal(200, resp.StatusCode)
+		a.Equal("*", resp.Header.Get("Access-Control-Allow-Origin"))
+		a.Equal("*", resp.Header.Get("Access-Control-Allow-Methods"))
+		a.Equal("Content-Type", resp.Header.Get("Access-Control-Allow-Headers"))
+		return
+	}
+	a.Fail("no response")
+}
+
+func Test_HTTP__StopReleasesPort(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator: netHTTPServerId,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Delegate("handler").Out().Bufferize()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o.Start(ctx)
+	o.Main().In().Push(9448)
+
+	served := false
+	for i := 0; i < 50 && !served; i++ {
+		if conn, err := net.Dial("tcp", "127.0.0.1:9448"); err == nil {
+			conn.Close()
+			served = true
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	require.True(t, served)
+
+	cancel()
+	for i := 0; i < 50; i++ {
+		if ln, err := net.Listen("tcp", ":9448"); err == nil {
+			ln.Close()
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	a.Fail("port not released")
+}
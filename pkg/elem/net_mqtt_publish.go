@@ -48,6 +48,7 @@ var netMQTTPublishCfg = &builtinConfig{
 			// },
 		},
 	},
+	opCapsFunc: requiresNetwork("broker"),
 	opFunc: func(op *core.Operator) {
 		options := mqtt.NewClientOptions()
 		options.AddBroker(op.Property("broker").(string))
@@ -65,7 +66,10 @@ var netMQTTPublishCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -54,6 +54,7 @@ var netMQTTSubscribeCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork("broker"),
 	opFunc: func(op *core.Operator) {
 		options := mqtt.NewClientOptions()
 		options.AddBroker(op.Property("broker").(string))
@@ -68,11 +69,14 @@ var netMQTTSubscribeCfg = &builtinConfig{
 		if token.Error() != nil {
 			panic(token.Error())
 		}
+		defer client.Disconnect(250)
 
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			in.Pull()
+			if _, ok := in.PullOK(); !ok {
+				return
+			}
 
 			out.PushBOS()
 			outStream := out.Stream()
@@ -82,7 +86,8 @@ var netMQTTSubscribeCfg = &builtinConfig{
 				outStream.Map("topic").Push(message.Topic())
 			})
 
-			op.WaitForStop()
+			<-op.Done()
+			client.Unsubscribe(topic).Wait()
 			out.PushEOS()
 			break
 		}
@@ -72,6 +72,7 @@ var netSendEmailCfg = &builtinConfig{
 			},
 		},
 	},
+	opCapsFunc: requiresNetwork("server"),
 	opFunc: func(op *core.Operator) {
 		in := op.Main().In()
 		out := op.Main().Out()
@@ -79,7 +80,10 @@ var netSendEmailCfg = &builtinConfig{
 		username := op.Property("username").(string)
 		password := op.Property("password").(string)
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
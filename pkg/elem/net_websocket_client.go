@@ -0,0 +1,149 @@
+package elem
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/gorilla/websocket"
+)
+
+var netWebSocketClientId = "9e1f3c2a-6d0b-4a3e-b5b7-2c8f4d1e7a60"
+var netWebSocketClientCfg = &builtinConfig{
+	opDef: core.OperatorDef{
+		Id: netWebSocketClientId,
+		Meta: core.OperatorMetaDef{
+			Name:             "WebSocket client",
+			ShortDescription: "connects to a WebSocket server, emits received messages and errors and sends messages via the send service",
+			Icon:             "plug",
+			Tags:             []string{"network", "websocket"},
+			DocURL:           "https://bitspark.de/slang/docs/operator/websocket-client",
+		},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {
+				In: core.TypeDef{
+					Type: "string",
+				},
+				Out: core.TypeDef{
+					Type: "map",
+					Map: map[string]*core.TypeDef{
+						"messages": {
+							Type:   "stream",
+							Stream: wsMessageDef(),
+						},
+						"error": {
+							Type: "string",
+						},
+					},
+				},
+			},
+			"send": {
+				In: WEBSOCKET_MESSAGE_DEF.Copy(),
+				Out: core.TypeDef{
+					Type: "string",
+				},
+			},
+		},
+	},
+	opCapsFunc: requiresNetwork(),
+	opFunc: func(op *core.Operator) {
+		in := op.Main().In()
+		out := op.Main().Out()
+		sendIn := op.Service("send").In()
+		sendOut := op.Service("send").Out()
+
+		var conn *websocket.Conn
+		mutex := &sync.Mutex{}
+
+		go func() {
+			for {
+				i, ok := sendIn.PullOK()
+				if !ok {
+					return
+				}
+				if core.IsMarker(i) {
+					sendOut.Push(i)
+					continue
+				}
+				msg := i.(map[string]interface{})
+				text, _ := msg["text"].(bool)
+				data, _ := msg["data"].(core.Binary)
+
+				mutex.Lock()
+				err := errors.New("not connected")
+				if conn != nil {
+					err = conn.WriteMessage(wsMessageType(text), data)
+				}
+				mutex.Unlock()
+
+				if err != nil {
+					sendOut.Push(err.Error())
+				} else {
+					sendOut.Push(nil)
+				}
+			}
+		}()
+
+		go func() {
+			<-op.Done()
+			mutex.Lock()
+			if conn != nil {
+				conn.Close()
+			}
+			mutex.Unlock()
+		}()
+
+		for !op.CheckStop() {
+			url, marker := in.PullString()
+			if op.CheckStop() {
+				return
+			}
+			if marker != nil {
+				out.Push(marker)
+				continue
+			}
+
+			messages := out.Map("messages")
+			messages.PushBOS()
+
+			if err := checkURL(url); err != nil {
+				messages.PushEOS()
+				out.Map("error").Push(err.Error())
+				continue
+			}
+
+			c, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				messages.PushEOS()
+				out.Map("error").Push(err.Error())
+				continue
+			}
+
+			mutex.Lock()
+			conn = c
+			mutex.Unlock()
+
+			var readErr error
+			for {
+				messageType, data, err := c.ReadMessage()
+				if err != nil {
+					readErr = err
+					break
+				}
+				pushWSMessage(messages.Stream(), messageType, data)
+			}
+
+			mutex.Lock()
+			conn = nil
+			mutex.Unlock()
+			c.Close()
+
+			messages.PushEOS()
+			if websocket.IsCloseError(readErr, websocket.CloseNormalClosure, websocket.CloseGoingAway) || op.Stopped() {
+				out.Map("error").Push(nil)
+			} else {
+				out.Map("error").Push(readErr.Error())
+			}
+		}
+	},
+}
@@ -0,0 +1,268 @@
+package elem
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+type wsConnection struct {
+	id    string
+	conn  *websocket.Conn
+	mutex *sync.Mutex
+}
+
+// wsHandler passes each message of all open connections to the handler delegate together with the id of its
+// connection and sends the stream of replies back to that connection. Messages of different connections are
+// interleaved, so that connections are handled concurrently.
+type wsHandler struct {
+	upgrader    websocket.Upgrader
+	sync        *core.Synchronizer
+	connections map[string]*wsConnection
+	mutex       *sync.Mutex
+}
+
+// write sends a single frame, writes of the handler and of broadcasts may happen concurrently
+func (c *wsConnection) write(text bool, data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn.WriteMessage(wsMessageType(text), data)
+}
+
+func wsMessageType(text bool) int {
+	if text {
+		return websocket.TextMessage
+	}
+	return websocket.BinaryMessage
+}
+
+func wsMessageDef() *core.TypeDef {
+	msg := WEBSOCKET_MESSAGE_DEF.Copy()
+	return &msg
+}
+
+func pushWSMessage(p *core.Port, messageType int, data []byte) {
+	p.Map("text").Push(messageType == websocket.TextMessage)
+	p.Map("data").Push(core.Binary(data))
+}
+
+func newWSHandler(op *core.Operator) *wsHandler {
+	dlg := op.Delegate("handler")
+	handler := &wsHandler{
+		sync:        &core.Synchronizer{},
+		connections: make(map[string]*wsConnection),
+		mutex:       &sync.Mutex{},
+	}
+	handler.sync.Init(dlg.In(), dlg.Out())
+
+	// Without configured origins the default same-origin check of the upgrader applies
+	if origins := stringsProperty(op.Property("origins")); len(origins) != 0 {
+		cors := &corsConfig{origins: origins}
+		handler.upgrader.CheckOrigin = func(req *http.Request) bool {
+			return cors.allowedOrigin(req.Header.Get("Origin")) != ""
+		}
+	}
+
+	return handler
+}
+
+func (h *wsHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	conn, err := h.upgrader.Upgrade(resp, req, nil)
+	if err != nil {
+		// The upgrader has already answered the request
+		return
+	}
+	defer conn.Close()
+
+	c := &wsConnection{id: uuid.New().String(), conn: conn, mutex: &sync.Mutex{}}
+	h.mutex.Lock()
+	h.connections[c.id] = c
+	h.mutex.Unlock()
+
+	defer func() {
+		h.mutex.Lock()
+		delete(h.connections, c.id)
+		h.mutex.Unlock()
+	}()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		token := h.sync.Push(func(out *core.Port) {
+			out.Map("connection").Push(c.id)
+			pushWSMessage(out.Map("message"), messageType, data)
+		})
+		go h.sync.Pull(token, func(in *core.Port) {
+			wsReply(c, in)
+		})
+	}
+}
+
+// wsReply sends the replies of the handler to the connection until their stream ends. Replies are consumed even if
+// the connection has been closed, so that the following messages get their own replies.
+func wsReply(c *wsConnection, in *core.Port) {
+	if !in.PullBOS() {
+		return
+	}
+	for {
+		i, ok := in.Stream().PullOK()
+		if !ok || in.OwnEOS(i) {
+			return
+		}
+		r := i.(map[string]interface{})
+		text, _ := r["text"].(bool)
+		data, _ := r["data"].(core.Binary)
+		c.write(text, data)
+	}
+}
+
+// closeAll closes all open connections, they are not affected by shutting down the HTTP server
+func (h *wsHandler) closeAll() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, c := range h.connections {
+		c.conn.Close()
+	}
+}
+
+// broadcast sends the frame to all open connections and returns the number of connections reached
+func (h *wsHandler) broadcast(text bool, data []byte) int {
+	h.mutex.Lock()
+	connections := make([]*wsConnection, 0, len(h.connections))
+	for _, c := range h.connections {
+		connections = append(connections, c)
+	}
+	h.mutex.Unlock()
+
+	reached := 0
+	for _, c := range connections {
+		if err := c.write(text, data); err == nil {
+			reached++
+		}
+	}
+	return reached
+}
+
+var netWebSocketServerId = "5c4b4d6b-0b5e-4f4b-8a7f-3f6e0e4c9d21"
+var netWebSocketServerCfg = &builtinConfig{
+	opDef: core.OperatorDef{
+		Id: netWebSocketServerId,
+		Meta: core.OperatorMetaDef{
+			Name:             "WebSocket server",
+			ShortDescription: "starts a WebSocket server, uses a handler delegate to reply to each message of all connections",
+			Icon:             "server",
+			Tags:             []string{"network", "websocket"},
+			DocURL:           "https://bitspark.de/slang/docs/operator/websocket-server",
+		},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {
+				In: core.TypeDef{
+					Type: "number",
+				},
+				Out: core.TypeDef{
+					Type: "string",
+				},
+			},
+			"broadcast": {
+				In: WEBSOCKET_MESSAGE_DEF.Copy(),
+				Out: core.TypeDef{
+					Type: "number",
+				},
+			},
+		},
+		DelegateDefs: map[string]*core.DelegateDef{
+			"handler": {
+				In: core.TypeDef{
+					Type:   "stream",
+					Stream: wsMessageDef(),
+				},
+				Out: core.TypeDef{
+					Type: "map",
+					Map: map[string]*core.TypeDef{
+						"connection": {
+							Type: "string",
+						},
+						"message": wsMessageDef(),
+					},
+				},
+			},
+		},
+		PropertyDefs: map[string]*core.TypeDef{
+			"certFile": {
+				Type: "string",
+			},
+			"keyFile": {
+				Type: "string",
+			},
+			"origins": {
+				Type: "stream",
+				Stream: &core.TypeDef{
+					Type: "string",
+				},
+			},
+		},
+		PropertyDefaults: core.Properties{
+			"certFile": "",
+			"keyFile":  "",
+			"origins":  []interface{}{},
+		},
+	},
+	opCapsFunc: requiresNetwork(),
+	opFunc: func(op *core.Operator) {
+		in := op.Main().In()
+		out := op.Main().Out()
+		broadcastIn := op.Service("broadcast").In()
+		broadcastOut := op.Service("broadcast").Out()
+		handler := newWSHandler(op)
+
+		go handler.sync.Worker()
+		go func() {
+			<-op.Done()
+			handler.closeAll()
+		}()
+		go func() {
+			for {
+				i, ok := broadcastIn.PullOK()
+				if !ok {
+					return
+				}
+				if core.IsMarker(i) {
+					broadcastOut.Push(i)
+					continue
+				}
+				msg := i.(map[string]interface{})
+				text, _ := msg["text"].(bool)
+				data, _ := msg["data"].(core.Binary)
+				broadcastOut.Push(float64(handler.broadcast(text, data)))
+			}
+		}()
+
+		for !op.CheckStop() {
+			port, marker := in.PullInt()
+			if op.CheckStop() {
+				return
+			}
+			if marker != nil {
+				out.Push(marker)
+				continue
+			}
+
+			s := &http.Server{
+				Addr:           ":" + strconv.Itoa(port),
+				Handler:        handler,
+				ReadTimeout:    10 * time.Second,
+				MaxHeaderBytes: 1 << 20,
+			}
+
+			err := serveHTTP(op, s)
+			out.Push(err.Error())
+		}
+	},
+}
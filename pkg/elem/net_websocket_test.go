@@ -0,0 +1,257 @@
+package elem
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func dialWebSocket(url string) (*websocket.Conn, error) {
+	var err error
+	for i := 0; i < 10; i++ {
+		var conn *websocket.Conn
+		conn, _, err = websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			return conn, nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return nil, err
+}
+
+func Test_WebSocket__IsRegistered(t *testing.T) {
+	a := assertions.New(t)
+
+	a.NotNil(getBuiltinCfg(netWebSocketServerId))
+	a.NotNil(getBuiltinCfg(netWebSocketClientId))
+}
+
+func Test_WebSocket__ServerPorts(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator: netWebSocketServerId,
+		},
+	)
+	require.NoError(t, err)
+
+	a.Equal(core.TYPE_NUMBER, o.Main().In().Type())
+	a.Equal(core.TYPE_STRING, o.Main().Out().Type())
+
+	a.Equal(core.TYPE_BOOLEAN, o.Service("broadcast").In().Map("text").Type())
+	a.Equal(core.TYPE_BINARY, o.Service("broadcast").In().Map("data").Type())
+	a.Equal(core.TYPE_NUMBER, o.Service("broadcast").Out().Type())
+
+	dlg := o.Delegate("handler")
+	a.NotNil(dlg)
+	a.Equal(core.TYPE_STRING, dlg.Out().Map("connection").Type())
+	a.Equal(core.TYPE_BOOLEAN, dlg.Out().Map("message").Map("text").Type())
+	a.Equal(core.TYPE_BINARY, dlg.Out().Map("message").Map("data").Type())
+	a.Equal(core.TYPE_STREAM, dlg.In().Type())
+	a.Equal(core.TYPE_BINARY, dlg.In().Stream().Map("data").Type())
+}
+
+func Test_WebSocket__ServerReply(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator: netWebSocketServerId,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	handler := o.Delegate("handler")
+	handler.Out().Bufferize()
+
+	o.Start(context.Background())
+	o.Main().In().Push(9445)
+
+	conn, err := dialWebSocket("ws://127.0.0.1:9445/")
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	// Each message is answered by a stream of replies
+	var connection interface{}
+	for _, msg := range []string{"ping", "pong"} {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(msg)))
+		id := handler.Out().Map("connection").Pull()
+		a.NotEmpty(id)
+		if connection != nil {
+			a.Equal(connection, id)
+		}
+		connection = id
+		a.Equal(true, handler.Out().Map("message").Map("text").Pull())
+		a.Equal(core.Binary(msg), handler.Out().Map("message").Map("data").Pull())
+
+		handler.In().Push([]interface{}{
+			map[string]interface{}{"text": false, "data": core.Binary(msg)},
+			map[string]interface{}{"text": true, "data": core.Binary(msg + "!")},
+		})
+		messageType, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		a.Equal(websocket.BinaryMessage, messageType)
+		a.Equal([]byte(msg), data)
+		messageType, data, err = conn.ReadMessage()
+		require.NoError(t, err)
+		a.Equal(websocket.TextMessage, messageType)
+		a.Equal([]byte(msg+"!"), data)
+	}
+}
+
+func Test_WebSocket__ServerConcurrentConnections(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator: netWebSocketServerId,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	handler := o.Delegate("handler")
+	handler.Out().Bufferize()
+
+	o.Start(context.Background())
+	o.Main().In().Push(9450)
+
+	conn1, err := dialWebSocket("ws://127.0.0.1:9450/")
+	require.NoError(t, err)
+	defer conn1.Close()
+	conn2, err := dialWebSocket("ws://127.0.0.1:9450/")
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	// The second connection is answered while the first one stays open
+	for _, conn := range []*websocket.Conn{conn1, conn2} {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+		id := handler.Out().Map("connection").Pull()
+		handler.Out().Map("message").Pull()
+		handler.In().Push([]interface{}{
+			map[string]interface{}{"text": true, "data": core.Binary(id.(string))},
+		})
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		a.Equal([]byte(id.(string)), data)
+	}
+}
+
+func Test_WebSocket__ServerBroadcast(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator: netWebSocketServerId,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Service("broadcast").Out().Bufferize()
+	o.Delegate("handler").Out().Bufferize()
+
+	o.Start(context.Background())
+	o.Main().In().Push(9446)
+
+	conn1, err := dialWebSocket("ws://127.0.0.1:9446/")
+	require.NoError(t, err)
+	defer conn1.Close()
+	conn2, err := dialWebSocket("ws://127.0.0.1:9446/")
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	// Give the server time to register both connections
+	time.Sleep(50 * time.Millisecond)
+
+	o.Service("broadcast").In().Push(map[string]interface{}{"text": true, "data": core.Binary("news")})
+	a.Equal(float64(2), o.Service("broadcast").Out().Pull())
+
+	for _, conn := range []*websocket.Conn{conn1, conn2} {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		a.Equal([]byte("news"), data)
+	}
+}
+
+func Test_WebSocket__Client(t *testing.T) {
+	a := assertions.New(t)
+
+	srv, err := buildOperator(
+		core.InstanceDef{
+			Operator: netWebSocketServerId,
+		},
+	)
+	require.NoError(t, err)
+
+	srv.Main().Out().Bufferize()
+	handler := srv.Delegate("handler")
+	handler.Out().Bufferize()
+
+	srv.Start(context.Background())
+	srv.Main().In().Push(9447)
+
+	// Make sure the server is listening before the client connects
+	for i := 0; i < 10; i++ {
+		if conn, err := net.Dial("tcp", "127.0.0.1:9447"); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	o, err := buildOperator(core.InstanceDef{Operator: netWebSocketClientId})
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Service("send").Out().Bufferize()
+	o.Start(context.Background())
+
+	o.Main().In().Push("ws://127.0.0.1:9447/")
+	messages := o.Main().Out().Map("messages")
+	a.True(messages.PullBOS())
+
+	// The stream of messages begins before the client has connected
+	var sendErr interface{}
+	for i := 0; i < 10; i++ {
+		o.Service("send").In().Push(map[string]interface{}{"text": true, "data": core.Binary("hello server")})
+		if sendErr = o.Service("send").Out().Pull(); sendErr == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	a.Nil(sendErr)
+	a.NotEmpty(handler.Out().Map("connection").Pull())
+	a.Equal(true, handler.Out().Map("message").Map("text").Pull())
+	a.Equal(core.Binary("hello server"), handler.Out().Map("message").Map("data").Pull())
+
+	handler.In().Push([]interface{}{
+		map[string]interface{}{"text": true, "data": core.Binary("hello client")},
+	})
+	a.Equal(true, messages.Stream().Map("text").Pull())
+	a.Equal(core.Binary("hello client"), messages.Stream().Map("data").Pull())
+}
+
+func Test_WebSocket__ClientDialError(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(core.InstanceDef{Operator: netWebSocketClientId})
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Service("send").Out().Bufferize()
+	o.Start(context.Background())
+
+	o.Main().In().Push("ws://127.0.0.1:1/")
+	a.Equal([]interface{}{}, o.Main().Out().Map("messages").Pull())
+	a.NotNil(o.Main().Out().Map("error").Pull())
+}
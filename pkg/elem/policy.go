@@ -0,0 +1,263 @@
+package elem
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"gopkg.in/yaml.v2"
+)
+
+// Capabilities builtin operators can require
+const (
+	CAP_FILESYSTEM = "filesystem"
+	CAP_NETWORK    = "network"
+	CAP_PROCESS    = "process"
+)
+
+// Capability is a permission an operator instance requires. Value holds the path or host in case it is known when
+// building the operator, otherwise it is checked when the operator accesses it.
+type Capability struct {
+	Type  string
+	Value string
+}
+
+type capsFunc func(props core.Properties) []Capability
+
+// Policy is an allowlist of capabilities. Filesystem holds path prefixes, Network holds hosts which may contain a
+// port or start with a wildcard such as *.example.com. A single * grants access to all paths or hosts. Commands holds
+// the executables or directories containing them which processes with a known command, such as external operators,
+// may be started from, in addition to Process being granted.
+type Policy struct {
+	Filesystem []string `yaml:"filesystem" json:"filesystem"`
+	Network    []string `yaml:"network" json:"network"`
+	Process    bool     `yaml:"process" json:"process"`
+	Commands   []string `yaml:"commands" json:"commands"`
+}
+
+var policy *Policy
+
+// SetPolicy restricts the capabilities of all operators built from now on. A nil policy lifts all restrictions.
+func SetPolicy(p *Policy) {
+	policy = p
+}
+
+func GetPolicy() *Policy {
+	return policy
+}
+
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	if err := yaml.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %s", path, err)
+	}
+	return p, nil
+}
+
+// Allows returns an error in case the capability is not granted
+func (p *Policy) Allows(c Capability) error {
+	switch c.Type {
+	case CAP_PROCESS:
+		if !p.Process {
+			return errors.New("process execution not permitted")
+		}
+		if c.Value != "" && !p.allowsCommand(c.Value) {
+			return fmt.Errorf("execution of %s not permitted", c.Value)
+		}
+	case CAP_FILESYSTEM:
+		if len(p.Filesystem) == 0 {
+			return errors.New("filesystem access not permitted")
+		}
+		if c.Value != "" && !p.allowsPath(c.Value) {
+			return fmt.Errorf("access to path %s not permitted", c.Value)
+		}
+	case CAP_NETWORK:
+		if len(p.Network) == 0 {
+			return errors.New("network access not permitted")
+		}
+		if c.Value != "" && !p.allowsHost(c.Value) {
+			return fmt.Errorf("access to host %s not permitted", c.Value)
+		}
+	default:
+		return fmt.Errorf("unknown capability %s", c.Type)
+	}
+	return nil
+}
+
+// allowsPath compares paths with their symbolic links resolved, so that links cannot lead outside the allowed paths
+func (p *Policy) allowsPath(path string) bool {
+	path = resolveLinks(absPath(path))
+	for _, prefix := range p.Filesystem {
+		if prefix == "*" {
+			return true
+		}
+		prefix = resolveLinks(absPath(prefix))
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) allowsCommand(command string) bool {
+	command = absPath(command)
+	for _, allowed := range p.Commands {
+		if allowed == "*" {
+			return true
+		}
+		allowed = absPath(allowed)
+		if command == allowed || strings.HasPrefix(command, strings.TrimSuffix(allowed, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) allowsHost(address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, ""
+	}
+	for _, allowed := range p.Network {
+		if allowed == "*" {
+			return true
+		}
+		allowedHost, allowedPort, err := net.SplitHostPort(allowed)
+		if err != nil {
+			allowedHost, allowedPort = allowed, ""
+		}
+		if allowedPort != "" && allowedPort != port {
+			continue
+		}
+		if strings.HasPrefix(allowedHost, "*.") && strings.HasSuffix(host, allowedHost[1:]) {
+			return true
+		}
+		if allowedHost == host {
+			return true
+		}
+	}
+	return false
+}
+
+// absPath resolves the home directory and makes the path absolute, so that it can be compared to path prefixes
+func absPath(path string) string {
+	if strings.HasPrefix(path, "~") {
+		if usr, err := user.Current(); err == nil {
+			path = filepath.Join(usr.HomeDir, path[1:])
+		}
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// resolveLinks evaluates the symbolic links of the longest existing part of the path, as paths may not exist yet
+// when they are checked, e.g. before writing a file
+func resolveLinks(path string) string {
+	rest := ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest)
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// hostOf extracts the host from URLs, MySQL DSNs such as user:pass@tcp(host:3306)/db and plain addresses
+func hostOf(address string) string {
+	if strings.Contains(address, "://") {
+		if u, err := url.Parse(address); err == nil {
+			return u.Host
+		}
+	}
+	if i := strings.Index(address, "("); i != -1 {
+		if j := strings.Index(address[i:], ")"); j != -1 {
+			return address[i+1 : i+j]
+		}
+	}
+	return address
+}
+
+// checkCapability checks the capability against the current policy
+func checkCapability(c Capability) error {
+	if policy == nil {
+		return nil
+	}
+	return policy.Allows(c)
+}
+
+// checkPath must be called before an operator accesses a path which is not known when building it
+func checkPath(path string) error {
+	return checkCapability(Capability{CAP_FILESYSTEM, path})
+}
+
+// checkURL must be called before an operator connects to a URL which is not known when building it
+func checkURL(address string) error {
+	return checkCapability(Capability{CAP_NETWORK, hostOf(address)})
+}
+
+// CheckPath is checkPath for operators registered by plugins
+func CheckPath(path string) error {
+	return checkPath(path)
+}
+
+// CheckURL is checkURL for operators registered by plugins
+func CheckURL(address string) error {
+	return checkURL(address)
+}
+
+func requiresProcess(props core.Properties) []Capability {
+	return []Capability{{CAP_PROCESS, ""}}
+}
+
+func requiresFilesystem(props core.Properties) []Capability {
+	return []Capability{{CAP_FILESYSTEM, ""}}
+}
+
+// requiresNetwork declares network access to the hosts given by the properties
+func requiresNetwork(hostProps ...string) capsFunc {
+	return func(props core.Properties) []Capability {
+		caps := []Capability{{CAP_NETWORK, ""}}
+		for _, name := range hostProps {
+			values := []interface{}{props[name]}
+			if vs, ok := props[name].([]interface{}); ok {
+				values = vs
+			}
+			for _, v := range values {
+				if address, ok := v.(string); ok && address != "" {
+					caps = append(caps, Capability{CAP_NETWORK, hostOf(address)})
+				}
+			}
+		}
+		return caps
+	}
+}
+
+// checkPolicy returns an error in case the instance requires capabilities which have not been granted
+func checkPolicy(cfg *builtinConfig, def core.InstanceDef) error {
+	if policy == nil || cfg.opCapsFunc == nil {
+		return nil
+	}
+	for _, c := range cfg.opCapsFunc(def.Properties) {
+		if err := policy.Allows(c); err != nil {
+			return fmt.Errorf("%s: %s", cfg.opDef.Meta.Name, err)
+		}
+	}
+	return nil
+}
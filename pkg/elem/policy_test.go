@@ -0,0 +1,167 @@
+package elem
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Policy__Allows(t *testing.T) {
+	a := assertions.New(t)
+
+	p := &Policy{
+		Filesystem: []string{"/data/slang"},
+		Network:    []string{"api.example.com", "*.internal", "db:3306"},
+	}
+
+	a.NoError(p.Allows(Capability{CAP_FILESYSTEM, ""}))
+	a.NoError(p.Allows(Capability{CAP_FILESYSTEM, "/data/slang"}))
+	a.NoError(p.Allows(Capability{CAP_FILESYSTEM, "/data/slang/in/file.csv"}))
+	a.Error(p.Allows(Capability{CAP_FILESYSTEM, "/data/slangx/file.csv"}))
+	a.Error(p.Allows(Capability{CAP_FILESYSTEM, "/data/slang/../secret"}))
+
+	a.NoError(p.Allows(Capability{CAP_NETWORK, "api.example.com"}))
+	a.NoError(p.Allows(Capability{CAP_NETWORK, "api.example.com:443"}))
+	a.NoError(p.Allows(Capability{CAP_NETWORK, "queue.internal:5672"}))
+	a.NoError(p.Allows(Capability{CAP_NETWORK, "db:3306"}))
+	a.Error(p.Allows(Capability{CAP_NETWORK, "db:5432"}))
+	a.Error(p.Allows(Capability{CAP_NETWORK, "example.com"}))
+
+	a.Error(p.Allows(Capability{CAP_PROCESS, ""}))
+
+	p = &Policy{Process: true, Commands: []string{"/usr/bin/python3", "/opt/slang/bin"}}
+	a.NoError(p.Allows(Capability{CAP_PROCESS, ""}))
+	a.NoError(p.Allows(Capability{CAP_PROCESS, "/usr/bin/python3"}))
+	a.NoError(p.Allows(Capability{CAP_PROCESS, "/opt/slang/bin/sidecar"}))
+	a.Error(p.Allows(Capability{CAP_PROCESS, "/usr/bin/python"}))
+	a.Error(p.Allows(Capability{CAP_PROCESS, "/opt/slang/bin/../../../bin/sh"}))
+	a.Error((&Policy{Process: true}).Allows(Capability{CAP_PROCESS, "/bin/sh"}))
+}
+
+func Test_Policy__HostOf(t *testing.T) {
+	a := assertions.New(t)
+
+	a.Equal("example.com:8080", hostOf("https://example.com:8080/path?q=1"))
+	a.Equal("db:3306", hostOf("user:pass@tcp(db:3306)/slang"))
+	a.Equal("broker:1883", hostOf("broker:1883"))
+}
+
+func Test_Policy__RefuseBuild(t *testing.T) {
+	a := assertions.New(t)
+
+	SetPolicy(&Policy{Network: []string{"allowed.org"}})
+	defer SetPolicy(nil)
+
+	_, err := buildOperator(
+		core.InstanceDef{
+			Operator:   shellExecuteCfg.opDef.Id,
+			Properties: core.Properties{"bufferSize": 1024},
+		},
+	)
+	a.Error(err)
+
+	mqttProps := func(broker string) core.Properties {
+		return core.Properties{"broker": broker, "username": "", "password": "", "topic": "t"}
+	}
+	_, err = buildOperator(core.InstanceDef{Operator: "MQTT publish", Properties: mqttProps("tcp://forbidden.org:1883")})
+	a.Error(err)
+	_, err = buildOperator(core.InstanceDef{Operator: "MQTT publish", Properties: mqttProps("tcp://allowed.org:1883")})
+	a.NoError(err)
+
+	_, err = buildOperator(core.InstanceDef{Operator: "read file"})
+	a.Error(err)
+}
+
+func Test_Policy__RefuseAccess(t *testing.T) {
+	a := assertions.New(t)
+
+	SetPolicy(&Policy{Filesystem: []string{"/nonexistent/allowed"}})
+	defer SetPolicy(nil)
+
+	o, err := buildOperator(core.InstanceDef{Operator: "read file"})
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Start(context.Background())
+
+	o.Main().In().Push("/etc/hostname")
+	a.Equal(map[string]interface{}{"content": nil, "error": "access to path /etc/hostname not permitted"}, o.Main().Out().Pull())
+}
+
+func Test_Policy__Symlinks(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-policy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	allowed := filepath.Join(dir, "allowed")
+	secret := filepath.Join(dir, "secret")
+	require.NoError(t, os.Mkdir(allowed, 0755))
+	require.NoError(t, os.Mkdir(secret, 0755))
+	require.NoError(t, os.Symlink(secret, filepath.Join(allowed, "link")))
+	require.NoError(t, os.Symlink(allowed, filepath.Join(dir, "alias")))
+
+	p := &Policy{Filesystem: []string{allowed}}
+	a.NoError(p.Allows(Capability{CAP_FILESYSTEM, filepath.Join(allowed, "new", "file.txt")}))
+	a.NoError(p.Allows(Capability{CAP_FILESYSTEM, filepath.Join(dir, "alias", "file.txt")}))
+	a.Error(p.Allows(Capability{CAP_FILESYSTEM, filepath.Join(allowed, "link")}))
+	a.Error(p.Allows(Capability{CAP_FILESYSTEM, filepath.Join(allowed, "link", "file.txt")}))
+}
+
+func Test_Policy__RefuseServers(t *testing.T) {
+	a := assertions.New(t)
+
+	SetPolicy(&Policy{Filesystem: []string{"/nonexistent/allowed"}})
+	defer SetPolicy(nil)
+
+	_, err := buildOperator(core.InstanceDef{Operator: "HTTP server"})
+	a.Error(err)
+	_, err = buildOperator(core.InstanceDef{Operator: "WebSocket server"})
+	a.Error(err)
+
+	SetPolicy(&Policy{Network: []string{"localhost"}})
+	_, err = buildOperator(core.InstanceDef{Operator: "HTTP server", Properties: core.Properties{"host": "localhost"}})
+	a.NoError(err)
+	_, err = buildOperator(core.InstanceDef{Operator: "HTTP server", Properties: core.Properties{"host": "0.0.0.0"}})
+	a.Error(err)
+}
+
+func Test_Policy__ReportDenials(t *testing.T) {
+	a := assertions.New(t)
+
+	SetPolicy(&Policy{Filesystem: []string{"/nonexistent/allowed"}, Network: []string{"allowed.org"}})
+	defer SetPolicy(nil)
+
+	o, err := buildOperator(core.InstanceDef{Operator: "lines from file"})
+	require.NoError(t, err)
+	o.Main().Out().Bufferize()
+	o.Start(context.Background())
+
+	o.Main().In().Push(map[string]interface{}{"filename": "/etc/hostname"})
+	a.Equal(map[string]interface{}{"lines": nil, "error": "access to path /etc/hostname not permitted"}, o.Main().Out().Pull())
+
+	o, err = buildOperator(core.InstanceDef{Operator: "HTTP client"})
+	require.NoError(t, err)
+	o.Main().Out().Bufferize()
+	o.Start(context.Background())
+
+	o.Main().In().Push(map[string]interface{}{
+		"method":  "GET",
+		"url":     "http://forbidden.org/",
+		"body":    core.Binary{},
+		"headers": []interface{}{},
+	})
+	a.Equal(map[string]interface{}{
+		"status":  nil,
+		"headers": []interface{}{},
+		"body":    nil,
+		"error":   "access to host forbidden.org not permitted",
+	}, o.Main().Out().Pull())
+}
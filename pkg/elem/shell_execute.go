@@ -1,10 +1,45 @@
 package elem
 
 import (
-	"github.com/Bitspark/slang/pkg/core"
+	"errors"
+	"os"
 	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
 )
 
+// exitStatus returns the exit code of the finished process and the name of the signal which terminated it, if any
+func exitStatus(c *exec.Cmd) (float64, interface{}) {
+	if c.ProcessState == nil {
+		return -1, nil
+	}
+	ws, ok := c.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok {
+		if c.ProcessState.Success() {
+			return 0, nil
+		}
+		return -1, nil
+	}
+	if ws.Signaled() {
+		return float64(ws.ExitStatus()), ws.Signal().String()
+	}
+	return float64(ws.ExitStatus()), nil
+}
+
+func environmentProperty(v interface{}) []string {
+	env := []string{}
+	if entries, ok := v.([]interface{}); ok {
+		for _, entry := range entries {
+			e := entry.(map[string]interface{})
+			env = append(env, e["key"].(string)+"="+e["value"].(string))
+		}
+	}
+	return env
+}
+
 var shellExecuteCfg = &builtinConfig{
 	opDef: core.OperatorDef{
 		Id: "13cbad40-da00-40d7-bdcd-981b14ec346b",
@@ -55,6 +90,12 @@ var shellExecuteCfg = &builtinConfig{
 						"code": {
 							Type: "string",
 						},
+						"exitCode": {
+							Type: "number",
+						},
+						"signal": {
+							Type: "string",
+						},
 					},
 				},
 			},
@@ -90,36 +131,86 @@ var shellExecuteCfg = &builtinConfig{
 			"bufferSize": {
 				Type: "number",
 			},
+			"environment": {
+				Type: "stream",
+				Stream: &core.TypeDef{
+					Type: "map",
+					Map: map[string]*core.TypeDef{
+						"key": {
+							Type: "string",
+						},
+						"value": {
+							Type: "string",
+						},
+					},
+				},
+			},
+			"workingDirectory": {
+				Type: "string",
+			},
+			"timeout": {
+				Type: "number",
+			},
+		},
+		PropertyDefaults: core.Properties{
+			"bufferSize":       1024,
+			"environment":      []interface{}{},
+			"workingDirectory": "",
+			"timeout":          0,
 		},
 	},
+	opCapsFunc: requiresProcess,
 	opFunc: func(op *core.Operator) {
 		in := op.Main().In()
 		out := op.Main().Out()
 		user := op.Delegate("user")
 		buffersize := int(op.Property("bufferSize").(float64))
+		env := environmentProperty(op.Property("environment"))
+		workingDir, _ := op.Property("workingDirectory").(string)
+		timeout := time.Duration(0)
+		if t, ok := op.Property("timeout").(float64); ok {
+			timeout = time.Duration(t) * time.Millisecond
+		}
 		for !op.CheckStop() {
-			i := in.Map("command").Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) || i == nil {
-				in.Map("stdin").Pull()
 				out.Push(i)
 				continue
 			}
 
-			cmd := i.(string)
+			im := i.(map[string]interface{})
+			cmd, ok := im["command"].(string)
+			if !ok {
+				out.Push(nil)
+				continue
+			}
 			args := []string{}
-			for _, arg := range in.Map("arguments").Pull().([]interface{}) {
+			argsIn, _ := im["arguments"].([]interface{})
+			for _, arg := range argsIn {
 				args = append(args, arg.(string))
 			}
 
 			c := exec.Command(cmd, args...)
+			c.Dir = workingDir
+			if len(env) != 0 {
+				c.Env = append(os.Environ(), env...)
+			}
+			setProcessGroup(c)
 
 			stdout, _ := c.StdoutPipe()
 			stderr, _ := c.StderrPipe()
 			stdin, _ := c.StdinPipe()
 
-			err := c.Start()
+			startErr := c.Start()
+
+			readers := &sync.WaitGroup{}
+			readers.Add(2)
 			// Redirect stdout to out port
 			go func() {
+				defer readers.Done()
 				user.Out().Map("stdout").PushBOS()
 				out.Map("stdout").PushBOS()
 				bytes := make([]byte, buffersize)
@@ -128,7 +219,8 @@ var shellExecuteCfg = &builtinConfig{
 					if err != nil {
 						break
 					}
-					chunk := core.Binary(bytes[0:read])
+					chunk := make(core.Binary, read)
+					copy(chunk, bytes[0:read])
 					user.Out().Map("stdout").Stream().Push(chunk)
 					out.Map("stdout").Stream().Push(chunk)
 				}
@@ -137,6 +229,7 @@ var shellExecuteCfg = &builtinConfig{
 			}()
 			// Redirect stderr to out port
 			go func() {
+				defer readers.Done()
 				user.Out().Map("stderr").PushBOS()
 				out.Map("stderr").PushBOS()
 				bytes := make([]byte, buffersize)
@@ -145,35 +238,83 @@ var shellExecuteCfg = &builtinConfig{
 					if err != nil {
 						break
 					}
-					chunk := core.Binary(bytes[0:read])
+					chunk := make(core.Binary, read)
+					copy(chunk, bytes[0:read])
 					user.Out().Map("stderr").Stream().Push(chunk)
 					out.Map("stderr").Stream().Push(chunk)
 				}
 				user.Out().Map("stderr").PushEOS()
 				out.Map("stderr").PushEOS()
 			}()
-			// Redirect stdin to program and out port
+			// Redirect stdin to program and out port, the stream on the out port is complete even if the operator
+			// has been stopped
+			forwarded := make(chan bool)
 			go func() {
-				user.In().PullBOS()
+				defer close(forwarded)
+				defer stdin.Close()
 				out.Map("stdin").PushBOS()
+				defer out.Map("stdin").PushEOS()
+				if !user.In().PullBOS() {
+					return
+				}
 				for {
-					i := user.In().Stream().Pull()
-					if user.In().OwnEOS(i) {
-						out.Map("stdin").PushEOS()
-						stdin.Close()
-						break
+					i, ok := user.In().Stream().PullOK()
+					if !ok || user.In().OwnEOS(i) {
+						return
 					}
 					input := i.(core.Binary)
 					stdin.Write(input)
 					out.Map("stdin").Stream().Push(input)
 				}
 			}()
-			err = c.Wait()
+			if startErr != nil {
+				readers.Wait()
+				<-forwarded
+				out.Map("code").Push(startErr.Error())
+				out.Map("exitCode").Push(float64(-1))
+				out.Map("signal").Push(nil)
+				continue
+			}
+
+			// The process is only killed as long as it has not exited, so that a timeout is not reported for
+			// processes finishing just in time
+			timeoutMutex := &sync.Mutex{}
+			exited, timedOut := false, false
+			var timer *time.Timer
+			if timeout > 0 {
+				timer = time.AfterFunc(timeout, func() {
+					timeoutMutex.Lock()
+					defer timeoutMutex.Unlock()
+					if !exited {
+						timedOut = true
+						killProcessGroup(c)
+					}
+				})
+			}
+
+			// All output has to be read before waiting for the process
+			readers.Wait()
+			err := c.Wait()
+			<-forwarded
+
+			timeoutMutex.Lock()
+			exited = true
+			if timedOut {
+				err = errors.New("timeout exceeded")
+			}
+			timeoutMutex.Unlock()
+			if timer != nil {
+				timer.Stop()
+			}
+
+			exitCode, signal := exitStatus(c)
 			if err != nil {
 				out.Map("code").Push(err.Error())
 			} else {
 				out.Map("code").Push(nil)
 			}
+			out.Map("exitCode").Push(exitCode)
+			out.Map("signal").Push(signal)
 		}
 	},
 }
@@ -0,0 +1,124 @@
+//go:build !windows
+// +build !windows
+
+package elem
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/stretchr/testify/require"
+)
+
+func runShellExecute(t *testing.T, props core.Properties, script string) map[string]interface{} {
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator:   shellExecuteCfg.opDef.Id,
+			Properties: props,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Delegate("user").Out().Bufferize()
+	o.Start(context.Background())
+
+	o.Main().In().Push(map[string]interface{}{"command": "sh", "arguments": []interface{}{"-c", script}})
+	o.Delegate("user").In().Push([]interface{}{})
+
+	return o.Main().Out().Pull().(map[string]interface{})
+}
+
+func Test_ShellExecute__ExitCode(t *testing.T) {
+	a := assertions.New(t)
+
+	res := runShellExecute(t, core.Properties{"bufferSize": 1024}, "exit 3")
+	a.Equal("exit status 3", res["code"])
+	a.Equal(float64(3), res["exitCode"])
+	a.Nil(res["signal"])
+
+	res = runShellExecute(t, core.Properties{"bufferSize": 1024}, "true")
+	a.Nil(res["code"])
+	a.Equal(float64(0), res["exitCode"])
+}
+
+func Test_ShellExecute__EnvironmentAndWorkingDirectory(t *testing.T) {
+	a := assertions.New(t)
+
+	env := []interface{}{map[string]interface{}{"key": "SLANG_TEST", "value": "hello"}}
+	props := core.Properties{"bufferSize": 1024, "environment": env, "workingDirectory": os.TempDir()}
+	res := runShellExecute(t, props, "printf \"$SLANG_TEST \"; pwd")
+	a.Nil(res["code"])
+
+	stdout := ""
+	for _, chunk := range res["stdout"].([]interface{}) {
+		stdout += string(chunk.(core.Binary))
+	}
+	a.Contains(stdout, "hello ")
+	a.Contains(stdout, os.TempDir())
+}
+
+func Test_ShellExecute__Stdin(t *testing.T) {
+	a := assertions.New(t)
+
+	o, err := buildOperator(
+		core.InstanceDef{
+			Operator: shellExecuteCfg.opDef.Id,
+		},
+	)
+	require.NoError(t, err)
+
+	o.Main().Out().Bufferize()
+	o.Delegate("user").Out().Bufferize()
+	o.Start(context.Background())
+
+	o.Main().In().Push(map[string]interface{}{"command": "cat", "arguments": []interface{}{}})
+	o.Delegate("user").In().Push([]interface{}{core.Binary("hello")})
+
+	res := o.Main().Out().Pull().(map[string]interface{})
+	a.Nil(res["code"])
+	a.Equal([]interface{}{core.Binary("hello")}, res["stdin"])
+	a.Equal([]interface{}{core.Binary("hello")}, res["stdout"])
+}
+
+func Test_ShellExecute__Timeout(t *testing.T) {
+	a := assertions.New(t)
+
+	start := time.Now()
+	res := runShellExecute(t, core.Properties{"bufferSize": 1024, "timeout": 100}, "sleep 10 & wait")
+	a.True(time.Since(start) < 5*time.Second)
+	a.Equal("killed", res["signal"])
+	a.Equal("timeout exceeded", res["code"])
+
+	res = runShellExecute(t, core.Properties{"bufferSize": 1024, "timeout": 5000}, "exit 0")
+	a.Nil(res["code"])
+	a.Nil(res["signal"])
+}
+
+func Test_ShellExecute__StartError(t *testing.T) {
+	a := assertions.New(t)
+
+	res := runShellExecute(t, core.Properties{"bufferSize": 1024, "workingDirectory": "/does/not/exist"}, "true")
+	a.Equal(float64(-1), res["exitCode"])
+	a.NotNil(res["code"])
+}
+
+func Test_ShellExecute__Disabled(t *testing.T) {
+	a := assertions.New(t)
+
+	require.NoError(t, Disable("shell execute"))
+	defer delete(disabled, GetId("shell execute"))
+
+	a.True(IsDisabled(shellExecuteCfg.opDef.Id))
+	_, err := buildOperator(
+		core.InstanceDef{
+			Operator:   shellExecuteCfg.opDef.Id,
+			Properties: core.Properties{"bufferSize": 1024},
+		},
+	)
+	a.Error(err)
+}
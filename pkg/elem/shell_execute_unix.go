@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package elem
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in its own process group so that it can be killed including its children
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+}
@@ -55,10 +55,15 @@ var streamConcatenateCfg = &builtinConfig{
 			streams[i] = in.Map("stream_"+idxProp.(string))
 		}
 		for !op.CheckStop() {
-			item := streams[0].Stream().Pull()
+			item, ok := streams[0].Stream().PullOK()
+			if !ok {
+				return
+			}
 			if !streams[0].OwnBOS(item) {
 				for i := 1; i < len(streams); i++ {
-					streams[i].Stream().Pull()
+					if _, ok := streams[i].Stream().PullOK(); !ok {
+						return
+					}
 				}
 				out.Push(item)
 				continue
@@ -67,10 +72,12 @@ var streamConcatenateCfg = &builtinConfig{
 			out.PushBOS()
 			for i := 0; i < len(streams); i++ {
 				for {
-					item = streams[i].Stream().Pull()
+					if item, ok = streams[i].Stream().PullOK(); !ok {
+						return
+					}
 					if streams[i].OwnEOS(item) {
-						if i+1 < len(streams) {
-							streams[i+1].PullBOS()
+						if i+1 < len(streams) && !streams[i+1].PullBOS() {
+							return
 						}
 						break
 					}
@@ -74,7 +74,10 @@ var streamDistinctCfg = &builtinConfig{
 		checker := op.Delegate("checker")
 
 		for !op.CheckStop() {
-			i := inStream.Pull()
+			i, ok := inStream.PullOK()
+			if !ok {
+				return
+			}
 			if !in.OwnBOS(i) {
 				out.Push(i)
 				continue
@@ -83,13 +86,19 @@ var streamDistinctCfg = &builtinConfig{
 			m := make(map[string]interface{})
 
 			for {
-				i = inStream.Pull()
+				if i, ok = inStream.PullOK(); !ok {
+					return
+				}
 				if in.OwnEOS(i) {
 					break
 				}
 
 				hasher.Out().Push(i)
-				h := hasher.In().Pull().(string)
+				hi, ok := hasher.In().PullOK()
+				if !ok {
+					return
+				}
+				h := hi.(string)
 				num := 0
 
 				for {
@@ -98,7 +107,11 @@ var streamDistinctCfg = &builtinConfig{
 						checker.Out().Map("a").Push(i)
 						checker.Out().Map("b").Push(mi)
 
-						if checker.In().Pull().(bool) {
+						c, ok := checker.In().PullOK()
+						if !ok {
+							return
+						}
+						if c.(bool) {
 							m[hn] = i
 							break
 						} else {
@@ -58,7 +58,10 @@ var streamMapAccessCfg = &builtinConfig{
 		out := op.Main().Out()
 		for !op.CheckStop() {
 		start:
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
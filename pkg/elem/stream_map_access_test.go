@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -34,7 +35,7 @@ func Test_StreamMapAccess__String(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push(map[string]interface{}{"key": "a", "stream": []interface{}{map[string]interface{}{"key": "a", "value": 1}}})
 	a.PortPushes(1, o.Main().Out())
@@ -62,7 +62,10 @@ var streamMapToStreamCfg = &builtinConfig{
 			entries = append(entries, entry.(string))
 		}
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -64,7 +64,10 @@ var streamParallelizeCfg = &builtinConfig{
 			}
 		}
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
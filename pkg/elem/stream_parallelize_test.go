@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -34,7 +35,7 @@ func Test_StreamParallelize__String(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push([]interface{}{"test1", "test2", "test3"})
 	a.PortPushes(map[string]interface{}{"el_0": "test1", "el_2": "test3", "el_3": nil}, o.Main().Out())
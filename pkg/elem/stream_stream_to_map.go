@@ -69,7 +69,10 @@ var streamStreamToMapCfg = &builtinConfig{
 		keyStr := op.Property("key").(string)
 		valueStr := op.Property("value").(string)
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -91,17 +91,27 @@ var streamTransformCfg = &builtinConfig{
 		iteratorIn := iterator.In()
 
 		for !op.CheckStop() {
-			state := in.Map("initial").Pull()
+			state, ok := in.Map("initial").PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(state) {
-				in.Map("items").Pull()
+				if _, ok := in.Map("items").PullOK(); !ok {
+					return
+				}
 				out.Push(state)
 			}
 
-			in.Map("items").PullBOS()
+			if !in.Map("items").PullBOS() {
+				return
+			}
 			out.Map("items").PushBOS()
 
 			for {
-				item := in.Map("items").Stream().Pull()
+				item, ok := in.Map("items").Stream().PullOK()
+				if !ok {
+					return
+				}
 				if in.Map("items").OwnEOS(item) {
 					break
 				}
@@ -109,8 +119,13 @@ var streamTransformCfg = &builtinConfig{
 				iteratorOut.Map("item").Push(item)
 				iteratorOut.Map("state").Push(state)
 
-				state = iteratorIn.Map("state").Pull()
-				outItem := iteratorIn.Map("item").Pull()
+				if state, ok = iteratorIn.Map("state").PullOK(); !ok {
+					return
+				}
+				outItem, ok := iteratorIn.Map("item").PullOK()
+				if !ok {
+					return
+				}
 
 				out.Map("items").Stream().Push(outItem)
 			}
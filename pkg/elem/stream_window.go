@@ -58,7 +58,10 @@ var streamWindowCfg = &builtinConfig{
 		fill := op.Property("fill").(bool)
 
 		for !op.CheckStop() {
-			i := in.Stream().Pull()
+			i, ok := in.Stream().PullOK()
+			if !ok {
+				return
+			}
 			if !in.OwnBOS(i) {
 				out.Push(i)
 				continue
@@ -70,7 +73,9 @@ var streamWindowCfg = &builtinConfig{
 
 			out.PushBOS()
 			for {
-				i = in.Stream().Pull()
+				if i, ok = in.Stream().PullOK(); !ok {
+					return
+				}
 				if in.OwnEOS(i) {
 					break
 				}
@@ -45,7 +45,10 @@ var streamWindowReleaseCfg = &builtinConfig{
 		ws := getWindowStore(store)
 
 		for !op.CheckStop() {
-			item := in.Pull()
+			item, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(item) {
 				out.Push(item)
 				continue
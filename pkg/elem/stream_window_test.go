@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -36,7 +37,7 @@ func Test_StreamWindow__Sliding1(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push([]interface{}{"a", "b", "c", "d", "e"})
 	a.PortPushes([]interface{}{
@@ -69,7 +70,7 @@ func Test_StreamWindow__Sliding2(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push([]interface{}{"a", "b", "c", "d", "e"})
 	a.PortPushes([]interface{}{
@@ -99,7 +100,7 @@ func Test_StreamWindow__Sliding3(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push([]interface{}{"a", "b", "c", "d", "e", "f"})
 	a.PortPushes([]interface{}{
@@ -129,7 +130,7 @@ func Test_StreamWindow__Jumping1(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 
 	o.Main().In().Push([]interface{}{"a", "b", "c", "d", "e"})
 	a.PortPushes([]interface{}{
@@ -48,7 +48,10 @@ var stringFormatCfg = &builtinConfig{
 		out := op.Main().Out()
 		vars := op.Property("variables").([]interface{})
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
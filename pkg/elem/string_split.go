@@ -40,7 +40,10 @@ var stringSplitCfg = &builtinConfig{
 		out := op.Main().Out()
 		sep := op.Property("separator").(string)
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
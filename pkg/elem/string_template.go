@@ -51,7 +51,10 @@ var stringTemplateCfg = &builtinConfig{
 		out := op.Main().Out()
 		vars := op.Property("variables").([]interface{})
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -1,6 +1,7 @@
 package elem
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -29,7 +30,7 @@ func Test_TemplateFormat__String(t *testing.T) {
 	require.NoError(t, err)
 
 	o.Main().Out().Bufferize()
-	o.Start()
+	o.Start(context.Background())
 	o.Main().In().Push(map[string]interface{}{"a": "test", "content": "__{a}__"})
 	a.PortPushes("__test__", o.Main().Out())
 }
@@ -47,7 +47,10 @@ var timeCrontabCfg = &builtinConfig{
 		c := cron.New()
 		handler := op.Delegate("handler")
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -59,12 +62,13 @@ var timeCrontabCfg = &builtinConfig{
 			c.AddFunc(crontab, func() {
 				handler.Out().Push(nil)
 
-				item := handler.In().Pull()
-				out.Stream().Push(item)
+				if item, ok := handler.In().PullOK(); ok {
+					out.Stream().Push(item)
+				}
 			})
 			c.Start()
 
-			op.WaitForStop()
+			<-op.Done()
 			c.Stop()
 			out.PushEOS()
 			break
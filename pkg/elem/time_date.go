@@ -54,7 +54,11 @@ var timeParseDateCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			if i := in.Pull(); !core.IsMarker(i) {
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
+			if !core.IsMarker(i) {
 				t, _ := parseDate(i.(string))
 				out.Map("year").Push(t.Year())
 				out.Map("month").Push(int(t.Month()))
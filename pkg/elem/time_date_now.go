@@ -39,7 +39,11 @@ var timeDateNowCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			if i := in.Pull(); !core.IsMarker(i) {
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
+			if !core.IsMarker(i) {
 				t := time.Now()
 				out.Map("year").Push(t.Year())
 				out.Map("month").Push(int(t.Month()))
@@ -42,7 +42,10 @@ var timeDelayCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			i := in.Pull()
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
 			if core.IsMarker(i) {
 				out.Push(i)
 				continue
@@ -52,8 +55,14 @@ var timeDelayCfg = &builtinConfig{
 			delay := im["delay"].(float64)
 			item := im["item"]
 
-			<-time.After(time.Millisecond * time.Duration(delay))
-			out.Push(item)
+			timer := time.NewTimer(time.Millisecond * time.Duration(delay))
+			select {
+			case <-timer.C:
+				out.Push(item)
+			case <-op.Done():
+				timer.Stop()
+				return
+			}
 		}
 	},
 	opConnFunc: func(op *core.Operator, dst, src *core.Port) error {
@@ -30,7 +30,11 @@ var timeUNIXMillisCfg = &builtinConfig{
 		in := op.Main().In()
 		out := op.Main().Out()
 		for !op.CheckStop() {
-			if i := in.Pull(); !core.IsMarker(i) {
+			i, ok := in.PullOK()
+			if !ok {
+				return
+			}
+			if !core.IsMarker(i) {
 				out.Push(float64(time.Now().UnixNano() / 1000 / 1000))
 			} else {
 				out.Push(i)
@@ -81,3 +81,16 @@ var HTTP_RESPONSE_DEF = core.TypeDef{
 		},
 	},
 }
+
+// WebSocketMessage Slang type
+var WEBSOCKET_MESSAGE_DEF = core.TypeDef{
+	Type: "map",
+	Map: map[string]*core.TypeDef{
+		"text": {
+			Type: "boolean",
+		},
+		"data": {
+			Type: "binary",
+		},
+	},
+}
@@ -0,0 +1,252 @@
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	LOCK_FILE    = "slang.lock"
+	PACKAGES_DIR = storage.PACKAGES_DIR
+	PACKAGE_FILE = ".package.yaml" // written into the directory of each installed package
+)
+
+// Lock pins the exact versions and archive checksums of the packages a project depends on
+type Lock struct {
+	Registry string          `yaml:"registry"`
+	Packages []LockedPackage `yaml:"packages"`
+}
+
+type LockedPackage struct {
+	Name       string `yaml:"name"`
+	Constraint string `yaml:"constraint,omitempty"`
+	Version    string `yaml:"version"`
+	Checksum   string `yaml:"checksum"`
+}
+
+func (l *Lock) find(name string) int {
+	for i, pkg := range l.Packages {
+		if pkg.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *Lock) set(pkg LockedPackage) {
+	if i := l.find(pkg.Name); i != -1 {
+		l.Packages[i] = pkg
+	} else {
+		l.Packages = append(l.Packages, pkg)
+	}
+	sort.Slice(l.Packages, func(i, j int) bool { return l.Packages[i].Name < l.Packages[j].Name })
+}
+
+// Project manages the packages installed into a project directory
+type Project struct {
+	Dir  string
+	Lock *Lock
+}
+
+// OpenProject reads the lock file of the project if there is one. The registry given overrides the one of the lock
+// file.
+func OpenProject(dir string, registryURL string) (*Project, error) {
+	p := &Project{dir, &Lock{}}
+
+	if b, err := ioutil.ReadFile(p.lockFile()); err == nil {
+		if err := yaml.Unmarshal(b, p.Lock); err != nil {
+			return nil, fmt.Errorf("invalid lock file %s: %s", p.lockFile(), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if registryURL != "" {
+		p.Lock.Registry = registryURL
+	}
+	return p, nil
+}
+
+func (p *Project) lockFile() string {
+	return filepath.Join(p.Dir, LOCK_FILE)
+}
+
+func (p *Project) PackagesDir() string {
+	return filepath.Join(p.Dir, PACKAGES_DIR)
+}
+
+// Loader returns a loader for the operators of all installed packages
+func (p *Project) Loader() *storage.PackageLoader {
+	return storage.NewPackageLoader(p.PackagesDir())
+}
+
+func (p *Project) Save() error {
+	b, err := yaml.Marshal(p.Lock)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p.lockFile(), b, 0644)
+}
+
+func (p *Project) client() (*Client, error) {
+	if p.Lock.Registry == "" {
+		return nil, fmt.Errorf("no registry configured")
+	}
+	return NewClient(p.Lock.Registry), nil
+}
+
+// Install installs the highest version of the package satisfying the constraint and adds it to the lock file
+func (p *Project) Install(name string, constraint string) (*LockedPackage, error) {
+	c, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+
+	v, release, err := c.Resolve(name, constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := LockedPackage{name, constraint, v, release.Checksum}
+	if err := p.install(c, pkg, release); err != nil {
+		return nil, err
+	}
+
+	p.Lock.set(pkg)
+	return &pkg, p.Save()
+}
+
+// Sync installs exactly the versions pinned by the lock file, skipping packages which are installed already
+func (p *Project) Sync() error {
+	c, err := p.client()
+	if err != nil {
+		return err
+	}
+
+	for _, pkg := range p.Lock.Packages {
+		if installed, err := p.Installed(pkg.Name); err == nil && *installed == pkg {
+			continue
+		}
+
+		index, err := c.Index(pkg.Name)
+		if err != nil {
+			return err
+		}
+		release, ok := index.Versions[pkg.Version]
+		if !ok {
+			return fmt.Errorf("version %s of package %s not found in registry", pkg.Version, pkg.Name)
+		}
+		if release.Checksum != pkg.Checksum {
+			return fmt.Errorf("checksum of package %s@%s differs from lock file", pkg.Name, pkg.Version)
+		}
+
+		if err := p.install(c, pkg, release); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update installs the highest versions satisfying the constraints of the given packages or of all packages if none
+// are given
+func (p *Project) Update(names ...string) ([]LockedPackage, error) {
+	if len(names) == 0 {
+		for _, pkg := range p.Lock.Packages {
+			names = append(names, pkg.Name)
+		}
+	}
+
+	updated := []LockedPackage{}
+	for _, name := range names {
+		i := p.Lock.find(name)
+		if i == -1 {
+			return updated, fmt.Errorf("package %s is not installed", name)
+		}
+
+		prev := p.Lock.Packages[i]
+		pkg, err := p.Install(name, prev.Constraint)
+		if err != nil {
+			return updated, err
+		}
+		if *pkg != prev {
+			updated = append(updated, *pkg)
+		}
+	}
+	return updated, nil
+}
+
+// Remove uninstalls the package and removes it from the lock file
+func (p *Project) Remove(name string) error {
+	i := p.Lock.find(name)
+	if i == -1 {
+		return fmt.Errorf("package %s is not installed", name)
+	}
+
+	if err := os.RemoveAll(filepath.Join(p.PackagesDir(), name)); err != nil {
+		return err
+	}
+
+	p.Lock.Packages = append(p.Lock.Packages[:i], p.Lock.Packages[i+1:]...)
+	return p.Save()
+}
+
+// Installed returns the package as it is installed in the packages directory
+func (p *Project) Installed(name string) (*LockedPackage, error) {
+	b, err := ioutil.ReadFile(filepath.Join(p.PackagesDir(), name, PACKAGE_FILE))
+	if err != nil {
+		return nil, err
+	}
+	var pkg LockedPackage
+	if err := yaml.Unmarshal(b, &pkg); err != nil {
+		return nil, err
+	}
+	return &pkg, nil
+}
+
+func (p *Project) install(c *Client, pkg LockedPackage, release *Release) error {
+	if err := ValidatePackageName(pkg.Name); err != nil {
+		return err
+	}
+
+	bundle, err := c.Fetch(pkg.Name, release)
+	if err != nil {
+		return err
+	}
+
+	// Install into a temporary directory first so that a failed installation leaves the previous version intact
+	pkgDir := filepath.Join(p.PackagesDir(), pkg.Name)
+	tmpDir := filepath.Join(p.PackagesDir(), "."+pkg.Name+".tmp")
+	os.RemoveAll(tmpDir)
+	if _, err := utils.EnsureDirExists(tmpDir); err != nil {
+		return err
+	}
+
+	fs := storage.NewFileSystem(tmpDir)
+	for _, opDef := range bundle.Operators {
+		if _, err := fs.Dump(opDef); err != nil {
+			os.RemoveAll(tmpDir)
+			return err
+		}
+	}
+
+	b, err := yaml.Marshal(&pkg)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, PACKAGE_FILE), b, 0644); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+
+	if err := os.RemoveAll(pkgDir); err != nil {
+		return err
+	}
+	return os.Rename(tmpDir, pkgDir)
+}
@@ -0,0 +1,205 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Bitspark/go-version"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// A registry is a tree of static files which can be served by any HTTP server:
+//
+//	<registry>/<package>/index.yaml
+//	<registry>/<package>/<package>-<version>.zip
+//
+// The index lists all released versions of a package. The archives are operator bundles as exported by the daemon.
+const INDEX_FILE = "index.yaml"
+
+var packageNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+type PackageIndex struct {
+	Name     string              `yaml:"name"`
+	Versions map[string]*Release `yaml:"versions"`
+}
+
+type Release struct {
+	File     string `yaml:"file"`
+	Checksum string `yaml:"checksum"`
+	Main     string `yaml:"main"`
+}
+
+func ValidatePackageName(name string) error {
+	if !packageNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid package name \"%s\": only lower case letters, digits, '.', '_' and '-' are allowed", name)
+	}
+	return nil
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Client fetches packages from a registry. Besides http and https URLs the registry may be a file URL.
+type Client struct {
+	URL  string
+	base string
+	http *http.Client
+}
+
+func NewClient(url string) *Client {
+	url = strings.TrimSuffix(url, "/")
+	if strings.HasPrefix(url, "file://") {
+		// Files are served from the registry directory only and remote registries cannot redirect to files
+		dir := filepath.FromSlash(strings.TrimPrefix(url, "file://"))
+		transport := &http.Transport{}
+		transport.RegisterProtocol("file", http.NewFileTransport(http.Dir(dir)))
+		return &Client{url, "file://", &http.Client{Transport: transport}}
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	return &Client{url, url, &http.Client{Transport: transport}}
+}
+
+func (c *Client) get(path string) ([]byte, error) {
+	url := c.base + "/" + path
+	resp, err := c.http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Index fetches the index of the package
+func (c *Client) Index(name string) (*PackageIndex, error) {
+	if err := ValidatePackageName(name); err != nil {
+		return nil, err
+	}
+
+	b, err := c.get(name + "/" + INDEX_FILE)
+	if err != nil {
+		return nil, err
+	}
+
+	var index PackageIndex
+	if err := yaml.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("invalid index of package %s: %s", name, err)
+	}
+	return &index, nil
+}
+
+// Resolve returns the highest version of the package satisfying the constraint. An empty constraint is satisfied by
+// all versions.
+func (c *Client) Resolve(name string, constraint string) (string, *Release, error) {
+	index, err := c.Index(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var cs version.Constraints
+	if constraint != "" {
+		if cs, err = storage.ParseConstraint(constraint); err != nil {
+			return "", nil, fmt.Errorf("invalid version constraint %s: %s", constraint, err)
+		}
+	}
+
+	var best *version.Version
+	bestStr := ""
+	for vStr := range index.Versions {
+		v, err := version.NewVersion(vStr)
+		if err != nil || (cs != nil && !cs.Check(v)) {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best = v
+			bestStr = vStr
+		}
+	}
+
+	if best == nil {
+		return "", nil, fmt.Errorf("no version of package %s satisfies \"%s\"", name, constraint)
+	}
+	return bestStr, index.Versions[bestStr], nil
+}
+
+// Fetch downloads the archive of a release and verifies it
+func (c *Client) Fetch(name string, release *Release) (*storage.Bundle, error) {
+	b, err := c.get(name + "/" + release.File)
+	if err != nil {
+		return nil, err
+	}
+
+	if checksum(b) != release.Checksum {
+		return nil, fmt.Errorf("checksum mismatch for %s of package %s", release.File, name)
+	}
+
+	return storage.ReadBundle(bytes.NewReader(b), int64(len(b)))
+}
+
+// Publish adds a new version of a package to the registry located in the given directory. Released versions are
+// immutable, hence publishing an existing version fails.
+func Publish(dir string, name string, v string, archive []byte) (*Release, error) {
+	if err := ValidatePackageName(name); err != nil {
+		return nil, err
+	}
+	if _, err := version.NewVersion(v); err != nil {
+		return nil, fmt.Errorf("invalid version %s: %s", v, err)
+	}
+
+	bundle, err := storage.ReadBundle(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+
+	pkgDir := filepath.Join(dir, name)
+	if _, err := utils.EnsureDirExists(pkgDir); err != nil {
+		return nil, err
+	}
+
+	indexFile := filepath.Join(pkgDir, INDEX_FILE)
+	index := &PackageIndex{Name: name}
+	if b, err := ioutil.ReadFile(indexFile); err == nil {
+		if err := yaml.Unmarshal(b, index); err != nil {
+			return nil, fmt.Errorf("invalid index of package %s: %s", name, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if index.Versions == nil {
+		index.Versions = make(map[string]*Release)
+	}
+	if _, ok := index.Versions[v]; ok {
+		return nil, fmt.Errorf("version %s of package %s has already been published", v, name)
+	}
+
+	release := &Release{
+		File:     fmt.Sprintf("%s-%s.zip", name, v),
+		Checksum: checksum(archive),
+		Main:     bundle.Manifest.Main,
+	}
+	if err := ioutil.WriteFile(filepath.Join(pkgDir, release.File), archive, 0644); err != nil {
+		return nil, err
+	}
+
+	index.Versions[v] = release
+	b, err := yaml.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	return release, ioutil.WriteFile(indexFile, b, 0644)
+}
@@ -0,0 +1,410 @@
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/Bitspark/go-version"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
+)
+
+const BUNDLE_MANIFEST = "manifest.yaml"
+
+// MaxBundleSize limits the size of bundles read, both of the archive and of all files extracted from it
+var MaxBundleSize int64 = 64 << 20
+
+// BundleManifest describes the content of a bundle. Main is the operator the bundle has been exported for, Operators
+// lists it and all operators it depends on.
+type BundleManifest struct {
+	SlangVersion string        `yaml:"slangVersion"`
+	TimeUnix     int64         `yaml:"timeUnix"`
+	Main         string        `yaml:"main"`
+	Operators    []BundleEntry `yaml:"operators"`
+}
+
+type BundleEntry struct {
+	Id       string `yaml:"id"`
+	Name     string `yaml:"name"`
+	Version  string `yaml:"version,omitempty"`
+	File     string `yaml:"file"`
+	Checksum string `yaml:"checksum"`
+}
+
+type Bundle struct {
+	Manifest  BundleManifest
+	Operators []core.OperatorDef
+}
+
+// ImportResult reports which operators of a bundle have been stored, which were already present and which conflict
+// with different operators having the same id
+type ImportResult struct {
+	Imported  []uuid.UUID `json:"imported"`
+	Unchanged []uuid.UUID `json:"unchanged"`
+	Conflicts []uuid.UUID `json:"conflicts"`
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func marshalOpDef(opDef core.OperatorDef) ([]byte, error) {
+	return yaml.Marshal(&opDef)
+}
+
+// Dependencies returns the ids of all non-elementary operators the operator depends on, directly or transitively.
+// The operator itself is not contained.
+func (s *Storage) Dependencies(opId uuid.UUID) ([]uuid.UUID, error) {
+	found := make(map[uuid.UUID]bool)
+	if err := s.collectDependencies(opId, found); err != nil {
+		return nil, err
+	}
+	delete(found, opId)
+
+	deps := make([]uuid.UUID, 0, len(found))
+	for depId := range found {
+		deps = append(deps, depId)
+	}
+	sortIds(deps)
+	return deps, nil
+}
+
+func (s *Storage) collectDependencies(opId uuid.UUID, found map[uuid.UUID]bool) error {
+	return s.collectRefDependencies(opId.String(), found)
+}
+
+func (s *Storage) collectRefDependencies(ref string, found map[uuid.UUID]bool) error {
+	idStr, _ := core.SplitOperatorRef(ref)
+	opId, err := uuid.Parse(idStr)
+	if err != nil {
+		return fmt.Errorf("invalid operator id %s", ref)
+	}
+	if found[opId] {
+		return nil
+	}
+	found[opId] = true
+
+	opDef, err := s.LoadRef(ref)
+	if err != nil {
+		return err
+	}
+
+	for _, insDef := range opDef.InstanceDefs {
+		if elem.IsRegistered(insDef.Operator) {
+			continue
+		}
+		if err := s.collectRefDependencies(insDef.Operator, found); err != nil {
+			return fmt.Errorf("%s: instance %s: %s", opDef.Id, insDef.Name, err)
+		}
+	}
+	return nil
+}
+
+// collectBundleOperators adds the referenced operator and all operators it depends on. Pinned versions are resolved
+// and operators are keyed by id and version, so that all versions of an operator in use are contained.
+func (s *Storage) collectBundleOperators(ref string, found map[string]bool, opDefs *[]core.OperatorDef) error {
+	opDef, err := s.LoadRef(ref)
+	if err != nil {
+		return err
+	}
+
+	key := opDef.Id + "@" + opDef.Meta.Version
+	if found[key] {
+		return nil
+	}
+	found[key] = true
+	*opDefs = append(*opDefs, *opDef)
+
+	for _, insDef := range opDef.InstanceDefs {
+		if elem.IsRegistered(insDef.Operator) {
+			continue
+		}
+		if err := s.collectBundleOperators(insDef.Operator, found, opDefs); err != nil {
+			return fmt.Errorf("%s: instance %s: %s", opDef.Id, insDef.Name, err)
+		}
+	}
+	return nil
+}
+
+// PackBundle writes the operator together with all operators it depends on into a zip archive
+func (s *Storage) PackBundle(w io.Writer, opId uuid.UUID, slangVersion string) (*BundleManifest, error) {
+	var opDefs []core.OperatorDef
+	if err := s.collectBundleOperators(opId.String(), make(map[string]bool), &opDefs); err != nil {
+		return nil, err
+	}
+
+	manifest := &BundleManifest{
+		SlangVersion: slangVersion,
+		TimeUnix:     time.Now().Unix(),
+		Main:         opId.String(),
+	}
+
+	zipWriter := zip.NewWriter(w)
+
+	for _, opDef := range opDefs {
+		b, err := marshalOpDef(opDef)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := BundleEntry{
+			Id:       opDef.Id,
+			Name:     opDef.Meta.Name,
+			Version:  opDef.Meta.Version,
+			File:     opDef.Id + ".yaml",
+			Checksum: checksum(b),
+		}
+		if entry.Version != "" {
+			entry.File = opDef.Id + "@" + entry.Version + ".yaml"
+		}
+
+		fileWriter, err := zipWriter.Create(entry.File)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fileWriter.Write(b); err != nil {
+			return nil, err
+		}
+
+		manifest.Operators = append(manifest.Operators, entry)
+	}
+
+	manifestBytes, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	fileWriter, err := zipWriter.Create(BUNDLE_MANIFEST)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fileWriter.Write(manifestBytes); err != nil {
+		return nil, err
+	}
+
+	return manifest, zipWriter.Close()
+}
+
+// ReadBundle reads a bundle and validates it against its manifest. All operators the operators of the bundle depend
+// on have to be contained.
+func ReadBundle(r io.ReaderAt, size int64) (*Bundle, error) {
+	if size > MaxBundleSize {
+		return nil, fmt.Errorf("bundle exceeds %d bytes", MaxBundleSize)
+	}
+
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sizes in the archive cannot be trusted, hence the extracted bytes are counted
+	files := make(map[string][]byte)
+	remaining := MaxBundleSize
+	for _, file := range zipReader.File {
+		fileReader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(fileReader, remaining+1))
+		fileReader.Close()
+		if err != nil {
+			return nil, err
+		}
+		remaining -= int64(len(b))
+		if remaining < 0 {
+			return nil, fmt.Errorf("bundle exceeds %d bytes", MaxBundleSize)
+		}
+		files[file.Name] = b
+	}
+
+	manifestBytes, ok := files[BUNDLE_MANIFEST]
+	if !ok {
+		return nil, fmt.Errorf("bundle has no %s", BUNDLE_MANIFEST)
+	}
+
+	bundle := &Bundle{}
+	if err := yaml.Unmarshal(manifestBytes, &bundle.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %s", err)
+	}
+
+	mainFound := false
+	for _, entry := range bundle.Manifest.Operators {
+		b, ok := files[entry.File]
+		if !ok {
+			return nil, fmt.Errorf("bundle misses file %s of operator %s", entry.File, entry.Id)
+		}
+		if checksum(b) != entry.Checksum {
+			return nil, fmt.Errorf("checksum mismatch for operator %s", entry.Id)
+		}
+
+		var opDef core.OperatorDef
+		if err := yaml.Unmarshal(b, &opDef); err != nil {
+			return nil, fmt.Errorf("invalid operator %s: %s", entry.Id, err)
+		}
+		if opDef.Id != entry.Id {
+			return nil, fmt.Errorf("operator %s has id %s", entry.Id, opDef.Id)
+		}
+		if err := opDef.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid operator %s: %s", entry.Id, err)
+		}
+
+		mainFound = mainFound || entry.Id == bundle.Manifest.Main
+		bundle.Operators = append(bundle.Operators, opDef)
+	}
+
+	if !mainFound {
+		return nil, fmt.Errorf("bundle misses main operator %s", bundle.Manifest.Main)
+	}
+
+	if err := bundle.checkReferences(); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// checkReferences makes sure that all instances refer to elementary operators or to operators of the bundle
+// satisfying their version constraints
+func (b *Bundle) checkReferences() error {
+	versions := make(map[string][]string)
+	for _, opDef := range b.Operators {
+		versions[opDef.Id] = append(versions[opDef.Id], opDef.Meta.Version)
+	}
+
+	for _, opDef := range b.Operators {
+		for _, insDef := range opDef.InstanceDefs {
+			if elem.IsRegistered(insDef.Operator) {
+				continue
+			}
+			if !bundleContains(versions, insDef.Operator) {
+				return fmt.Errorf("operator %s: instance %s refers to %s which is not contained in the bundle", opDef.Id, insDef.Name, insDef.Operator)
+			}
+		}
+	}
+	return nil
+}
+
+func bundleContains(versions map[string][]string, ref string) bool {
+	idStr, constraint := core.SplitOperatorRef(ref)
+	vs, ok := versions[idStr]
+	if !ok {
+		return false
+	}
+	if constraint == "" {
+		return true
+	}
+
+	cs, err := ParseConstraint(constraint)
+	if err != nil {
+		return false
+	}
+	for _, vStr := range vs {
+		if v, err := version.NewVersion(vStr); err == nil && cs.Check(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportBundle stores all operators of the bundle which are not present yet. Operators with ids already taken by
+// different operators are reported as conflicts and nothing is stored, unless overwrite is set. Versions are stored
+// in ascending order and versions older than the stored operator do not replace it.
+func (s *Storage) ImportBundle(bundle *Bundle, overwrite bool) (*ImportResult, error) {
+	if s.dumper == nil {
+		return nil, fmt.Errorf("storage cannot store operators")
+	}
+
+	result := &ImportResult{[]uuid.UUID{}, []uuid.UUID{}, []uuid.UUID{}}
+	toStore := []core.OperatorDef{}
+
+	for _, opDef := range bundle.Operators {
+		opId, err := uuid.Parse(opDef.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		// Versioned operators are compared with the same version
+		var existing *core.OperatorDef
+		if opDef.Meta.Version != "" {
+			existing, _ = s.LoadVersion(opId, opDef.Meta.Version)
+		} else if s.IsLoadable(opId) {
+			if existing, err = s.Load(opId); err != nil {
+				return nil, err
+			}
+		}
+		if existing == nil {
+			toStore = append(toStore, opDef)
+			continue
+		}
+		a, _ := marshalOpDef(*existing)
+		b, _ := marshalOpDef(opDef)
+		if bytes.Equal(a, b) {
+			result.Unchanged = append(result.Unchanged, opId)
+			continue
+		}
+
+		if !overwrite || !s.IsDumpable(opId) {
+			result.Conflicts = append(result.Conflicts, opId)
+			continue
+		}
+		toStore = append(toStore, opDef)
+	}
+
+	if len(result.Conflicts) != 0 {
+		return result, fmt.Errorf("%d operators conflict with existing operators", len(result.Conflicts))
+	}
+
+	sort.SliceStable(toStore, func(i, j int) bool {
+		return versionLess(toStore[i].Meta.Version, toStore[j].Meta.Version)
+	})
+	for _, opDef := range toStore {
+		var opId uuid.UUID
+		var err error
+		if s.isOlderThanStored(opDef) {
+			opId, err = s.StoreVersion(opDef)
+		} else {
+			opId, err = s.Store(opDef)
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Imported = append(result.Imported, opId)
+	}
+
+	return result, nil
+}
+
+// versionLess orders operators without or with invalid versions before versioned ones
+func versionLess(a, b string) bool {
+	va, errA := version.NewVersion(a)
+	vb, errB := version.NewVersion(b)
+	if errB != nil {
+		return false
+	}
+	if errA != nil {
+		return true
+	}
+	return va.LessThan(vb)
+}
+
+// isOlderThanStored tells whether the version of the operator is lower than the version of the stored operator
+func (s *Storage) isOlderThanStored(opDef core.OperatorDef) bool {
+	opId, err := uuid.Parse(opDef.Id)
+	if err != nil || opDef.Meta.Version == "" || !s.dumper.Has(opId) {
+		return false
+	}
+	stored, err := s.dumper.Load(opId)
+	if err != nil {
+		return false
+	}
+	return versionLess(opDef.Meta.Version, stored.Meta.Version)
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/google/uuid"
+)
+
+const (
+	DIFF_ADDED   = "added"
+	DIFF_REMOVED = "removed"
+	DIFF_CHANGED = "changed"
+)
+
+// Change is a difference between two operator definitions. Path is the dot separated path of the changed value,
+// e.g. "operators.add.properties.expression". Instances are identified by name instead of by index.
+type Change struct {
+	Path string      `json:"path"`
+	Kind string      `json:"kind"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// ErrNoHistory is returned in case the storage does not keep the history of operators
+var ErrNoHistory = errors.New("storage does not keep the history of operators")
+
+// Diff returns the structural differences between two operator definitions
+func Diff(from, to *core.OperatorDef) ([]Change, error) {
+	fromVal, err := toGeneric(from)
+	if err != nil {
+		return nil, err
+	}
+	toVal, err := toGeneric(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+	diffValues("", fromVal, toVal, &changes)
+	return changes, nil
+}
+
+func toGeneric(opDef *core.OperatorDef) (interface{}, error) {
+	b, err := json.Marshal(opDef)
+	if err != nil {
+		return nil, err
+	}
+	var val interface{}
+	err = json.Unmarshal(b, &val)
+	return val, err
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// namedItems turns a list of objects which all have a name into a map from names to objects
+func namedItems(list []interface{}) (map[string]interface{}, bool) {
+	items := make(map[string]interface{})
+	for _, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := obj["name"].(string)
+		if !ok || name == "" {
+			return nil, false
+		}
+		items[name] = obj
+	}
+	return items, true
+}
+
+func diffValues(path string, from, to interface{}, changes *[]Change) {
+	if reflect.DeepEqual(from, to) {
+		return
+	}
+
+	switch fromVal := from.(type) {
+	case map[string]interface{}:
+		if toVal, ok := to.(map[string]interface{}); ok {
+			diffMaps(path, fromVal, toVal, changes)
+			return
+		}
+	case []interface{}:
+		if toVal, ok := to.([]interface{}); ok {
+			fromItems, fromNamed := namedItems(fromVal)
+			toItems, toNamed := namedItems(toVal)
+			if fromNamed && toNamed {
+				diffMaps(path, fromItems, toItems, changes)
+				return
+			}
+		}
+	}
+
+	switch {
+	case from == nil:
+		*changes = append(*changes, Change{path, DIFF_ADDED, nil, to})
+	case to == nil:
+		*changes = append(*changes, Change{path, DIFF_REMOVED, from, nil})
+	default:
+		*changes = append(*changes, Change{path, DIFF_CHANGED, from, to})
+	}
+}
+
+func diffMaps(path string, from, to map[string]interface{}, changes *[]Change) {
+	keys := []string{}
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		diffValues(joinPath(path, key), from[key], to[key], changes)
+	}
+}
+
+func (s *Storage) historian() (Historian, error) {
+	if h, ok := s.dumper.(Historian); ok {
+		return h, nil
+	}
+	return nil, ErrNoHistory
+}
+
+// Revisions returns the revisions of a stored operator, latest first
+func (s *Storage) Revisions(opId uuid.UUID) ([]Revision, error) {
+	h, err := s.historian()
+	if err != nil {
+		return nil, err
+	}
+	return h.Revisions(opId)
+}
+
+// LoadRevision loads an operator as it was in the given revision. An empty revision denotes the current operator.
+func (s *Storage) LoadRevision(opId uuid.UUID, rev string) (*core.OperatorDef, error) {
+	if rev == "" {
+		return s.Load(opId)
+	}
+	h, err := s.historian()
+	if err != nil {
+		return nil, err
+	}
+	return h.LoadRevision(opId, rev)
+}
+
+func (s *Storage) Restore(opId uuid.UUID, rev string) (*core.OperatorDef, error) {
+	h, err := s.historian()
+	if err != nil {
+		return nil, err
+	}
+	return h.Restore(opId, rev)
+}
@@ -1,26 +1,46 @@
 package storage
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Bitspark/go-funk"
+	"github.com/Bitspark/go-version"
 	"github.com/Bitspark/slang/pkg/core"
 	"github.com/Bitspark/slang/pkg/utils"
+	"github.com/fsnotify/fsnotify"
 	"github.com/google/uuid"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 var FILE_ENDINGS = []string{".yaml", ".yml", ".json"} // Order of endings matters!
 
+// FileSystem stores operators as YAML or JSON files anywhere below its root directory. Operators are indexed by the
+// id inside the file, so files may be named and organized freely. New operators are stored under a path derived
+// from their name and first tag, e.g. "math/add-numbers.yaml". Operators with a version are additionally stored as
+// <file>@<version>.yaml next to their file so that all versions are kept side by side.
 type FileSystem struct {
-	root  string
-	cache map[uuid.UUID]*core.OperatorDef
-	uuids []uuid.UUID
+	root     string
+	cache    map[uuid.UUID]*core.OperatorDef
+	paths    map[uuid.UUID]string
+	versions map[uuid.UUID]map[string]string
+	mutex    *sync.Mutex
+	watcher  *fsnotify.Watcher
+	subs     map[chan<- OperatorChange]*subscription
+}
+
+// subscription tracks whether a subscriber missed changes and has to be told to resynchronize
+type subscription struct {
+	resync bool
+	done   chan struct{}
 }
 
 func NewFileSystem(p string) *FileSystem {
@@ -30,35 +50,68 @@ func NewFileSystem(p string) *FileSystem {
 	if !strings.HasSuffix(p, pathSep) {
 		p += pathSep
 	}
-	return &FileSystem{p, make(map[uuid.UUID]*core.OperatorDef), nil}
+	return &FileSystem{p, make(map[uuid.UUID]*core.OperatorDef), nil, nil, &sync.Mutex{}, nil, make(map[chan<- OperatorChange]*subscription)}
 }
 
 func (fs *FileSystem) Has(opId uuid.UUID) bool {
-	all, err := fs.List()
-	return err == nil && funk.Contains(all, opId)
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	_, ok := fs.index()[opId]
+	return ok
 }
 
 func (fs *FileSystem) List() ([]uuid.UUID, error) {
-	if fs.uuids != nil {
-		return fs.uuids, nil
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return funk.Keys(fs.index()).([]uuid.UUID), nil
+}
+
+// Path returns the file the operator is stored in
+func (fs *FileSystem) Path(opId uuid.UUID) (string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if path, ok := fs.index()[opId]; ok {
+		return path, nil
 	}
+	return "", fmt.Errorf("unknown operator for id: %s", opId)
+}
+
+// skipDir tells whether a directory is ignored when indexing, such as hidden directories and installed packages
+func (fs *FileSystem) skipDir(path string, info os.FileInfo) bool {
+	return filepath.Clean(path) != filepath.Clean(fs.root) && (strings.HasPrefix(info.Name(), ".") || info.Name() == PACKAGES_DIR)
+}
+
+// index returns the paths of all operators, the mutex has to be held
+func (fs *FileSystem) index() map[uuid.UUID]string {
+	if fs.paths != nil {
+		return fs.paths
+	}
+
+	fs.paths = make(map[uuid.UUID]string)
+	fs.versions = make(map[uuid.UUID]map[string]string)
+	fs.scan(fs.root)
+	fs.addLatestVersions()
+	return fs.paths
+}
 
-	opsFilePathSet := make(map[uuid.UUID]bool)
+// scan adds all operators defined by the file or by the files below the directory to the index
+func (fs *FileSystem) scan(root string) {
+	paths, versions := fs.paths, fs.versions
 
-	_ = filepath.Walk(fs.root, func(path string, info os.FileInfo, err error) error {
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			log.Printf("cannot read file %s: %s", path, err)
 			return nil
 		}
 
-		// Prevent recursive walk. Just read files within fs.root
-		if info.IsDir() && path != fs.root {
-			return filepath.SkipDir
+		if info.IsDir() {
+			if fs.skipDir(path, info) {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
-		if info.IsDir() ||
-			strings.HasPrefix(info.Name(), ".") ||
-			!fs.hasSupportedSuffix(info.Name()) {
+		if strings.HasPrefix(info.Name(), ".") || !fs.hasSupportedSuffix(info.Name()) {
 			return nil
 		}
 
@@ -69,70 +122,480 @@ func (fs *FileSystem) List() ([]uuid.UUID, error) {
 			return nil
 		}
 
-		if opId, err := uuid.Parse(opDef.Id); err == nil {
-			opsFilePathSet[opId] = true
-		} else {
+		opId, err := uuid.Parse(opDef.Id)
+		if err != nil {
 			log.Printf("invalid id in OperatorDef file %s: %s", path, err)
 			return nil
 		}
+
+		isVersionFile := strings.Contains(info.Name(), "@")
+		if opDef.Meta.Version != "" {
+			if _, ok := versions[opId]; !ok {
+				versions[opId] = make(map[string]string)
+			}
+			// Version files take precedence over the current operator file
+			if _, ok := versions[opId][opDef.Meta.Version]; !ok || isVersionFile {
+				versions[opId][opDef.Meta.Version] = path
+			}
+		}
+		if isVersionFile {
+			return nil
+		}
+
+		if other, ok := paths[opId]; ok {
+			log.Printf("operator %s is defined by both %s and %s, ignoring the latter", opId, other, path)
+			return nil
+		}
+		paths[opId] = path
 		return nil
 	})
+}
+
+// addLatestVersions represents operators of which only versions exist by their highest version
+func (fs *FileSystem) addLatestVersions() {
+	for opId := range fs.versions {
+		if _, ok := fs.paths[opId]; !ok {
+			fs.paths[opId] = fs.latestVersionFile(fs.versions[opId])
+		}
+	}
+}
+
+// idsBelow returns the ids of all operators stored in the file or below the directory
+func (fs *FileSystem) idsBelow(path string) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for opId := range fs.paths {
+		if isBelow(fs.paths[opId], path) {
+			ids = append(ids, opId)
+			continue
+		}
+		for _, p := range fs.versions[opId] {
+			if isBelow(p, path) {
+				ids = append(ids, opId)
+				break
+			}
+		}
+	}
+	return ids
+}
 
-	fs.uuids = funk.Keys(opsFilePathSet).([]uuid.UUID)
+func isBelow(path string, root string) bool {
+	return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
+// reindex updates the index after the file or directory has changed and returns the ids of all operators affected
+func (fs *FileSystem) reindex(path string) []uuid.UUID {
+	if fs.paths == nil {
+		fs.index()
+		return fs.idsBelow(path)
+	}
+
+	affected := fs.idsBelow(path)
+	for _, opId := range affected {
+		if isBelow(fs.paths[opId], path) {
+			delete(fs.paths, opId)
+		}
+		for v, p := range fs.versions[opId] {
+			if isBelow(p, path) {
+				delete(fs.versions[opId], v)
+			}
+		}
+		if len(fs.versions[opId]) == 0 {
+			delete(fs.versions, opId)
+		}
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		fs.scan(path)
+	}
+	fs.addLatestVersions()
 
-	return fs.List()
+	for _, opId := range fs.idsBelow(path) {
+		if !funk.Contains(affected, opId) {
+			affected = append(affected, opId)
+		}
+	}
+	for _, opId := range affected {
+		delete(fs.cache, opId)
+	}
+	return affected
 }
 
 func (fs *FileSystem) Load(opId uuid.UUID) (*core.OperatorDef, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
 	if def, ok := fs.cache[opId]; ok {
 		return def, nil
 	}
 
-	opDefFile, err := fs.getFilePath(opId)
-	if err != nil {
-		return nil, err
+	opDefFile, ok := fs.index()[opId]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator for id: %s", opId)
 	}
 
-	fs.cache[opId], err = fs.readOpDefFile(opDefFile)
+	opDef, err := fs.readOpDefFile(opDefFile)
 	if err != nil {
 		return nil, err
 	}
 
-	return fs.Load(opId)
+	fs.cache[opId] = opDef
+	return opDef, nil
 }
 
 func (fs *FileSystem) Dump(opDef core.OperatorDef) (uuid.UUID, error) {
+	opId, _, err := fs.dump(opDef)
+	return opId, err
+}
+
+// dump stores the operator and returns the paths of all files written
+func (fs *FileSystem) dump(opDef core.OperatorDef) (uuid.UUID, []string, error) {
 	opId, err := uuid.Parse(opDef.Id)
 
 	if err != nil {
-		return opId, fmt.Errorf(`id is not a valid UUID v4: "%s" --> "%s"`, opDef.Id, err)
+		return opId, nil, fmt.Errorf(`id is not a valid UUID v4: "%s" --> "%s"`, opDef.Id, err)
 	}
 
-	cwd := fs.root
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	// Operators stay where they are, new operators get a readable path
+	absPath, ok := fs.index()[opId]
+	if !ok || strings.Contains(filepath.Base(absPath), "@") {
+		absPath = fs.newPath(opDef)
+	}
 
-	relPath := strings.Replace(opId.String(), ".", string(filepath.Separator), -1)
-	absPath := filepath.Join(cwd, relPath+".yaml")
 	_, err = utils.EnsureDirExists(filepath.Dir(absPath))
 
 	if err != nil {
-		return opId, err
+		return opId, nil, err
 	}
 
 	delete(fs.cache, opId)
-	fs.uuids = nil
 
+	var opDefBytes []byte
+	if utils.IsJSON(absPath) {
+		opDefBytes, err = json.MarshalIndent(&opDef, "", "  ")
+	} else {
+		opDefBytes, err = yaml.Marshal(&opDef)
+	}
+
+	if err != nil {
+		return opId, nil, err
+	}
+
+	err = ioutil.WriteFile(absPath, opDefBytes, os.ModePerm)
+	if err != nil {
+		return opId, nil, err
+	}
+	written := []string{absPath}
+
+	if opDef.Meta.Version != "" {
+		versionPath, err := fs.writeVersionFile(absPath, opDef)
+		if err != nil {
+			return opId, written, err
+		}
+		written = append(written, versionPath)
+	}
+
+	for _, path := range written {
+		fs.reindex(path)
+	}
+
+	return opId, written, nil
+}
+
+// writeVersionFile writes the version file next to the operator file
+func (fs *FileSystem) writeVersionFile(absPath string, opDef core.OperatorDef) (string, error) {
 	opDefYaml, err := yaml.Marshal(&opDef)
+	if err != nil {
+		return "", err
+	}
+	versionPath := strings.TrimSuffix(absPath, filepath.Ext(absPath)) + "@" + opDef.Meta.Version + ".yaml"
+	return versionPath, ioutil.WriteFile(versionPath, opDefYaml, os.ModePerm)
+}
+
+// DumpVersion only writes the version file of the operator, so that the current operator file is left unchanged
+func (fs *FileSystem) DumpVersion(opDef core.OperatorDef) (uuid.UUID, error) {
+	opId, _, err := fs.dumpVersion(opDef)
+	return opId, err
+}
 
+// dumpVersion stores the version of the operator and returns the paths of all files written
+func (fs *FileSystem) dumpVersion(opDef core.OperatorDef) (uuid.UUID, []string, error) {
+	opId, err := uuid.Parse(opDef.Id)
 	if err != nil {
-		return opId, err
+		return opId, nil, fmt.Errorf(`id is not a valid UUID v4: "%s" --> "%s"`, opDef.Id, err)
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	absPath, ok := fs.index()[opId]
+	if !ok || strings.Contains(filepath.Base(absPath), "@") {
+		absPath = fs.newPath(opDef)
+	}
+	if _, err := utils.EnsureDirExists(filepath.Dir(absPath)); err != nil {
+		return opId, nil, err
 	}
 
-	err = ioutil.WriteFile(absPath, opDefYaml, os.ModePerm)
+	versionPath, err := fs.writeVersionFile(absPath, opDef)
 	if err != nil {
-		return opId, err
+		return opId, nil, err
 	}
+	fs.reindex(versionPath)
+	return opId, []string{versionPath}, nil
+}
 
-	return opId, nil
+func (fs *FileSystem) Remove(opId uuid.UUID) error {
+	_, err := fs.remove(opId)
+	return err
+}
+
+// remove removes the operator including all its versions and returns the paths of all files removed
+func (fs *FileSystem) remove(opId uuid.UUID) ([]string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	path, ok := fs.index()[opId]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator for id: %s", opId)
+	}
+
+	files := []string{path}
+	for _, p := range fs.versions[opId] {
+		if p != path {
+			files = append(files, p)
+		}
+	}
+
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		fs.reindex(file)
+	}
+	return files, nil
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// newPath derives a path for a new operator from its name and first tag, the mutex has to be held
+func (fs *FileSystem) newPath(opDef core.OperatorDef) string {
+	dir := fs.root
+	if len(opDef.Meta.Tags) != 0 && slug(opDef.Meta.Tags[0]) != "" {
+		dir = filepath.Join(dir, slug(opDef.Meta.Tags[0]))
+	}
+
+	name := slug(opDef.Meta.Name)
+	if name == "" {
+		return filepath.Join(dir, opDef.Id+".yaml")
+	}
+
+	taken := func(path string) bool {
+		if utils.FileExists(path) {
+			return true
+		}
+		for _, p := range fs.index() {
+			if p == path {
+				return true
+			}
+		}
+		return false
+	}
+
+	path := filepath.Join(dir, name+".yaml")
+	if taken(path) {
+		path = filepath.Join(dir, name+"-"+opDef.Id[:8]+".yaml")
+	}
+	if taken(path) {
+		path = filepath.Join(dir, name+"-"+opDef.Id+".yaml")
+	}
+	return path
+}
+
+func (fs *FileSystem) Versions(opId uuid.UUID) ([]string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.index()
+	return funk.Keys(fs.versions[opId]).([]string), nil
+}
+
+func (fs *FileSystem) LoadVersion(opId uuid.UUID, v string) (*core.OperatorDef, error) {
+	fs.mutex.Lock()
+	fs.index()
+	path, ok := fs.versions[opId][v]
+	fs.mutex.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown version %s of operator %s", v, opId)
+	}
+	return fs.readOpDefFile(path)
+}
+
+func (fs *FileSystem) latestVersionFile(versions map[string]string) string {
+	var latest *version.Version
+	latestFile := ""
+	for vStr, path := range versions {
+		v, err := version.NewVersion(vStr)
+		if err != nil {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+			latestFile = path
+		}
+	}
+	return latestFile
+}
+
+// Watch makes the file system pick up changes other processes make to the files below its root directory. Cached
+// operators are dropped as soon as their files change and subscribers are informed about the operators affected.
+func (fs *FileSystem) Watch() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if fs.watcher != nil {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := fs.watchDir(watcher, fs.root); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	fs.watcher = watcher
+	go fs.watch(watcher)
+	return nil
+}
+
+// Close stops watching the file system
+func (fs *FileSystem) Close() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if fs.watcher == nil {
+		return nil
+	}
+	err := fs.watcher.Close()
+	fs.watcher = nil
+	return err
+}
+
+func (fs *FileSystem) watchDir(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if fs.skipDir(path, info) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+func (fs *FileSystem) watch(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := fs.watchDir(watcher, event.Name); err != nil {
+						log.Printf("cannot watch %s: %s", event.Name, err)
+					}
+				}
+			}
+
+			fs.notify(filepath.Clean(event.Name))
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("watching %s: %s", fs.root, err)
+		}
+	}
+}
+
+// notify updates the index after a file has changed and informs all subscribers about the operators affected
+func (fs *FileSystem) notify(path string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	changes := []OperatorChange{}
+	for _, opId := range fs.reindex(path) {
+		if p, ok := fs.paths[opId]; ok {
+			changes = append(changes, OperatorChange{opId, p, CHANGE_STORED})
+		} else {
+			changes = append(changes, OperatorChange{opId, path, CHANGE_REMOVED})
+		}
+	}
+
+	for ch, sub := range fs.subs {
+		if sub.resync {
+			// The resynchronization covers these changes
+			continue
+		}
+		for _, change := range changes {
+			// Slow subscribers must not block the watcher
+			select {
+			case ch <- change:
+				continue
+			default:
+			}
+			sub.resync = true
+			go fs.resync(ch, sub)
+			break
+		}
+	}
+}
+
+// resync sends CHANGE_RESYNC to a subscriber which missed changes as soon as its channel has room again
+func (fs *FileSystem) resync(ch chan<- OperatorChange, sub *subscription) {
+	for {
+		fs.mutex.Lock()
+		select {
+		case ch <- OperatorChange{Kind: CHANGE_RESYNC}:
+			sub.resync = false
+			fs.mutex.Unlock()
+			return
+		default:
+		}
+		fs.mutex.Unlock()
+
+		select {
+		case <-sub.done:
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func (fs *FileSystem) Subscribe(ch chan<- OperatorChange) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if _, ok := fs.subs[ch]; !ok {
+		fs.subs[ch] = &subscription{done: make(chan struct{})}
+	}
+}
+
+func (fs *FileSystem) Unsubscribe(ch chan<- OperatorChange) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if sub, ok := fs.subs[ch]; ok {
+		close(sub.done)
+		delete(fs.subs, ch)
+	}
 }
 
 func (fs *FileSystem) hasSupportedSuffix(filePath string) bool {
@@ -143,10 +606,6 @@ func (fs *FileSystem) getInstanceName(opDefFilePath string) string {
 	return strings.TrimSuffix(filepath.Base(opDefFilePath), filepath.Ext(opDefFilePath))
 }
 
-func (fs *FileSystem) getFilePath(opId uuid.UUID) (string, error) {
-	return utils.FileWithFileEnding(filepath.Join(fs.root, opId.String()), FILE_ENDINGS)
-}
-
 func (fs *FileSystem) readOpDefFile(opDefFile string) (*core.OperatorDef, error) {
 	b, err := ioutil.ReadFile(opDefFile)
 	if err != nil {
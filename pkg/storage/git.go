@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/google/uuid"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// Historian is implemented by dumpers which keep the history of operators
+type Historian interface {
+	Revisions(opId uuid.UUID) ([]Revision, error)
+	LoadRevision(opId uuid.UUID, rev string) (*core.OperatorDef, error)
+	Restore(opId uuid.UUID, rev string) (*core.OperatorDef, error)
+}
+
+type Revision struct {
+	Hash    string    `json:"hash"`
+	Message string    `json:"message"`
+	Author  string    `json:"author"`
+	Time    time.Time `json:"time"`
+}
+
+// GitFileSystem is a FileSystem whose directory is a git repository. Each dump is committed so that previous
+// revisions of operators can be inspected and restored. Revisions are looked up by the file the operator is
+// currently stored in.
+type GitFileSystem struct {
+	*FileSystem
+	repo   *git.Repository
+	mutex  *sync.Mutex
+	Author string
+	Email  string
+}
+
+// NewGitFileSystem opens the git repository at the given path or initializes a new one
+func NewGitFileSystem(p string) (*GitFileSystem, error) {
+	fs := NewFileSystem(p)
+
+	repo, err := git.PlainOpen(fs.root)
+	if err == git.ErrRepositoryNotExists {
+		repo, err = git.PlainInit(fs.root, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitFileSystem{fs, repo, &sync.Mutex{}, "slang", "slang@localhost"}, nil
+}
+
+func (g *GitFileSystem) Dump(opDef core.OperatorDef) (uuid.UUID, error) {
+	return g.dump(opDef, fmt.Sprintf("Update %s (%s)", opDef.Meta.Name, opDef.Id), g.FileSystem.dump)
+}
+
+func (g *GitFileSystem) DumpVersion(opDef core.OperatorDef) (uuid.UUID, error) {
+	msg := fmt.Sprintf("Add version %s of %s (%s)", opDef.Meta.Version, opDef.Meta.Name, opDef.Id)
+	return g.dump(opDef, msg, g.FileSystem.dumpVersion)
+}
+
+// dump writes the operator with the write function and commits the files written
+func (g *GitFileSystem) dump(opDef core.OperatorDef, msg string, write func(core.OperatorDef) (uuid.UUID, []string, error)) (uuid.UUID, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	opId, paths, err := write(opDef)
+	if err != nil {
+		return opId, err
+	}
+
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return opId, err
+	}
+
+	files := []string{}
+	for _, path := range paths {
+		file, err := g.relPath(path)
+		if err != nil {
+			return opId, err
+		}
+		if _, err := wt.Add(file); err != nil {
+			return opId, err
+		}
+		files = append(files, file)
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return opId, err
+	}
+	changed := false
+	for _, file := range files {
+		if s := status.File(file); s.Staging != git.Unmodified && s.Staging != git.Untracked {
+			changed = true
+		}
+	}
+	if !changed {
+		return opId, nil
+	}
+
+	_, err = wt.Commit(msg, &git.CommitOptions{
+		Author: &object.Signature{Name: g.Author, Email: g.Email, When: time.Now()},
+	})
+	return opId, err
+}
+
+func (g *GitFileSystem) Remove(opId uuid.UUID) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	opDef, err := g.FileSystem.Load(opId)
+	if err != nil {
+		return err
+	}
+	name := opDef.Meta.Name
+
+	paths, err := g.FileSystem.remove(opId)
+	if err != nil {
+		return err
+	}
+
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	for _, path := range paths {
+		file, err := g.relPath(path)
+		if err != nil {
+			return err
+		}
+		// Files which have never been committed are simply gone
+		if _, err := wt.Remove(file); err == nil {
+			committed = true
+		}
+	}
+	if !committed {
+		return nil
+	}
+
+	_, err = wt.Commit(fmt.Sprintf("Remove %s (%s)", name, opId), &git.CommitOptions{
+		Author: &object.Signature{Name: g.Author, Email: g.Email, When: time.Now()},
+	})
+	return err
+}
+
+// relPath returns the path of the file within the repository
+func (g *GitFileSystem) relPath(path string) (string, error) {
+	rel, err := filepath.Rel(g.root, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// fileName returns the path of the file the operator is currently stored in within the repository
+func (g *GitFileSystem) fileName(opId uuid.UUID) (string, error) {
+	path, err := g.Path(opId)
+	if err != nil {
+		return "", err
+	}
+	return g.relPath(path)
+}
+
+// Revisions returns all revisions of the operator, latest first
+func (g *GitFileSystem) Revisions(opId uuid.UUID) ([]Revision, error) {
+	revs := []Revision{}
+	if _, err := g.repo.Head(); err == plumbing.ErrReferenceNotFound {
+		return revs, nil
+	}
+
+	fileName, err := g.fileName(opId)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := g.repo.Log(&git.LogOptions{FileName: &fileName})
+	if err != nil {
+		return nil, err
+	}
+
+	err = commits.ForEach(func(c *object.Commit) error {
+		revs = append(revs, Revision{c.Hash.String(), c.Message, c.Author.Name, c.Author.When})
+		return nil
+	})
+	// The file filtering iterator reports the end of the history as EOF
+	if err == io.EOF {
+		err = nil
+	}
+	return revs, err
+}
+
+func (g *GitFileSystem) LoadRevision(opId uuid.UUID, rev string) (*core.OperatorDef, error) {
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %s: %s", rev, err)
+	}
+	commit, err := g.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName, err := g.fileName(opId)
+	if err != nil {
+		return nil, err
+	}
+	file, err := commit.File(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("operator %s does not exist in revision %s", opId, rev)
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	opDef, err := core.ParseYAMLOperatorDef(contents)
+	if err != nil {
+		return nil, err
+	}
+	return &opDef, nil
+}
+
+// Restore stores the operator as it was in the given revision, which creates a new revision
+func (g *GitFileSystem) Restore(opId uuid.UUID, rev string) (*core.OperatorDef, error) {
+	opDef, err := g.LoadRevision(opId, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := g.dump(*opDef, fmt.Sprintf("Restore %s (%s) from %s", opDef.Meta.Name, opDef.Id, rev), g.FileSystem.dump); err != nil {
+		return nil, err
+	}
+	return opDef, nil
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"log"
+	"sort"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/google/uuid"
+)
+
+// DependencyGraph holds which operators embed which other operators as instances. Elementary operators are not part
+// of the graph. Versions are ignored, an operator pinned at any version counts as dependency.
+type DependencyGraph struct {
+	names      map[uuid.UUID]string
+	deps       map[uuid.UUID][]uuid.UUID
+	dependents map[uuid.UUID][]uuid.UUID
+}
+
+// DependencyGraph builds the graph over all operators of the storage. Operators which cannot be loaded are skipped.
+func (s *Storage) DependencyGraph() (*DependencyGraph, error) {
+	opIds, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &DependencyGraph{
+		names:      make(map[uuid.UUID]string),
+		deps:       make(map[uuid.UUID][]uuid.UUID),
+		dependents: make(map[uuid.UUID][]uuid.UUID),
+	}
+	for _, opId := range opIds {
+		opDef, err := s.Load(opId)
+		if err != nil {
+			log.Printf("skipping operator %s in dependency graph: %s", opId, err)
+			continue
+		}
+		g.add(opId, opDef)
+	}
+	for _, ids := range g.deps {
+		sortIds(ids)
+	}
+	for _, ids := range g.dependents {
+		sortIds(ids)
+	}
+	return g, nil
+}
+
+func (g *DependencyGraph) add(opId uuid.UUID, opDef *core.OperatorDef) {
+	g.names[opId] = opDef.Meta.Name
+	seen := make(map[uuid.UUID]bool)
+	for _, insDef := range opDef.InstanceDefs {
+		if elem.IsRegistered(insDef.Operator) {
+			continue
+		}
+		idStr, _ := core.SplitOperatorRef(insDef.Operator)
+		depId, err := uuid.Parse(idStr)
+		if err != nil || seen[depId] {
+			continue
+		}
+		seen[depId] = true
+		g.deps[opId] = append(g.deps[opId], depId)
+		g.dependents[depId] = append(g.dependents[depId], opId)
+	}
+}
+
+// Name returns the name of the operator or an empty string if it is not part of the graph
+func (g *DependencyGraph) Name(opId uuid.UUID) string {
+	return g.names[opId]
+}
+
+// Has returns true if the operator could be loaded from the storage
+func (g *DependencyGraph) Has(opId uuid.UUID) bool {
+	_, ok := g.names[opId]
+	return ok
+}
+
+// Dependencies returns the operators embedded by the operator, only the direct ones unless transitive is true
+func (g *DependencyGraph) Dependencies(opId uuid.UUID, transitive bool) []uuid.UUID {
+	return g.walk(g.deps, opId, transitive)
+}
+
+// Dependents returns the operators embedding the operator, only the direct ones unless transitive is true
+func (g *DependencyGraph) Dependents(opId uuid.UUID, transitive bool) []uuid.UUID {
+	return g.walk(g.dependents, opId, transitive)
+}
+
+func (g *DependencyGraph) walk(edges map[uuid.UUID][]uuid.UUID, opId uuid.UUID, transitive bool) []uuid.UUID {
+	if !transitive {
+		return append([]uuid.UUID{}, edges[opId]...)
+	}
+
+	found := map[uuid.UUID]bool{opId: true}
+	queue := []uuid.UUID{opId}
+	ids := []uuid.UUID{}
+	for len(queue) != 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, id := range edges[next] {
+			if found[id] {
+				continue
+			}
+			found[id] = true
+			ids = append(ids, id)
+			queue = append(queue, id)
+		}
+	}
+	sortIds(ids)
+	return ids
+}
+
+// Dependents returns the ids of all operators embedding the operator, directly or transitively
+func (s *Storage) Dependents(opId uuid.UUID) ([]uuid.UUID, error) {
+	g, err := s.DependencyGraph()
+	if err != nil {
+		return nil, err
+	}
+	return g.Dependents(opId, true), nil
+}
+
+func sortIds(ids []uuid.UUID) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+}
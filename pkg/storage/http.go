@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/google/uuid"
+)
+
+// HTTPLoader loads the operators of another daemon through its /operator/ API. Builtin operators of the remote
+// daemon are skipped. The list of operators and loaded operators are fetched again once they are older than MaxAge.
+type HTTPLoader struct {
+	URL     string
+	Token   string
+	MaxAge  time.Duration
+	client  *http.Client
+	ids     map[uuid.UUID]bool
+	fetched time.Time
+	opDefs  map[uuid.UUID]httpOperatorDef
+	mutex   *sync.Mutex
+}
+
+type httpOperatorDef struct {
+	def     *core.OperatorDef
+	fetched time.Time
+}
+
+// NewHTTPLoader creates a loader for the daemon at the given URL, e.g. http://localhost:5149. The token is passed as
+// bearer token in case the daemon requires authentication.
+func NewHTTPLoader(url string, token string) *HTTPLoader {
+	return &HTTPLoader{
+		URL:    strings.TrimSuffix(url, "/"),
+		Token:  token,
+		MaxAge: 10 * time.Second,
+		client: &http.Client{Timeout: 30 * time.Second},
+		opDefs: make(map[uuid.UUID]httpOperatorDef),
+		mutex:  &sync.Mutex{},
+	}
+}
+
+// get decodes the data of the response of the remote daemon to a GET request into v
+func (l *HTTPLoader) get(path string, v interface{}) error {
+	req, err := http.NewRequest("GET", l.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	if l.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+l.Token)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Error *struct {
+			Msg string `json:"msg"`
+		} `json:"error"`
+	}
+	dec := json.NewDecoder(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		if dec.Decode(&data) == nil && data.Error != nil {
+			return fmt.Errorf("%s: %s", l.URL, data.Error.Msg)
+		}
+		return fmt.Errorf("cannot fetch %s from %s: %s", path, l.URL, resp.Status)
+	}
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid response from %s: %s", l.URL, err)
+	}
+	return nil
+}
+
+// fetch returns the ids of the operators of the remote daemon. The mutex is not held while waiting for the daemon.
+func (l *HTTPLoader) fetch() (map[uuid.UUID]bool, error) {
+	l.mutex.Lock()
+	if l.ids != nil && time.Since(l.fetched) < l.MaxAge {
+		ids := l.ids
+		l.mutex.Unlock()
+		return ids, nil
+	}
+	l.mutex.Unlock()
+
+	var data struct {
+		Objects []struct {
+			Def  core.OperatorDef `json:"def"`
+			Type string           `json:"type"`
+		} `json:"objects"`
+		Error *struct {
+			Msg string `json:"msg"`
+		} `json:"error"`
+	}
+	if err := l.get("/operator/", &data); err != nil {
+		return nil, err
+	}
+	if data.Error != nil {
+		return nil, fmt.Errorf("%s: %s", l.URL, data.Error.Msg)
+	}
+
+	ids := make(map[uuid.UUID]bool)
+	for _, obj := range data.Objects {
+		if obj.Type == "elementary" {
+			continue
+		}
+		if opId, err := uuid.Parse(obj.Def.Id); err == nil {
+			ids[opId] = true
+		}
+	}
+
+	l.mutex.Lock()
+	l.ids = ids
+	l.fetched = time.Now()
+	l.mutex.Unlock()
+	return ids, nil
+}
+
+func (l *HTTPLoader) List() ([]uuid.UUID, error) {
+	ids, err := l.fetch()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]uuid.UUID, 0, len(ids))
+	for opId := range ids {
+		list = append(list, opId)
+	}
+	return list, nil
+}
+
+func (l *HTTPLoader) Has(opId uuid.UUID) bool {
+	ids, err := l.fetch()
+	if err != nil {
+		return false
+	}
+	return ids[opId]
+}
+
+// Load fetches the single operator from the remote daemon, the mutex is not held while waiting for the daemon
+func (l *HTTPLoader) Load(opId uuid.UUID) (*core.OperatorDef, error) {
+	l.mutex.Lock()
+	cached, ok := l.opDefs[opId]
+	l.mutex.Unlock()
+
+	if !ok || time.Since(cached.fetched) >= l.MaxAge {
+		var data struct {
+			Data struct {
+				Def  core.OperatorDef `json:"def"`
+				Type string           `json:"type"`
+			} `json:"data"`
+		}
+		if err := l.get("/operator/def/"+opId.String(), &data); err != nil {
+			return nil, err
+		}
+		if data.Data.Type == "elementary" {
+			return nil, fmt.Errorf("unknown operator for id: %s", opId)
+		}
+
+		cached = httpOperatorDef{&data.Data.Def, time.Now()}
+		l.mutex.Lock()
+		l.opDefs[opId] = cached
+		l.mutex.Unlock()
+	}
+
+	cpyOpDef := cached.def.Copy(true)
+	return &cpyOpDef, nil
+}
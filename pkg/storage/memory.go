@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/google/uuid"
+)
+
+// MemoryLoader loads operators from a fixed set of definitions, such as the blueprints of a SlangFileDef
+type MemoryLoader struct {
+	opDefs map[uuid.UUID]core.OperatorDef
+}
+
+func NewMemoryLoader(opDefs ...core.OperatorDef) *MemoryLoader {
+	l := &MemoryLoader{make(map[uuid.UUID]core.OperatorDef)}
+	for _, opDef := range opDefs {
+		if opId, err := uuid.Parse(opDef.Id); err == nil {
+			l.opDefs[opId] = opDef
+		}
+	}
+	return l
+}
+
+func (l *MemoryLoader) List() ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(l.opDefs))
+	for opId := range l.opDefs {
+		ids = append(ids, opId)
+	}
+	return ids, nil
+}
+
+func (l *MemoryLoader) Has(opId uuid.UUID) bool {
+	_, ok := l.opDefs[opId]
+	return ok
+}
+
+func (l *MemoryLoader) Load(opId uuid.UUID) (*core.OperatorDef, error) {
+	opDef, ok := l.opDefs[opId]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator for id: %s", opId)
+	}
+	cpyOpDef := opDef.Copy(true)
+	return &cpyOpDef, nil
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/google/uuid"
+)
+
+// PACKAGES_DIR is the directory packages are installed into. It is skipped when indexing a project directory.
+const PACKAGES_DIR = "slang_packages"
+
+// PackageLoader loads the operators of installed packages. Each package is installed into its own sub directory of
+// the packages directory. Packages are reindexed whenever the modification time of the packages directory changes,
+// which is the case after packages have been installed or removed.
+type PackageLoader struct {
+	dir      string
+	packages map[string]*FileSystem
+	modTime  time.Time
+	mutex    *sync.Mutex
+}
+
+func NewPackageLoader(dir string) *PackageLoader {
+	return &PackageLoader{dir, nil, time.Time{}, &sync.Mutex{}}
+}
+
+// Reload discards all cached operators
+func (pl *PackageLoader) Reload() {
+	pl.mutex.Lock()
+	defer pl.mutex.Unlock()
+	pl.packages = nil
+}
+
+func (pl *PackageLoader) fileSystems() []*FileSystem {
+	pl.mutex.Lock()
+	defer pl.mutex.Unlock()
+
+	var modTime time.Time
+	if info, err := os.Stat(pl.dir); err == nil {
+		modTime = info.ModTime()
+	}
+	if !modTime.Equal(pl.modTime) {
+		pl.packages = nil
+		pl.modTime = modTime
+	}
+
+	if pl.packages == nil {
+		pl.packages = make(map[string]*FileSystem)
+		infos, _ := ioutil.ReadDir(pl.dir)
+		for _, info := range infos {
+			if info.IsDir() && !strings.HasPrefix(info.Name(), ".") {
+				pl.packages[info.Name()] = NewFileSystem(filepath.Join(pl.dir, info.Name()))
+			}
+		}
+	}
+
+	names := make([]string, 0, len(pl.packages))
+	for name := range pl.packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fss := make([]*FileSystem, len(names))
+	for i, name := range names {
+		fss[i] = pl.packages[name]
+	}
+	return fss
+}
+
+func (pl *PackageLoader) List() ([]uuid.UUID, error) {
+	found := make(map[uuid.UUID]bool)
+	all := []uuid.UUID{}
+	for _, fs := range pl.fileSystems() {
+		ids, err := fs.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if !found[id] {
+				found[id] = true
+				all = append(all, id)
+			}
+		}
+	}
+	return all, nil
+}
+
+func (pl *PackageLoader) Has(opId uuid.UUID) bool {
+	return pl.find(opId) != nil
+}
+
+func (pl *PackageLoader) Load(opId uuid.UUID) (*core.OperatorDef, error) {
+	if fs := pl.find(opId); fs != nil {
+		return fs.Load(opId)
+	}
+	return nil, fmt.Errorf("unknown operator for id: %s", opId)
+}
+
+func (pl *PackageLoader) Versions(opId uuid.UUID) ([]string, error) {
+	found := make(map[string]bool)
+	versions := []string{}
+	for _, fs := range pl.fileSystems() {
+		if !fs.Has(opId) {
+			continue
+		}
+		vs, err := fs.Versions(opId)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vs {
+			if !found[v] {
+				found[v] = true
+				versions = append(versions, v)
+			}
+		}
+	}
+	return versions, nil
+}
+
+func (pl *PackageLoader) LoadVersion(opId uuid.UUID, v string) (*core.OperatorDef, error) {
+	var err error
+	for _, fs := range pl.fileSystems() {
+		if !fs.Has(opId) {
+			continue
+		}
+		var opDef *core.OperatorDef
+		if opDef, err = fs.LoadVersion(opId, v); err == nil {
+			return opDef, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("unknown operator for id: %s", opId)
+	}
+	return nil, err
+}
+
+func (pl *PackageLoader) find(opId uuid.UUID) *FileSystem {
+	for _, fs := range pl.fileSystems() {
+		if fs.Has(opId) {
+			return fs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Bitspark/go-version"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
+)
+
+const SQL_TABLE = "slang_operators"
+
+// SQLStorage stores operators as YAML in a database table. It works with any driver using ? as placeholder, such as
+// SQLite and MySQL. Each version of an operator is kept in its own row, operators without version have an empty
+// version. The row dumped last is the current operator.
+type SQLStorage struct {
+	db *sql.DB
+}
+
+// OpenSQLStorage connects to the database and creates the operator table if it does not exist yet
+func OpenSQLStorage(driver string, dsn string) (*SQLStorage, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSQLStorage(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func NewSQLStorage(db *sql.DB) (*SQLStorage, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + SQL_TABLE + ` (
+		id VARCHAR(36) NOT NULL,
+		version VARCHAR(64) NOT NULL DEFAULT '',
+		name VARCHAR(255) NOT NULL,
+		def TEXT NOT NULL,
+		is_current INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (id, version)
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create table %s: %s", SQL_TABLE, err)
+	}
+	return &SQLStorage{db}, nil
+}
+
+func (s *SQLStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStorage) List() ([]uuid.UUID, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT id FROM ` + SQL_TABLE)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var idStr string
+		if err := rows.Scan(&idStr); err != nil {
+			return nil, err
+		}
+		if id, err := uuid.Parse(idStr); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+func (s *SQLStorage) Has(opId uuid.UUID) bool {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM `+SQL_TABLE+` WHERE id = ?`, opId.String()).Scan(&n)
+	return err == nil && n != 0
+}
+
+// Load loads the current operator, in case only versions have been stored it loads the highest version
+func (s *SQLStorage) Load(opId uuid.UUID) (*core.OperatorDef, error) {
+	rows, err := s.db.Query(`SELECT version, def, is_current FROM `+SQL_TABLE+` WHERE id = ?`, opId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var latest *version.Version
+	defYaml := ""
+	for rows.Next() {
+		var vStr, d string
+		var current int
+		if err := rows.Scan(&vStr, &d, &current); err != nil {
+			return nil, err
+		}
+		if current != 0 {
+			defYaml = d
+			break
+		}
+		if v, err := version.NewVersion(vStr); err == nil && (latest == nil || v.GreaterThan(latest)) {
+			latest = v
+			defYaml = d
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if defYaml == "" {
+		return nil, fmt.Errorf("unknown operator for id: %s", opId)
+	}
+	return parseSQLOperatorDef(defYaml)
+}
+
+func (s *SQLStorage) Versions(opId uuid.UUID) ([]string, error) {
+	rows, err := s.db.Query(`SELECT version FROM `+SQL_TABLE+` WHERE id = ? AND version <> ''`, opId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []string{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *SQLStorage) LoadVersion(opId uuid.UUID, v string) (*core.OperatorDef, error) {
+	var defYaml string
+	err := s.db.QueryRow(`SELECT def FROM `+SQL_TABLE+` WHERE id = ? AND version = ? AND version <> ''`, opId.String(), v).Scan(&defYaml)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown version %s of operator %s", v, opId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSQLOperatorDef(defYaml)
+}
+
+func parseSQLOperatorDef(defYaml string) (*core.OperatorDef, error) {
+	def, err := core.ParseYAMLOperatorDef(defYaml)
+	if err != nil {
+		return nil, err
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// Remove removes the operator including all its versions
+func (s *SQLStorage) Remove(opId uuid.UUID) error {
+	res, err := s.db.Exec(`DELETE FROM `+SQL_TABLE+` WHERE id = ?`, opId.String())
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("unknown operator for id: %s", opId)
+	}
+	return nil
+}
+
+// Dump stores the operator as current operator, other versions are kept
+func (s *SQLStorage) Dump(opDef core.OperatorDef) (uuid.UUID, error) {
+	return s.dump(opDef, true)
+}
+
+// DumpVersion stores the version of the operator, the current operator is left unchanged
+func (s *SQLStorage) DumpVersion(opDef core.OperatorDef) (uuid.UUID, error) {
+	if opDef.Meta.Version == "" {
+		return uuid.Nil, fmt.Errorf("operator %s has no version", opDef.Id)
+	}
+	return s.dump(opDef, false)
+}
+
+func (s *SQLStorage) dump(opDef core.OperatorDef, current bool) (uuid.UUID, error) {
+	opId, err := uuid.Parse(opDef.Id)
+	if err != nil {
+		return opId, fmt.Errorf(`id is not a valid UUID v4: "%s" --> "%s"`, opDef.Id, err)
+	}
+
+	opDefYaml, err := yaml.Marshal(&opDef)
+	if err != nil {
+		return opId, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return opId, err
+	}
+	if err := dumpSQLRow(tx, opId.String(), opDef, string(opDefYaml), current); err != nil {
+		tx.Rollback()
+		return opId, err
+	}
+	return opId, tx.Commit()
+}
+
+func dumpSQLRow(tx *sql.Tx, id string, opDef core.OperatorDef, opDefYaml string, current bool) error {
+	if current {
+		// An operator without version only exists as long as it is the current operator
+		if _, err := tx.Exec(`DELETE FROM `+SQL_TABLE+` WHERE id = ? AND version = ''`, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE `+SQL_TABLE+` SET is_current = 0 WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	var n int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM `+SQL_TABLE+` WHERE id = ? AND version = ?`, id, opDef.Meta.Version).Scan(&n)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case n == 0:
+		_, err = tx.Exec(`INSERT INTO `+SQL_TABLE+` (id, version, name, def, is_current) VALUES (?, ?, ?, ?, ?)`,
+			id, opDef.Meta.Version, opDef.Meta.Name, opDefYaml, sqlBool(current))
+	case current:
+		_, err = tx.Exec(`UPDATE `+SQL_TABLE+` SET name = ?, def = ?, is_current = 1 WHERE id = ? AND version = ?`,
+			opDef.Meta.Name, opDefYaml, id, opDef.Meta.Version)
+	default:
+		_, err = tx.Exec(`UPDATE `+SQL_TABLE+` SET name = ?, def = ? WHERE id = ? AND version = ?`,
+			opDef.Meta.Name, opDefYaml, id, opDef.Meta.Version)
+	}
+	return err
+}
+
+func sqlBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
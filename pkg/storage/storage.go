@@ -17,6 +17,7 @@ type LoaderDumper interface {
 	List() ([]uuid.UUID, error)
 	Load(opId uuid.UUID) (*core.OperatorDef, error)
 	Dump(opDef core.OperatorDef) (uuid.UUID, error)
+	Remove(opId uuid.UUID) error
 	Has(opId uuid.UUID) bool
 }
 
@@ -41,6 +42,14 @@ func (s *Storage) AddLoader(loader Loader) *Storage {
 	return s
 }
 
+// WithLoader returns a new storage which loads operators from the loader in addition to the loaders of the storage.
+// The storage itself is left unchanged.
+func (s *Storage) WithLoader(loader Loader) *Storage {
+	loaders := make([]Loader, len(s.loader), len(s.loader)+1)
+	copy(loaders, s.loader)
+	return &Storage{append(loaders, loader), s.dumper}
+}
+
 func (s *Storage) IsDumpable(opId uuid.UUID) bool {
 	return s.dumper.Has(opId)
 }
@@ -69,6 +78,14 @@ func (s *Storage) Store(opDef core.OperatorDef) (uuid.UUID, error) {
 	return s.dumper.Dump(opDef)
 }
 
+// Remove removes a stored operator, operators of other loaders cannot be removed
+func (s *Storage) Remove(opId uuid.UUID) error {
+	if s.dumper == nil || !s.dumper.Has(opId) {
+		return fmt.Errorf("operator %s is not stored locally", opId)
+	}
+	return s.dumper.Remove(opId)
+}
+
 func (s *Storage) Load(opId uuid.UUID) (*core.OperatorDef, error) {
 	opDef, err := s.loadFirstFound(opId)
 	if err != nil {
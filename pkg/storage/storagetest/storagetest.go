@@ -0,0 +1,185 @@
+// Package storagetest provides a conformance test suite for implementations of storage.LoaderDumper.
+package storagetest
+
+import (
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// OperatorDef returns a valid operator with a new id
+func OperatorDef(name string) core.OperatorDef {
+	return core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: name, Tags: []string{"test"}},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {
+				In:  core.TypeDef{Type: "number"},
+				Out: core.TypeDef{Type: "number"},
+			},
+		},
+		Connections: map[string][]string{"(": {")"}},
+	}
+}
+
+func marshal(t *testing.T, opDef core.OperatorDef) string {
+	b, err := yaml.Marshal(&opDef)
+	require.NoError(t, err)
+	return string(b)
+}
+
+// TestLoaderDumper runs the conformance tests against empty loader dumpers created by newLD
+func TestLoaderDumper(t *testing.T, newLD func(t *testing.T) storage.LoaderDumper) {
+	t.Run("Empty", func(t *testing.T) {
+		ld := newLD(t)
+		ids, err := ld.List()
+		require.NoError(t, err)
+		assert.Len(t, ids, 0)
+
+		opId := uuid.New()
+		assert.False(t, ld.Has(opId))
+		_, err = ld.Load(opId)
+		assert.Error(t, err)
+	})
+
+	t.Run("DumpLoad", func(t *testing.T) {
+		ld := newLD(t)
+		opDef := OperatorDef("dumped")
+
+		opId, err := ld.Dump(opDef)
+		require.NoError(t, err)
+		assert.Equal(t, opDef.Id, opId.String())
+		assert.True(t, ld.Has(opId))
+
+		ids, err := ld.List()
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{opId}, ids)
+
+		loaded, err := ld.Load(opId)
+		require.NoError(t, err)
+		assert.Equal(t, marshal(t, opDef), marshal(t, *loaded))
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		ld := newLD(t)
+		opDef := OperatorDef("original")
+
+		opId, err := ld.Dump(opDef)
+		require.NoError(t, err)
+
+		opDef.Meta.Name = "changed"
+		opDef.ServiceDefs[core.MAIN_SERVICE].Out.Type = "string"
+		_, err = ld.Dump(opDef)
+		require.NoError(t, err)
+
+		ids, err := ld.List()
+		require.NoError(t, err)
+		assert.Len(t, ids, 1)
+
+		loaded, err := ld.Load(opId)
+		require.NoError(t, err)
+		assert.Equal(t, marshal(t, opDef), marshal(t, *loaded))
+	})
+
+	t.Run("Multiple", func(t *testing.T) {
+		ld := newLD(t)
+		expected := []uuid.UUID{}
+		for _, name := range []string{"a", "b", "c"} {
+			opId, err := ld.Dump(OperatorDef(name))
+			require.NoError(t, err)
+			expected = append(expected, opId)
+		}
+
+		ids, err := ld.List()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, expected, ids)
+		for _, opId := range expected {
+			assert.True(t, ld.Has(opId))
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		ld := newLD(t)
+		kept, err := ld.Dump(OperatorDef("kept"))
+		require.NoError(t, err)
+		removed, err := ld.Dump(OperatorDef("removed"))
+		require.NoError(t, err)
+
+		require.NoError(t, ld.Remove(removed))
+		assert.False(t, ld.Has(removed))
+		assert.True(t, ld.Has(kept))
+		_, err = ld.Load(removed)
+		assert.Error(t, err)
+
+		ids, err := ld.List()
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{kept}, ids)
+
+		assert.Error(t, ld.Remove(removed))
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		ld := newLD(t)
+		vl, ok := ld.(storage.VersionedLoader)
+		if !ok {
+			t.Skip("loader does not keep versions")
+		}
+
+		opDef := OperatorDef("versioned")
+		for _, v := range []string{"1.0.0", "2.0.0"} {
+			opDef.Meta.Version = v
+			_, err := ld.Dump(opDef)
+			require.NoError(t, err)
+		}
+		opId := uuid.MustParse(opDef.Id)
+
+		ids, err := ld.List()
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{opId}, ids)
+
+		versions, err := vl.Versions(opId)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"1.0.0", "2.0.0"}, versions)
+
+		loaded, err := ld.Load(opId)
+		require.NoError(t, err)
+		assert.Equal(t, "2.0.0", loaded.Meta.Version)
+		loaded, err = vl.LoadVersion(opId, "1.0.0")
+		require.NoError(t, err)
+		assert.Equal(t, "1.0.0", loaded.Meta.Version)
+		_, err = vl.LoadVersion(opId, "3.0.0")
+		assert.Error(t, err)
+
+		// Versions stored apart do not replace the current operator
+		if vd, ok := ld.(storage.VersionedDumper); ok {
+			opDef.Meta.Version = "1.5.0"
+			_, err := vd.DumpVersion(opDef)
+			require.NoError(t, err)
+
+			versions, err := vl.Versions(opId)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"1.0.0", "1.5.0", "2.0.0"}, versions)
+			loaded, err := ld.Load(opId)
+			require.NoError(t, err)
+			assert.Equal(t, "2.0.0", loaded.Meta.Version)
+		}
+
+		require.NoError(t, ld.Remove(opId))
+		assert.False(t, ld.Has(opId))
+		_, err = vl.LoadVersion(opId, "1.0.0")
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidId", func(t *testing.T) {
+		ld := newLD(t)
+		opDef := OperatorDef("invalid")
+		opDef.Id = "not-a-uuid"
+		_, err := ld.Dump(opDef)
+		assert.Error(t, err)
+	})
+}
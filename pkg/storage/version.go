@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Bitspark/go-version"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/google/uuid"
+)
+
+// VersionedLoader is a loader which keeps several versions of an operator side by side
+type VersionedLoader interface {
+	Loader
+	Versions(opId uuid.UUID) ([]string, error)
+	LoadVersion(opId uuid.UUID, version string) (*core.OperatorDef, error)
+}
+
+// VersionedDumper is a dumper which stores versions of an operator without replacing the current operator
+type VersionedDumper interface {
+	DumpVersion(opDef core.OperatorDef) (uuid.UUID, error)
+}
+
+// StoreVersion stores a version of the operator, the current operator is left unchanged
+func (s *Storage) StoreVersion(opDef core.OperatorDef) (uuid.UUID, error) {
+	vd, ok := s.dumper.(VersionedDumper)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("storage cannot store versions of operators")
+	}
+	if opDef.Meta.Version == "" {
+		return uuid.Nil, fmt.Errorf("operator %s has no version", opDef.Id)
+	}
+	return vd.DumpVersion(opDef)
+}
+
+// ParseConstraint parses version constraints. Besides the constraints understood by go-version such as ">= 1.2, < 2"
+// or "~> 1.2" it supports npm style caret (^1.2.3) and tilde (~1.2.3) ranges. A plain version must match exactly.
+func ParseConstraint(constraint string) (version.Constraints, error) {
+	constraint = strings.TrimSpace(constraint)
+
+	if strings.HasPrefix(constraint, "^") || (strings.HasPrefix(constraint, "~") && !strings.HasPrefix(constraint, "~>")) {
+		v, err := version.NewVersion(constraint[1:])
+		if err != nil {
+			return nil, err
+		}
+		segs := v.Segments()
+		var upper string
+		switch {
+		case constraint[0] == '~':
+			upper = fmt.Sprintf("%d.%d.0", segs[0], segs[1]+1)
+		case segs[0] != 0:
+			upper = fmt.Sprintf("%d.0.0", segs[0]+1)
+		case segs[1] != 0:
+			upper = fmt.Sprintf("0.%d.0", segs[1]+1)
+		default:
+			upper = fmt.Sprintf("0.0.%d", segs[2]+1)
+		}
+		return version.NewConstraint(fmt.Sprintf(">= %s, < %s", v.String(), upper))
+	}
+
+	if _, err := version.NewVersion(constraint); err == nil {
+		return version.NewConstraint("= " + constraint)
+	}
+
+	return version.NewConstraint(constraint)
+}
+
+// Versions returns all versions of the operator known to any of the loaders
+func (s *Storage) Versions(opId uuid.UUID) []string {
+	found := make(map[string]bool)
+	versions := []string{}
+
+	for _, loader := range s.loader {
+		if !loader.Has(opId) {
+			continue
+		}
+
+		var vs []string
+		if vl, ok := loader.(VersionedLoader); ok {
+			vs, _ = vl.Versions(opId)
+		} else if opDef, err := loader.Load(opId); err == nil && opDef.Meta.Version != "" {
+			vs = []string{opDef.Meta.Version}
+		}
+
+		for _, v := range vs {
+			if !found[v] {
+				found[v] = true
+				versions = append(versions, v)
+			}
+		}
+	}
+
+	return versions
+}
+
+// ResolveVersion returns the highest version of the operator satisfying the constraint
+func (s *Storage) ResolveVersion(opId uuid.UUID, constraint string) (string, error) {
+	cs, err := ParseConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint %s: %s", constraint, err)
+	}
+
+	var best *version.Version
+	bestStr := ""
+	for _, vStr := range s.Versions(opId) {
+		v, err := version.NewVersion(vStr)
+		if err != nil || !cs.Check(v) {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best = v
+			bestStr = vStr
+		}
+	}
+
+	if best == nil {
+		return "", fmt.Errorf("no version of operator %s satisfies %s", opId, constraint)
+	}
+	return bestStr, nil
+}
+
+// LoadVersion loads the given version of the operator
+func (s *Storage) LoadVersion(opId uuid.UUID, v string) (*core.OperatorDef, error) {
+	for _, loader := range s.loader {
+		if !loader.Has(opId) {
+			continue
+		}
+
+		var opDef *core.OperatorDef
+		var err error
+		if vl, ok := loader.(VersionedLoader); ok {
+			opDef, err = vl.LoadVersion(opId, v)
+		} else {
+			opDef, err = loader.Load(opId)
+		}
+
+		if err == nil && opDef.Meta.Version == v {
+			cpyOpDef := opDef.Copy(true)
+			return &cpyOpDef, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown version %s of operator %s", v, opId)
+}
+
+// LoadRef loads the operator an instance refers to. References are either plain ids or of the form id@constraint.
+func (s *Storage) LoadRef(ref string) (*core.OperatorDef, error) {
+	idStr, constraint := core.SplitOperatorRef(ref)
+	opId, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid operator reference %s: %s", ref, err)
+	}
+
+	if constraint == "" {
+		return s.Load(opId)
+	}
+
+	v, err := s.ResolveVersion(opId, constraint)
+	if err != nil {
+		return nil, err
+	}
+	return s.LoadVersion(opId, v)
+}
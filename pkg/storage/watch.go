@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+)
+
+const (
+	CHANGE_STORED  = "stored"
+	CHANGE_REMOVED = "removed"
+	CHANGE_RESYNC  = "resync"
+)
+
+// OperatorChange informs about an operator which has been stored or removed. Changes of kind CHANGE_RESYNC carry no
+// operator, they tell that changes have been missed, so that any operator may have changed.
+type OperatorChange struct {
+	Id   uuid.UUID `json:"id"`
+	Path string    `json:"path"`
+	Kind string    `json:"kind"`
+}
+
+// Notifier is implemented by loaders which inform about changes of their operators. Changes are dropped in case the
+// channel of a subscriber is full, the subscriber receives CHANGE_RESYNC instead as soon as there is room again.
+type Notifier interface {
+	Subscribe(ch chan<- OperatorChange)
+	Unsubscribe(ch chan<- OperatorChange)
+}
+
+// Subscribe subscribes to the changes of all loaders which inform about changes
+func (s *Storage) Subscribe(ch chan<- OperatorChange) {
+	for _, loader := range s.loader {
+		if n, ok := loader.(Notifier); ok {
+			n.Subscribe(ch)
+		}
+	}
+}
+
+func (s *Storage) Unsubscribe(ch chan<- OperatorChange) {
+	for _, loader := range s.loader {
+		if n, ok := loader.(Notifier); ok {
+			n.Unsubscribe(ch)
+		}
+	}
+}
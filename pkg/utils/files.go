@@ -39,3 +39,8 @@ func IsJSON(opDefFilePath string) bool {
 func IsYAML(opDefFilePath string) bool {
 	return strings.HasSuffix(opDefFilePath, ".yaml") || strings.HasSuffix(opDefFilePath, ".yml")
 }
+
+func FileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
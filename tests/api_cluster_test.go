@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/api"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_PartitionOperator(t *testing.T) {
+	a := assertions.New(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	st := storage.NewStorage(storage.NewFileSystem(dir))
+
+	evaluate := elem.GetId("evaluate").String()
+	opDef := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "calculation"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {
+				In:  core.TypeDef{Type: "map", Map: map[string]*core.TypeDef{"x": {Type: "number"}, "y": {Type: "number"}}},
+				Out: core.TypeDef{Type: "number"},
+			},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "mul", Operator: evaluate, Properties: core.Properties{"expression": "a*b", "variables": []interface{}{"a", "b"}}},
+			{Name: "inc", Operator: evaluate, Properties: core.Properties{"expression": "a+1", "variables": []interface{}{"a"}}},
+		},
+		Connections: map[string][]string{
+			"x(":   {"a(mul"},
+			"y(":   {"b(mul"},
+			"mul)": {"a(inc"},
+			"inc)": {")"},
+		},
+	}
+	opId, err := st.Store(opDef)
+	require.NoError(t, err)
+
+	_, err = api.PartitionOperator(opId, nil, nil, *st, 2, map[string]int{"mul": 2})
+	a.Error(err)
+
+	partition, err := api.PartitionOperator(opId, nil, nil, *st, 2, map[string]int{"mul": 0, "inc": 1})
+	require.NoError(t, err)
+	require.Len(t, partition.Parts, 2)
+	a.Len(partition.Bridges, 4)
+	for _, bridge := range partition.Bridges {
+		a.Len(bridge.To, 1)
+		a.NotEqual(bridge.From.Part, bridge.To[0].Part)
+	}
+
+	// All instances in one part only leave the main ports to be bridged
+	single, err := api.PartitionOperator(opId, nil, nil, *st, 1, nil)
+	require.NoError(t, err)
+	a.Len(single.Parts, 1)
+	a.Len(single.Bridges, 3)
+
+	// Run the parts in-process, forwarding items along the bridges
+	main, err := api.CreateAndConnectOperator("", partition.Main, false)
+	require.NoError(t, err)
+	main.Main().Out().Bufferize()
+
+	parts := make([]*core.Operator, len(partition.Parts))
+	for i, part := range partition.Parts {
+		partSt := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(part.Blueprints...))
+		parts[i], err = api.BuildAndCompile(uuid.MustParse(part.Main), nil, nil, *partSt)
+		require.NoError(t, err)
+		parts[i].Main().Out().Bufferize()
+	}
+	port := func(e api.Endpoint) *core.Port {
+		o := main
+		if e.Part != api.COORDINATOR {
+			o = parts[e.Part]
+		}
+		p, err := core.ParsePortReference(e.Ref, o)
+		require.NoError(t, err)
+		return p
+	}
+	for _, bridge := range partition.Bridges {
+		from := port(bridge.From)
+		if bridge.From.Part == api.COORDINATOR {
+			from.Bufferize()
+		}
+		to := port(bridge.To[0])
+		go func() {
+			for {
+				to.Push(api.BindMarker(to, from.Pull()))
+			}
+		}()
+	}
+	for _, o := range parts {
+		o.Start(context.Background())
+	}
+
+	main.Main().In().Push(map[string]interface{}{"x": 3.0, "y": 4.0})
+	main.Main().In().Push(map[string]interface{}{"x": 2.0, "y": 0.5})
+	a.PortPushesAll([]interface{}{13.0, 2.0}, main.Main().Out())
+}
+
+func TestAPI_PartitionOperator__External(t *testing.T) {
+	a := assertions.New(t)
+
+	extDef := externalOperatorDef("scale")
+	opDef := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "scaleAndInc"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {In: core.TypeDef{Type: "number"}, Out: core.TypeDef{Type: "number"}},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "ext", Operator: extDef.Id, Properties: core.Properties{"factor": 3}},
+			{Name: "inc", Operator: elem.GetId("evaluate").String(), Properties: core.Properties{"expression": "a+1", "variables": []interface{}{"a"}}},
+		},
+		Connections: map[string][]string{
+			"(":    {"(ext"},
+			"ext)": {"a(inc"},
+			"inc)": {")"},
+		},
+	}
+	st := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(extDef, opDef))
+
+	partition, err := api.PartitionOperator(uuid.MustParse(opDef.Id), nil, nil, *st, 2, map[string]int{"ext": 0, "inc": 1})
+	require.NoError(t, err)
+	require.Len(t, partition.Parts, 2)
+
+	// The part is sent to the worker as JSON and has to be buildable from its blueprints alone
+	b, err := json.Marshal(partition.Parts[0])
+	require.NoError(t, err)
+	var part core.SlangFileDef
+	require.NoError(t, json.Unmarshal(b, &part))
+	a.Len(part.Blueprints, 2)
+
+	partSt := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(part.Blueprints...))
+	op, err := api.BuildAndCompile(uuid.MustParse(part.Main), nil, nil, *partSt)
+	require.NoError(t, err)
+	a.NotNil(op)
+}
+
+func TestAPI_Cluster__StopEndsBridges(t *testing.T) {
+	a := assertions.New(t)
+
+	number := core.TypeDef{Type: "number"}
+	partition := &api.Partition{
+		Main: core.OperatorDef{
+			Id:          uuid.New().String(),
+			Meta:        core.OperatorMetaDef{Name: "coordinator only"},
+			ServiceDefs: map[string]*core.ServiceDef{core.MAIN_SERVICE: {In: number, Out: number}},
+			Connections: map[string][]string{},
+		},
+		Bridges: []*api.Bridge{
+			{From: api.Endpoint{Part: api.COORDINATOR, Ref: "("}, To: []api.Endpoint{{Part: api.COORDINATOR, Ref: ")"}}},
+		},
+	}
+
+	before := runtime.NumGoroutine()
+
+	cluster, err := api.NewCluster(partition)
+	require.NoError(t, err)
+	cluster.Addr = "localhost:0"
+	require.NoError(t, cluster.Start(nil))
+
+	cluster.Main().In().Push(1.0)
+	a.Equal(1.0, cluster.Main().Out().Pull())
+	require.NoError(t, cluster.Drain(time.Second))
+	require.NoError(t, cluster.Stop())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.True(runtime.NumGoroutine() <= before)
+}
@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/api"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func squareSlangFile() *core.SlangFileDef {
+	opDef := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "square"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {In: core.TypeDef{Type: "number"}, Out: core.TypeDef{Type: "number"}},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "sq", Operator: elem.GetId("evaluate").String(), Properties: core.Properties{"expression": "a*a", "variables": []interface{}{"a"}}},
+		},
+		Connections: map[string][]string{
+			"(":   {"a(sq"},
+			"sq)": {")"},
+		},
+	}
+	return &core.SlangFileDef{Main: opDef.Id, Blueprints: []core.OperatorDef{opDef}}
+}
+
+func TestAPI_Executable_Stdio(t *testing.T) {
+	a := assertions.New(t)
+
+	exe, err := api.NewExecutable(squareSlangFile())
+	require.NoError(t, err)
+	exe.Start()
+	defer exe.Stop()
+
+	out := new(bytes.Buffer)
+	require.NoError(t, exe.ServeStdio(strings.NewReader("2\n3\n"), out))
+	a.Equal("4\n9\n", out.String())
+}
+
+func TestAPI_Executable_StdioDrain(t *testing.T) {
+	a := assertions.New(t)
+
+	opDef := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "slow"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {
+				In: core.TypeDef{Type: "map", Map: map[string]*core.TypeDef{
+					"item":  {Type: "number"},
+					"delay": {Type: "number"},
+				}},
+				Out: core.TypeDef{Type: "number"},
+			},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "delay", Operator: elem.GetId("delay").String(), Generics: map[string]*core.TypeDef{"itemType": {Type: "number"}}},
+		},
+		Connections: map[string][]string{
+			"(":      {"(delay"},
+			"delay)": {")"},
+		},
+	}
+	exe, err := api.NewExecutable(&core.SlangFileDef{Main: opDef.Id, Blueprints: []core.OperatorDef{opDef}})
+	require.NoError(t, err)
+	exe.Start()
+	defer exe.Stop()
+
+	// The delay operator holds each item without buffering it
+	out := new(bytes.Buffer)
+	require.NoError(t, exe.ServeStdio(strings.NewReader("{\"item\":1,\"delay\":300}\n{\"item\":2,\"delay\":300}\n"), out))
+	a.Equal("1\n2\n", out.String())
+
+	exe.DrainTimeout = 100 * time.Millisecond
+	a.Error(exe.ServeStdio(strings.NewReader("{\"item\":3,\"delay\":500}\n"), new(bytes.Buffer)))
+}
+
+func TestAPI_Executable_HTTP(t *testing.T) {
+	a := assertions.New(t)
+
+	exe, err := api.NewExecutable(squareSlangFile())
+	require.NoError(t, err)
+	exe.Start()
+	defer exe.Stop()
+
+	server := httptest.NewServer(exe)
+	defer server.Close()
+
+	for _, c := range []struct{ in, out string }{{"3", "9"}, {"1.5", "2.25"}} {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(c.in))
+		require.NoError(t, err)
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		a.Equal(c.out, string(b))
+	}
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader("{invalid"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	a.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func delaySlangFile() *core.SlangFileDef {
+	opDef := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "delayed"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {In: core.TypeDef{Type: "number"}, Out: core.TypeDef{Type: "number"}},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "dl", Operator: elem.GetId("delay").String(), Generics: core.Generics{"itemType": {Type: "number"}}},
+		},
+		Connections: map[string][]string{
+			"(":   {"item(dl", "delay(dl"},
+			"dl)": {")"},
+		},
+	}
+	return &core.SlangFileDef{Main: opDef.Id, Blueprints: []core.OperatorDef{opDef}}
+}
+
+func TestAPI_Executable_HTTPConcurrent(t *testing.T) {
+	a := assertions.New(t)
+
+	exe, err := api.NewExecutable(squareSlangFile())
+	require.NoError(t, err)
+	exe.Start()
+	defer exe.Stop()
+
+	server := httptest.NewServer(exe)
+	defer server.Close()
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Post(server.URL, "application/json", strings.NewReader(strconv.Itoa(i)))
+			require.NoError(t, err)
+			b, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			a.Equal(strconv.Itoa(i*i), string(b))
+		}(i)
+	}
+	wg.Wait()
+
+	exe.MaxRequestSize = 4
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader("123456"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	a.Equal(http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestAPI_Executable_HTTPTimeout(t *testing.T) {
+	a := assertions.New(t)
+
+	exe, err := api.NewExecutable(delaySlangFile())
+	require.NoError(t, err)
+	exe.RequestTimeout = 50 * time.Millisecond
+	exe.Start()
+	defer exe.Stop()
+
+	server := httptest.NewServer(exe)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader("200"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	a.Equal(http.StatusGatewayTimeout, resp.StatusCode)
+
+	// The late reply is dropped rather than passed to the next request
+	time.Sleep(300 * time.Millisecond)
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader("0"))
+	require.NoError(t, err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	a.Equal(http.StatusOK, resp.StatusCode)
+	a.Equal("0", string(b))
+}
+
+func TestAPI_Executable_Socket(t *testing.T) {
+	a := assertions.New(t)
+
+	exe, err := api.NewExecutable(squareSlangFile())
+	require.NoError(t, err)
+	exe.Start()
+	defer exe.Stop()
+
+	inLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	outLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer inLn.Close()
+	defer outLn.Close()
+	go exe.ServeSocket(inLn, outLn)
+
+	dial := func(ln net.Listener) *api.WireConn {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		wc := api.NewWireConn(conn)
+		id, err := wc.Handshake(api.ENCODING_CBOR)
+		require.NoError(t, err)
+		a.Equal(exe.Operator().Id().String(), id)
+		return wc
+	}
+	in, out := dial(inLn), dial(outLn)
+
+	require.NoError(t, in.WriteValue(4.0))
+	v, err := out.ReadValue()
+	require.NoError(t, err)
+	a.Equal(16.0, v)
+}
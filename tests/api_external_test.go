@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/api"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const externalModeEnv = "SLANG_TEST_EXTERNAL"
+
+// TestAPI_External_Helper is not a real test but the process of the external operators below. In mode "scale" it
+// multiplies items by the factor property, in mode "calc" it calls the delegate calc first.
+func TestAPI_External_Helper(t *testing.T) {
+	mode := os.Getenv(externalModeEnv)
+	if mode == "" {
+		return
+	}
+
+	type message struct {
+		Type       string                 `json:"type"`
+		Service    string                 `json:"service,omitempty"`
+		Delegate   string                 `json:"delegate,omitempty"`
+		Value      interface{}            `json:"value,omitempty"`
+		Properties map[string]interface{} `json:"properties,omitempty"`
+	}
+
+	factor := 1.0
+	enc := json.NewEncoder(os.Stdout)
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		var msg message
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			enc.Encode(map[string]string{"type": "error", "error": err.Error()})
+			continue
+		}
+		switch {
+		case msg.Type == "init":
+			factor = msg.Properties["factor"].(float64)
+		case msg.Service == core.MAIN_SERVICE && mode == "calc":
+			enc.Encode(message{Type: "item", Delegate: "calc", Value: map[string]interface{}{"a": msg.Value}})
+		case msg.Service == core.MAIN_SERVICE || msg.Delegate == "calc":
+			enc.Encode(message{Type: "item", Service: core.MAIN_SERVICE, Value: msg.Value.(float64) * factor})
+		}
+	}
+	os.Exit(0)
+}
+
+func externalOperatorDef(mode string) core.OperatorDef {
+	opDef := core.OperatorDef{
+		Id:             uuid.New().String(),
+		Meta:           core.OperatorMetaDef{Name: "external " + mode},
+		ElementaryKind: core.ELEMENTARY_EXTERNAL,
+		External: &core.ExternalDef{
+			Command: []string{os.Args[0], "-test.run=^TestAPI_External_Helper$"},
+			Env:     map[string]string{externalModeEnv: mode},
+		},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {
+				In:  core.TypeDef{Type: "number"},
+				Out: core.TypeDef{Type: "number"},
+			},
+		},
+		PropertyDefs: core.TypeDefMap{
+			"factor": {Type: "number"},
+		},
+	}
+	if mode == "calc" {
+		opDef.DelegateDefs = map[string]*core.DelegateDef{
+			"calc": {
+				In:  core.TypeDef{Type: "number"},
+				Out: core.TypeDef{Type: "map", Map: map[string]*core.TypeDef{"a": {Type: "number"}}},
+			},
+		}
+	}
+	return opDef
+}
+
+func TestAPI_External_Run(t *testing.T) {
+	a := assertions.New(t)
+	ctx := context.Background()
+
+	opDef := externalOperatorDef("scale")
+	require.NoError(t, opDef.Validate())
+	st := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(opDef))
+
+	rt, err := api.LoadRuntime(uuid.MustParse(opDef.Id), nil, core.Properties{"factor": 3}, *st)
+	require.NoError(t, err)
+	require.NoError(t, rt.Start(ctx))
+	defer rt.Close()
+
+	for _, i := range []float64{1, 2, 5} {
+		v, err := rt.Call(ctx, i)
+		require.NoError(t, err)
+		a.Equal(3*i, v)
+	}
+}
+
+func TestAPI_External_Stream(t *testing.T) {
+	a := assertions.New(t)
+	ctx := context.Background()
+
+	extDef := externalOperatorDef("scale")
+	numbers := core.TypeDef{Type: "stream", Stream: &core.TypeDef{Type: "number"}}
+	opDef := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "scaleAll"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {In: numbers, Out: numbers},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "ext", Operator: extDef.Id, Properties: core.Properties{"factor": 3}},
+		},
+		Connections: map[string][]string{
+			"~(":   {"(ext"},
+			"ext)": {")~"},
+		},
+	}
+	st := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(extDef, opDef))
+
+	rt, err := api.LoadRuntime(uuid.MustParse(opDef.Id), nil, nil, *st)
+	require.NoError(t, err)
+	require.NoError(t, rt.Start(ctx))
+	defer rt.Close()
+
+	v, err := rt.Call(ctx, []interface{}{1, 2, 5})
+	require.NoError(t, err)
+	a.Equal([]interface{}{3.0, 6.0, 15.0}, v)
+
+	v, err = rt.Call(ctx, []interface{}{})
+	require.NoError(t, err)
+	a.Equal([]interface{}{}, v)
+
+	v, err = rt.Call(ctx, []interface{}{4})
+	require.NoError(t, err)
+	a.Equal([]interface{}{12.0}, v)
+}
+
+func TestAPI_External_Delegate(t *testing.T) {
+	a := assertions.New(t)
+	ctx := context.Background()
+
+	extDef := externalOperatorDef("calc")
+	opDef := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "incAndScale"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {
+				In:  core.TypeDef{Type: "number"},
+				Out: core.TypeDef{Type: "number"},
+			},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "ext", Operator: extDef.Id, Properties: core.Properties{"factor": 2}},
+			{Name: "inc", Operator: elem.GetId("evaluate").String(), Properties: core.Properties{"expression": "a+1", "variables": []interface{}{"a"}}},
+		},
+		Connections: map[string][]string{
+			"(":         {"(ext"},
+			"ext)":      {")"},
+			"ext.calc)": {"(inc"},
+			"inc)":      {"(ext.calc"},
+		},
+	}
+	st := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(extDef, opDef))
+
+	rt, err := api.LoadRuntime(uuid.MustParse(opDef.Id), nil, nil, *st)
+	require.NoError(t, err)
+	require.NoError(t, rt.Start(ctx))
+	defer rt.Close()
+
+	v, err := rt.Call(ctx, 4)
+	require.NoError(t, err)
+	a.Equal(10.0, v)
+}
+
+func TestAPI_External_Policy(t *testing.T) {
+	a := assertions.New(t)
+
+	opDef := externalOperatorDef("scale")
+	st := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(opDef))
+
+	defer elem.SetPolicy(nil)
+	load := func(p *elem.Policy) error {
+		elem.SetPolicy(p)
+		_, err := api.LoadRuntime(uuid.MustParse(opDef.Id), nil, core.Properties{"factor": 3}, *st)
+		return err
+	}
+
+	a.Error(load(&elem.Policy{}))
+	a.Error(load(&elem.Policy{Process: true}))
+	a.Error(load(&elem.Policy{Process: true, Commands: []string{"/usr/bin"}}))
+	a.NoError(load(&elem.Policy{Process: true, Commands: []string{os.Args[0]}}))
+}
+
+func TestAPI_External_MissingCommand(t *testing.T) {
+	a := assertions.New(t)
+
+	opDef := externalOperatorDef("scale")
+	opDef.External.Command = []string{"slang-external-does-not-exist"}
+	st := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(opDef))
+
+	_, err := api.LoadRuntime(uuid.MustParse(opDef.Id), nil, core.Properties{"factor": 3}, *st)
+	a.Error(err)
+}
+
+func TestOperatorDef_Validate__External(t *testing.T) {
+	a := assertions.New(t)
+
+	opDef := externalOperatorDef("scale")
+	a.NoError(opDef.Validate())
+
+	opDef = externalOperatorDef("scale")
+	opDef.External.Command = nil
+	a.Error(opDef.Validate())
+
+	opDef = externalOperatorDef("scale")
+	opDef.ElementaryKind = ""
+	a.Error(opDef.Validate())
+
+	opDef = externalOperatorDef("scale")
+	opDef.InstanceDefs = core.InstanceDefList{{Name: "inc", Operator: elem.GetId("evaluate").String()}}
+	a.Error(opDef.Validate())
+}
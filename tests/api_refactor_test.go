@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/api"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_Extract(t *testing.T) {
+	a := assertions.New(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	st := storage.NewStorage(storage.NewFileSystem(dir))
+
+	evaluate := elem.GetId("evaluate").String()
+	opDef := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "calculation"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {
+				In:  core.TypeDef{Type: "map", Map: map[string]*core.TypeDef{"x": {Type: "number"}, "y": {Type: "number"}}},
+				Out: core.TypeDef{Type: "number"},
+			},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "mul", Operator: evaluate, Properties: core.Properties{"expression": "a*b", "variables": []interface{}{"a", "b"}}},
+			{Name: "inc", Operator: evaluate, Properties: core.Properties{"expression": "a+1", "variables": []interface{}{"a"}}},
+		},
+		Connections: map[string][]string{
+			"x(":   {"a(mul"},
+			"y(":   {"b(mul"},
+			"mul)": {"a(inc"},
+			"inc)": {")"},
+		},
+	}
+	opId, err := st.Store(opDef)
+	require.NoError(t, err)
+
+	_, err = api.Extract(opId, []string{"unknown"}, "Multiply", "", *st)
+	a.Error(err)
+
+	extraction, err := api.Extract(opId, []string{"mul", "inc"}, "Multiply and increment", "", *st)
+	require.NoError(t, err)
+	a.Equal("multiply_and_increment", extraction.Instance)
+
+	extracted := extraction.Extracted
+	a.Len(extracted.InstanceDefs, 2)
+	a.Equal("map", extracted.ServiceDefs[core.MAIN_SERVICE].In.Type)
+	a.Len(extracted.ServiceDefs[core.MAIN_SERVICE].In.Map, 2)
+	a.Equal("primitive", extracted.ServiceDefs[core.MAIN_SERVICE].Out.Type)
+	a.Equal([]string{"a(inc"}, extracted.Connections["mul)"])
+
+	loaded, err := st.Load(opId)
+	require.NoError(t, err)
+	a.Len(loaded.InstanceDefs, 1)
+	a.Equal(extracted.Id, loaded.InstanceDefs[0].Operator)
+	a.Equal([]string{")"}, loaded.Connections["multiply_and_increment)"])
+
+	// The operator still computes the same
+	o, err := api.BuildAndCompile(opId, nil, nil, *st)
+	require.NoError(t, err)
+	o.Main().Out().Bufferize()
+	o.Main().In().Push(map[string]interface{}{"x": 3.0, "y": 4.0})
+	o.Start(context.Background())
+	a.PortPushesAll([]interface{}{13.0}, o.Main().Out())
+}
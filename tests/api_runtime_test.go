@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/api"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func squareRuntime(t *testing.T) *api.Runtime {
+	opDef := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "square"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {
+				In:  core.TypeDef{Type: "map", Map: map[string]*core.TypeDef{"a": {Type: "number"}}},
+				Out: core.TypeDef{Type: "number"},
+			},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "sq", Operator: elem.GetId("evaluate").String(), Properties: core.Properties{"expression": "a*a", "variables": []interface{}{"a"}}},
+		},
+		Connections: map[string][]string{
+			"a(":  {"a(sq"},
+			"sq)": {")"},
+		},
+	}
+	st := storage.NewStorage(nil).AddLoader(storage.NewMemoryLoader(opDef))
+
+	rt, err := api.LoadRuntime(uuid.MustParse(opDef.Id), nil, nil, *st)
+	require.NoError(t, err)
+	return rt
+}
+
+func TestAPI_Runtime_SendReceive(t *testing.T) {
+	a := assertions.New(t)
+	ctx := context.Background()
+
+	rt := squareRuntime(t)
+	_, err := rt.Receive(ctx)
+	a.Equal(api.ErrNotStarted, err)
+
+	require.NoError(t, rt.Start(ctx))
+	defer rt.Close()
+
+	type input struct {
+		A int `json:"a"`
+	}
+	require.NoError(t, rt.SendAll(ctx, []interface{}{input{2}, map[string]interface{}{"a": 3}}))
+
+	v, err := rt.Receive(ctx)
+	require.NoError(t, err)
+	a.Equal(4.0, v)
+
+	var n int
+	require.NoError(t, rt.ReceiveInto(ctx, &n))
+	a.Equal(9, n)
+
+	v, err = rt.Call(ctx, input{5})
+	require.NoError(t, err)
+	a.Equal(25.0, v)
+
+	a.Error(rt.Send(ctx, "no map"))
+}
+
+func TestAPI_Runtime_Cancel(t *testing.T) {
+	a := assertions.New(t)
+
+	rt := squareRuntime(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, rt.Start(ctx))
+
+	timeout, cancelTimeout := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelTimeout()
+	_, err := rt.Receive(timeout)
+	a.Equal(context.DeadlineExceeded, err)
+
+	received := make(chan error, 1)
+	go func() {
+		received <- rt.Each(context.Background(), func(v interface{}) error { return nil })
+	}()
+
+	cancel()
+	select {
+	case err := <-received:
+		a.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("runtime not closed")
+	}
+
+	a.Equal(api.ErrClosed, rt.Send(context.Background(), map[string]interface{}{"a": 1}))
+	a.NoError(rt.Close())
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/api"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_CreateSlangFile(t *testing.T) {
+	a := assertions.New(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	st := storage.NewStorage(storage.NewFileSystem(dir))
+
+	number := core.TypeDef{Type: "number"}
+	inner := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "inner"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {In: number, Out: number},
+		},
+		DelegateDefs: map[string]*core.DelegateDef{
+			"used":   {In: number, Out: number},
+			"unused": {In: number, Out: number},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "inc", Operator: elem.GetId("evaluate").String(), Properties: core.Properties{"expression": "a+1", "variables": []interface{}{"a"}}},
+		},
+		Connections: map[string][]string{
+			"(":    {"a(inc"},
+			"inc)": {")"},
+		},
+	}
+	innerId, err := st.Store(inner)
+	require.NoError(t, err)
+
+	outer := core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: "outer"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			core.MAIN_SERVICE: {In: number, Out: number},
+		},
+		InstanceDefs: core.InstanceDefList{
+			{Name: "inner", Operator: innerId.String()},
+		},
+		Connections: map[string][]string{
+			"(":           {"(inner"},
+			"inner)":      {")"},
+			"inner.used)": {"(inner.used"},
+		},
+		TestCases: []core.TestCaseDef{{Name: "Increment"}},
+	}
+	outerId, err := st.Store(outer)
+	require.NoError(t, err)
+
+	slFile, err := api.CreateSlangFile(outerId, api.SlangFileOptions{Properties: core.Properties{"x": 1.0}}, *st)
+	require.NoError(t, err)
+	a.Equal(outerId.String(), slFile.Main)
+	a.Equal(core.Properties{"x": 1.0}, slFile.Args.Properties)
+	require.Len(t, slFile.Blueprints, 2)
+	a.Equal(outerId.String(), slFile.Blueprints[0].Id)
+	a.Equal(innerId.String(), slFile.Blueprints[1].Id)
+	a.Len(slFile.Blueprints[1].DelegateDefs, 2)
+	a.Len(slFile.Blueprints[0].TestCases, 1)
+
+	shaken, err := api.CreateSlangFile(outerId, api.SlangFileOptions{ShakeDelegates: true, ShakeTests: true}, *st)
+	require.NoError(t, err)
+	a.Contains(shaken.Blueprints[1].DelegateDefs, "used")
+	a.NotContains(shaken.Blueprints[1].DelegateDefs, "unused")
+	a.Empty(shaken.Blueprints[0].TestCases)
+
+	_, err = api.CreateSlangFile(uuid.New(), api.SlangFileOptions{}, *st)
+	a.Error(err)
+}
@@ -99,6 +99,39 @@ func TestOperatorDef_Validate__Succeeds(t *testing.T) {
 	a.True(oDef.Valid())
 }
 
+func TestOperatorDef_Validate__FailsPropertyDefaultInvalid(t *testing.T) {
+	a := assertions.New(t)
+
+	_, err := validateJSONOperatorDef(`{
+		"id": "d1e020d6-4414-42e0-a5c5-dd6fda91754e",
+		"meta":{"name": "opName"},
+		"services": {"main": {"in": {"type": "number"}, "out": {"type": "number"}}},
+		"properties": {"timeout": {"type": "number"}},
+		"propertyDefaults": {"timeout": "never"}
+	}`)
+	a.Error(err)
+
+	_, err = validateJSONOperatorDef(`{
+		"id": "d1e020d6-4414-42e0-a5c5-dd6fda91754e",
+		"meta":{"name": "opName"},
+		"services": {"main": {"in": {"type": "number"}, "out": {"type": "number"}}},
+		"propertyDefaults": {"timeout": 0}
+	}`)
+	a.Error(err)
+}
+
+func TestOperatorDef_ApplyPropertyDefaults(t *testing.T) {
+	a := assertions.New(t)
+	oDef := core.OperatorDef{
+		PropertyDefs:     core.TypeDefMap{"a": {Type: "number"}, "b": {Type: "string"}},
+		PropertyDefaults: core.Properties{"b": "default"},
+	}
+
+	a.Equal(core.Properties{"b": "default"}, oDef.ApplyPropertyDefaults(nil))
+	a.Equal(core.Properties{"a": 1, "b": "set"}, oDef.ApplyPropertyDefaults(core.Properties{"a": 1, "b": "set"}))
+	a.Equal(core.Properties{"a": 1, "b": "default"}, oDef.ApplyPropertyDefaults(core.Properties{"a": 1}))
+}
+
 func TestOperatorDef_SpecifyGenericPorts__NilGenerics(t *testing.T) {
 	a := assertions.New(t)
 	op, _ := core.ParseJSONOperatorDef(`{"id": "d1e020d6-4414-42e0-a5c5-dd6fda91754e","meta":{"name": "opName"},"services": {"` + core.MAIN_SERVICE + `": {"in": {"type": "number"}, "out": {"type": "number"}}}}`)
@@ -413,3 +446,16 @@ func TestExpandExpression__ArrayCross2(t *testing.T) {
 	r.NoError(err)
 	a.Equal([]string{"a_a", "b_a", "c_a", "a_b", "b_b", "c_b", "a_c", "b_c", "c_c"}, parts)
 }
+
+func TestExpandExpression__ArrayMapEntry(t *testing.T) {
+	a := assertions.New(t)
+	r := require.New(t)
+	propDefs, props := makeProps()
+	props["arrmap1"] = []interface{}{
+		map[string]interface{}{"a": "x", "b": true},
+		map[string]interface{}{"a": "y", "b": false},
+	}
+	parts, err := core.ExpandExpression("{arrmap1.a}_{arrmap1.b}", props, propDefs)
+	r.NoError(err)
+	a.Equal([]string{"x_true", "y_true", "x_false", "y_false"}, parts)
+}
@@ -1,7 +1,9 @@
 package tests
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/Bitspark/slang/pkg/core"
 	"github.com/Bitspark/slang/tests/assertions"
@@ -122,3 +124,38 @@ func TestOperator_Compile__NestedChildren(t *testing.T) {
 	a.False(op3.Main().In().Connected(op6.Main().In()))
 	a.False(op6.Main().Out().Connected(op3.Main().Out()))
 }
+
+func TestOperator_Start__CancelEndsBuiltins(t *testing.T) {
+	a := assertions.New(t)
+	number := core.TypeDef{Type: "number"}
+
+	ended := make(chan bool)
+	op, _ := core.NewOperator("", func(op *core.Operator) {
+		defer close(ended)
+		for {
+			i, ok := op.Main().In().PullOK()
+			if !ok {
+				return
+			}
+			op.Main().Out().Push(i)
+		}
+	}, nil, nil, nil, core.OperatorDef{ServiceDefs: map[string]*core.ServiceDef{core.MAIN_SERVICE: {In: number, Out: number}}})
+	op.Main().Out().Bufferize()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	op.Start(ctx)
+	op.Main().In().Push(1.0)
+	a.Equal(1.0, op.Main().Out().Pull())
+	a.False(op.Stopped())
+
+	cancel()
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("builtin still pulling")
+	}
+	a.True(op.Stopped())
+	i, ok := op.Main().Out().PullOK()
+	a.False(ok)
+	a.Nil(i)
+}
@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/daemon"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/pkg/storage/storagetest"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemon_AnonymousPermissions(t *testing.T) {
+	a := assertions.New(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	st := storage.NewStorage(storage.NewFileSystem(dir))
+	opId, err := st.Store(storagetest.OperatorDef("anonymous"))
+	require.NoError(t, err)
+
+	srv := daemon.New(*st, "localhost", 0)
+	srv.AddService("/operator", daemon.DefinitionService)
+	srv.AddService("/run", daemon.RunnerService)
+	server := httptest.NewServer(srv.Handler())
+	defer server.Close()
+
+	status := func(method string, path string) int {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader("{}"))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	a.Equal(http.StatusOK, status("GET", "/operator/"))
+	a.Equal(http.StatusForbidden, status("POST", "/run/"))
+	a.Equal(http.StatusForbidden, status("DELETE", "/operator/def/"+opId.String()))
+	a.True(st.IsDumpable(opId))
+	a.Equal(http.StatusNoContent, status("OPTIONS", "/run/"))
+	a.Equal(http.StatusNoContent, status("OPTIONS", "/operator/def/"+opId.String()))
+	a.True(st.IsDumpable(opId))
+
+	a.Error(srv.Auth.SetAnonymousPermissions([]string{"everything"}))
+	require.NoError(t, srv.Auth.SetAnonymousPermissions([]string{daemon.PERM_READ, daemon.PERM_STORE}))
+	a.Equal(http.StatusForbidden, status("POST", "/run/"))
+	a.Equal(http.StatusOK, status("DELETE", "/operator/def/"+opId.String()))
+	a.False(st.IsDumpable(opId))
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/daemon"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemon_ImportStatus(t *testing.T) {
+	a := assertions.New(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	dst := storage.NewStorage(storage.NewFileSystem(dir))
+	srv := daemon.New(*dst, "localhost", 0)
+	require.NoError(t, srv.Auth.SetAnonymousPermissions([]string{daemon.PERM_READ, daemon.PERM_STORE}))
+	srv.AddService("/share", daemon.SharingService)
+	server := httptest.NewServer(srv.Handler())
+	defer server.Close()
+
+	upload := func(bundle []byte) int {
+		body := new(bytes.Buffer)
+		mw := multipart.NewWriter(body)
+		fw, err := mw.CreateFormFile("file", "bundle.zip")
+		require.NoError(t, err)
+		fw.Write(bundle)
+		mw.Close()
+
+		resp, err := http.Post(server.URL+"/share/import", mw.FormDataContentType(), body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	bundle := packTestBundle(t, "test_data/nested_op/usingCustomOp1.json").Bytes()
+	a.Equal(http.StatusBadRequest, upload([]byte("no bundle")))
+	a.Equal(http.StatusOK, upload(bundle))
+	a.Equal(http.StatusOK, upload(bundle))
+
+	bdl, err := storage.ReadBundle(bytes.NewReader(bundle), int64(len(bundle)))
+	require.NoError(t, err)
+	changed := bdl.Operators[0].Copy(true)
+	changed.Meta.Description = "changed locally"
+	_, err = dst.Store(changed)
+	require.NoError(t, err)
+	a.Equal(http.StatusConflict, upload(bundle))
+}
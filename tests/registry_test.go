@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/registry"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_InstallUpdateRemove(t *testing.T) {
+	a := assertions.New(t)
+
+	registryDir, err := ioutil.TempDir("", "slang-registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(registryDir)
+	projectDir, err := ioutil.TempDir("", "slang-project")
+	require.NoError(t, err)
+	defer os.RemoveAll(projectDir)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(registryDir)))
+	defer server.Close()
+
+	archive := packTestBundle(t, "test_data/nested_op/usingCustomOp1.json").Bytes()
+	_, err = registry.Publish(registryDir, "custom", "1.0.0", archive)
+	require.NoError(t, err)
+	_, err = registry.Publish(registryDir, "custom", "1.0.0", archive)
+	a.Error(err)
+
+	project, err := registry.OpenProject(projectDir, server.URL)
+	require.NoError(t, err)
+
+	pkg, err := project.Install("custom", "^1.0.0")
+	require.NoError(t, err)
+	a.Equal("1.0.0", pkg.Version)
+	loader := project.Loader()
+	a.True(loader.Has(Test.getUUIDFromFile("test_data/nested_op/usingCustomOp1.json")))
+	a.True(loader.Has(Test.getUUIDFromFile("test_data/nested_op/customOp.json")))
+
+	_, err = registry.Publish(registryDir, "custom", "1.1.0", archive)
+	require.NoError(t, err)
+	_, err = registry.Publish(registryDir, "custom", "2.0.0", archive)
+	require.NoError(t, err)
+
+	// Reopening the project reads the lock file
+	project, err = registry.OpenProject(projectDir, "")
+	require.NoError(t, err)
+	a.Equal(server.URL, project.Lock.Registry)
+
+	updated, err := project.Update()
+	require.NoError(t, err)
+	require.Len(t, updated, 1)
+	a.Equal("1.1.0", updated[0].Version)
+
+	installed, err := project.Installed("custom")
+	require.NoError(t, err)
+	a.Equal("1.1.0", installed.Version)
+
+	// Sync restores removed package directories from the lock file
+	require.NoError(t, os.RemoveAll(filepath.Join(project.PackagesDir(), "custom")))
+	require.NoError(t, project.Sync())
+	installed, err = project.Installed("custom")
+	require.NoError(t, err)
+	a.Equal("1.1.0", installed.Version)
+
+	require.NoError(t, project.Remove("custom"))
+	a.Len(project.Lock.Packages, 0)
+	a.False(project.Loader().Has(Test.getUUIDFromFile("test_data/nested_op/customOp.json")))
+
+	// Loaders pick up removed packages without being reloaded
+	a.False(loader.Has(Test.getUUIDFromFile("test_data/nested_op/customOp.json")))
+}
+
+func TestRegistry_ChecksumMismatch(t *testing.T) {
+	a := assertions.New(t)
+
+	registryDir, err := ioutil.TempDir("", "slang-registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(registryDir)
+
+	archive := packTestBundle(t, "test_data/nested_op/usingCustomOp1.json").Bytes()
+	release, err := registry.Publish(registryDir, "custom", "1.0.0", archive)
+	require.NoError(t, err)
+
+	tampered := append(append([]byte{}, archive...), 0)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(registryDir, "custom", release.File), tampered, 0644))
+
+	_, err = registry.NewClient("file://"+registryDir).Fetch("custom", release)
+	a.Error(err)
+}
+
+func TestRegistry_FileURLStaysInRegistry(t *testing.T) {
+	a := assertions.New(t)
+
+	registryDir, err := ioutil.TempDir("", "slang-registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(registryDir)
+
+	archive := packTestBundle(t, "test_data/nested_op/usingCustomOp1.json").Bytes()
+	release, err := registry.Publish(registryDir, "custom", "1.0.0", archive)
+	require.NoError(t, err)
+
+	client := registry.NewClient("file://" + registryDir)
+	_, err = client.Fetch("custom", release)
+	a.NoError(err)
+
+	// Files outside of the registry cannot be reached
+	secret := filepath.Join(filepath.Dir(registryDir), filepath.Base(registryDir)+"-secret.zip")
+	require.NoError(t, ioutil.WriteFile(secret, archive, 0644))
+	defer os.Remove(secret)
+	escaping := *release
+	escaping.File = "../../" + filepath.Base(secret)
+	_, err = client.Fetch("custom", &escaping)
+	a.Error(err)
+
+	// Remote registries cannot redirect to files
+	server := httptest.NewServer(http.RedirectHandler("file://"+secret, http.StatusFound))
+	defer server.Close()
+	_, err = registry.NewClient(server.URL).Fetch("custom", &escaping)
+	a.Error(err)
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Bitspark/slang/pkg/core"
@@ -40,7 +41,7 @@ func TestOperator_ReadOperator_1_BuiltinOperator_Eval(t *testing.T) {
 	o.Main().Out().Bufferize()
 	o.Main().In().Push(map[string]interface{}{"a": "hallo"})
 
-	o.Start()
+	o.Start(context.Background())
 
 	a.PortPushesAll([]interface{}{"hallo"}, o.Main().Out())
 }
@@ -53,7 +54,7 @@ func TestOperator_ReadOperator_NestedOperator_1_Child(t *testing.T) {
 	o.Main().Out().Bufferize()
 	o.Main().In().Push("hallo")
 
-	o.Start()
+	o.Start(context.Background())
 
 	a.PortPushesAll([]interface{}{"hallo"}, o.Main().Out())
 }
@@ -66,7 +67,7 @@ func TestOperator_ReadOperator_NestedOperator_N_Child(t *testing.T) {
 	o.Main().Out().Bufferize()
 	o.Main().In().Push("hallo")
 
-	o.Start()
+	o.Start(context.Background())
 
 	a.PortPushesAll([]interface{}{"hallo"}, o.Main().Out())
 }
@@ -80,7 +81,7 @@ func TestOperator_ReadOperator_NestedOperator_SubChild(t *testing.T) {
 	o.Main().In().Push("hallo")
 	o.Main().In().Push(2.0)
 
-	o.Start()
+	o.Start(context.Background())
 
 	a.PortPushesAll([]interface{}{"hallohallo", 4.0}, o.Main().Out())
 }
@@ -94,7 +95,7 @@ func TestOperator_ReadOperator_NestedOperator_Cwd(t *testing.T) {
 	o.Main().In().Push("hey")
 	o.Main().In().Push(false)
 
-	o.Start()
+	o.Start(context.Background())
 
 	a.PortPushesAll([]interface{}{"hey", false}, o.Main().Out())
 }
@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func packTestBundle(t *testing.T, opFile string) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	_, err := st.PackBundle(buf, Test.getUUIDFromFile(opFile), "0.1.0")
+	require.NoError(t, err)
+	return buf
+}
+
+func TestStorage_Bundle__Dependencies(t *testing.T) {
+	a := assertions.New(t)
+
+	opId := Test.getUUIDFromFile("test_data/nested_op/usingCustomOp1.json")
+	customOpId := Test.getUUIDFromFile("test_data/nested_op/customOp.json")
+
+	deps, err := st.Dependencies(opId)
+	require.NoError(t, err)
+	a.Equal([]uuid.UUID{customOpId}, deps)
+}
+
+func TestStorage_Bundle__ExportImport(t *testing.T) {
+	a := assertions.New(t)
+
+	buf := packTestBundle(t, "test_data/nested_op/usingCustomOp1.json")
+	bundle, err := storage.ReadBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	a.Len(bundle.Manifest.Operators, 2)
+	a.Len(bundle.Operators, 2)
+	a.Equal(Test.getUUIDFromFile("test_data/nested_op/usingCustomOp1.json").String(), bundle.Manifest.Main)
+
+	dir, err := ioutil.TempDir("", "slang-bundle")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	dst := storage.NewStorage(storage.NewFileSystem(dir))
+
+	result, err := dst.ImportBundle(bundle, false)
+	require.NoError(t, err)
+	a.Len(result.Imported, 2)
+
+	result, err = dst.ImportBundle(bundle, false)
+	require.NoError(t, err)
+	a.Len(result.Imported, 0)
+	a.Len(result.Unchanged, 2)
+
+	changed := bundle.Operators[0].Copy(true)
+	changed.Meta.Description = "changed locally"
+	_, err = dst.Store(changed)
+	require.NoError(t, err)
+
+	result, err = dst.ImportBundle(bundle, false)
+	a.Error(err)
+	a.Equal([]uuid.UUID{uuid.MustParse(changed.Id)}, result.Conflicts)
+
+	result, err = dst.ImportBundle(bundle, true)
+	require.NoError(t, err)
+	a.Len(result.Imported, 1)
+}
+
+func TestStorage_Bundle__ChecksumMismatch(t *testing.T) {
+	a := assertions.New(t)
+
+	buf := packTestBundle(t, "test_data/nested_op/usingCustomOp1.json")
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	// Copy the bundle but tamper with all operator files
+	tampered := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(tampered)
+	for _, file := range zipReader.File {
+		fileReader, err := file.Open()
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(fileReader)
+		require.NoError(t, err)
+		fileReader.Close()
+
+		if file.Name != storage.BUNDLE_MANIFEST {
+			b = append(b, []byte("\n# tampered\n")...)
+		}
+		fileWriter, err := zipWriter.Create(file.Name)
+		require.NoError(t, err)
+		fileWriter.Write(b)
+	}
+	require.NoError(t, zipWriter.Close())
+
+	_, err = storage.ReadBundle(bytes.NewReader(tampered.Bytes()), int64(tampered.Len()))
+	a.Error(err)
+}
+
+func TestStorage_Bundle__PinnedVersions(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-bundle")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := storage.NewStorage(storage.NewFileSystem(dir))
+
+	trigger := map[string]*core.ServiceDef{
+		"main": {
+			In:  core.TypeDef{Type: "trigger"},
+			Out: core.TypeDef{Type: "trigger"},
+		},
+	}
+	depId := uuid.New()
+	for _, v := range []string{"1.0.0", "2.0.0"} {
+		_, err := src.Store(core.OperatorDef{
+			Id:          depId.String(),
+			Meta:        core.OperatorMetaDef{Name: "versioned", Version: v},
+			ServiceDefs: trigger,
+			Connections: map[string][]string{"(": {")"}},
+		})
+		require.NoError(t, err)
+	}
+
+	mainDef := core.OperatorDef{
+		Id:          uuid.New().String(),
+		Meta:        core.OperatorMetaDef{Name: "pinning"},
+		ServiceDefs: trigger,
+		InstanceDefs: core.InstanceDefList{
+			{Name: "old", Operator: depId.String() + "@>= 1.0, < 2"},
+			{Name: "new", Operator: depId.String() + "@2.0.0"},
+		},
+		Connections: map[string][]string{"(": {"(old"}, "old)": {"(new"}, "new)": {")"}},
+	}
+	require.NoError(t, mainDef.Validate())
+	mainId, err := src.Store(mainDef)
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	manifest, err := src.PackBundle(buf, mainId, "0.1.0")
+	require.NoError(t, err)
+	a.Len(manifest.Operators, 3)
+
+	versions := []string{}
+	for _, entry := range manifest.Operators {
+		if entry.Id == depId.String() {
+			versions = append(versions, entry.Version)
+		}
+	}
+	a.ElementsMatch([]string{"1.0.0", "2.0.0"}, versions)
+
+	bundle, err := storage.ReadBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	dstDir, err := ioutil.TempDir("", "slang-bundle")
+	require.NoError(t, err)
+	defer os.RemoveAll(dstDir)
+	dst := storage.NewStorage(storage.NewFileSystem(dstDir))
+
+	// The highest version becomes the current operator regardless of the order in the bundle
+	for i, j := 0, len(bundle.Operators)-1; i < j; i, j = i+1, j-1 {
+		bundle.Operators[i], bundle.Operators[j] = bundle.Operators[j], bundle.Operators[i]
+	}
+	result, err := dst.ImportBundle(bundle, false)
+	require.NoError(t, err)
+	a.Len(result.Imported, 3)
+	a.ElementsMatch([]string{"1.0.0", "2.0.0"}, dst.Versions(depId))
+	current, err := dst.Load(depId)
+	require.NoError(t, err)
+	a.Equal("2.0.0", current.Meta.Version)
+
+	result, err = dst.ImportBundle(bundle, false)
+	require.NoError(t, err)
+	a.Len(result.Unchanged, 3)
+
+	// Older versions do not replace a newer stored operator
+	newerDir, err := ioutil.TempDir("", "slang-bundle")
+	require.NoError(t, err)
+	defer os.RemoveAll(newerDir)
+	newer := storage.NewStorage(storage.NewFileSystem(newerDir))
+	latest, err := src.LoadVersion(depId, "2.0.0")
+	require.NoError(t, err)
+	_, err = newer.Store(*latest)
+	require.NoError(t, err)
+
+	result, err = newer.ImportBundle(bundle, false)
+	require.NoError(t, err)
+	a.Len(result.Imported, 2)
+	a.ElementsMatch([]string{"1.0.0", "2.0.0"}, newer.Versions(depId))
+	current, err = newer.Load(depId)
+	require.NoError(t, err)
+	a.Equal("2.0.0", current.Meta.Version)
+}
+
+func TestStorage_Bundle__DanglingReference(t *testing.T) {
+	a := assertions.New(t)
+
+	buf := packTestBundle(t, "test_data/nested_op/usingCustomOp1.json")
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	// Copy the bundle but drop the operator the main operator depends on
+	customOpId := Test.getUUIDFromFile("test_data/nested_op/customOp.json").String()
+	dangling := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(dangling)
+	for _, file := range zipReader.File {
+		fileReader, err := file.Open()
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(fileReader)
+		require.NoError(t, err)
+		fileReader.Close()
+
+		if file.Name == storage.BUNDLE_MANIFEST {
+			var manifest storage.BundleManifest
+			require.NoError(t, yaml.Unmarshal(b, &manifest))
+			entries := manifest.Operators[:0]
+			for _, entry := range manifest.Operators {
+				if entry.Id != customOpId {
+					entries = append(entries, entry)
+				}
+			}
+			manifest.Operators = entries
+			b, err = yaml.Marshal(&manifest)
+			require.NoError(t, err)
+		}
+		fileWriter, err := zipWriter.Create(file.Name)
+		require.NoError(t, err)
+		fileWriter.Write(b)
+	}
+	require.NoError(t, zipWriter.Close())
+
+	_, err = storage.ReadBundle(bytes.NewReader(dangling.Bytes()), int64(dangling.Len()))
+	a.Error(err)
+}
+
+func TestStorage_Bundle__MaxSize(t *testing.T) {
+	a := assertions.New(t)
+
+	buf := packTestBundle(t, "test_data/nested_op/usingCustomOp1.json")
+
+	defer func(max int64) { storage.MaxBundleSize = max }(storage.MaxBundleSize)
+	storage.MaxBundleSize = int64(buf.Len())
+	_, err := storage.ReadBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	a.Error(err, "extracted files are larger than the archive")
+
+	storage.MaxBundleSize = int64(buf.Len()) - 1
+	_, err = storage.ReadBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	a.Error(err)
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/pkg/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "slang-storage")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestStorage_Conformance__FileSystem(t *testing.T) {
+	dirs := []string{}
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	storagetest.TestLoaderDumper(t, func(t *testing.T) storage.LoaderDumper {
+		dir := tempDir(t)
+		dirs = append(dirs, dir)
+		return storage.NewFileSystem(dir)
+	})
+}
+
+func TestStorage_Conformance__GitFileSystem(t *testing.T) {
+	dirs := []string{}
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	storagetest.TestLoaderDumper(t, func(t *testing.T) storage.LoaderDumper {
+		dir := tempDir(t)
+		dirs = append(dirs, dir)
+		gitFs, err := storage.NewGitFileSystem(dir)
+		require.NoError(t, err)
+		return gitFs
+	})
+}
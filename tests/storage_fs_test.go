@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testFsOpDef(name string, tags ...string) core.OperatorDef {
+	return core.OperatorDef{
+		Id:   uuid.New().String(),
+		Meta: core.OperatorMetaDef{Name: name, Tags: tags},
+		ServiceDefs: map[string]*core.ServiceDef{
+			"main": {
+				In:  core.TypeDef{Type: "trigger"},
+				Out: core.TypeDef{Type: "trigger"},
+			},
+		},
+		Connections: map[string][]string{"(": {")"}},
+	}
+}
+
+func TestFileSystem__ReadablePaths(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-fs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fs := storage.NewFileSystem(dir)
+
+	first := testFsOpDef("Add Numbers", "Math")
+	opId, err := fs.Dump(first)
+	require.NoError(t, err)
+	path, err := fs.Path(opId)
+	require.NoError(t, err)
+	a.Equal(filepath.Join(dir, "math", "add-numbers.yaml"), path)
+
+	// Names are disambiguated by the id
+	second := testFsOpDef("Add Numbers", "Math")
+	opId, err = fs.Dump(second)
+	require.NoError(t, err)
+	path, err = fs.Path(opId)
+	require.NoError(t, err)
+	a.Equal(filepath.Join(dir, "math", "add-numbers-"+second.Id[:8]+".yaml"), path)
+
+	// Renaming an operator keeps its file
+	first.Meta.Name = "Sum"
+	opId, err = fs.Dump(first)
+	require.NoError(t, err)
+	path, err = fs.Path(opId)
+	require.NoError(t, err)
+	a.Equal(filepath.Join(dir, "math", "add-numbers.yaml"), path)
+}
+
+func TestFileSystem__IndexesById(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-fs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Files written by someone else, in sub directories and with arbitrary names
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), os.ModePerm))
+	opDef := testFsOpDef("nested")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "b", "my operator.json"), []byte(`{"id": "`+opDef.Id+`", "meta": {"name": "nested"},
+		"services": {"main": {"in": {"type": "trigger"}, "out": {"type": "trigger"}}}, "connections": {"(": [")"]}}`), 0644))
+
+	fs := storage.NewFileSystem(dir)
+	loaded, err := fs.Load(uuid.MustParse(opDef.Id))
+	require.NoError(t, err)
+	a.Equal("nested", loaded.Meta.Name)
+
+	// Operators stay in their file and format when stored
+	loaded.Meta.Description = "changed"
+	_, err = fs.Dump(*loaded)
+	require.NoError(t, err)
+	path, err := fs.Path(uuid.MustParse(opDef.Id))
+	require.NoError(t, err)
+	a.Equal(filepath.Join(dir, "a", "b", "my operator.json"), path)
+
+	loaded, err = fs.Load(uuid.MustParse(opDef.Id))
+	require.NoError(t, err)
+	a.Equal("changed", loaded.Meta.Description)
+}
+
+func TestFileSystem__Watch(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-fs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := storage.NewFileSystem(dir)
+	require.NoError(t, fs.Watch())
+	defer fs.Close()
+
+	opDef := testFsOpDef("watched")
+	opId := uuid.MustParse(opDef.Id)
+	a.False(fs.Has(opId))
+
+	// Another storage writes into the same directory
+	_, err = storage.NewFileSystem(dir).Dump(opDef)
+	require.NoError(t, err)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !fs.Has(opId) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.True(fs.Has(opId))
+}
+
+func TestFileSystem__WatchNotifies(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-fs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := storage.NewFileSystem(dir)
+	require.NoError(t, fs.Watch())
+	defer fs.Close()
+
+	changes := make(chan storage.OperatorChange, 16)
+	storage.NewStorage(fs).Subscribe(changes)
+
+	opDef := testFsOpDef("notified")
+	opId := uuid.MustParse(opDef.Id)
+	_, err = storage.NewFileSystem(dir).Dump(opDef)
+	require.NoError(t, err)
+
+	nextChange := func() storage.OperatorChange {
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(2 * time.Second):
+			t.Fatal("no change received")
+			return storage.OperatorChange{}
+		}
+	}
+
+	change := nextChange()
+	a.Equal(opId, change.Id)
+	a.Equal(storage.CHANGE_STORED, change.Kind)
+
+	// Drain further notifications caused by writing the file
+	time.Sleep(100 * time.Millisecond)
+	for len(changes) != 0 {
+		<-changes
+	}
+
+	require.NoError(t, os.Remove(change.Path))
+	change = nextChange()
+	a.Equal(opId, change.Id)
+	a.Equal(storage.CHANGE_REMOVED, change.Kind)
+	a.False(fs.Has(opId))
+}
+
+func TestFileSystem__WatchResync(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-fs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := storage.NewFileSystem(dir)
+	require.NoError(t, fs.Watch())
+	defer fs.Close()
+
+	changes := make(chan storage.OperatorChange, 1)
+	st := storage.NewStorage(fs)
+	st.Subscribe(changes)
+	defer st.Unsubscribe(changes)
+
+	// The subscriber does not read, so that the changes of the second operator are dropped
+	writer := storage.NewFileSystem(dir)
+	_, err = writer.Dump(testFsOpDef("first"))
+	require.NoError(t, err)
+	_, err = writer.Dump(testFsOpDef("second"))
+	require.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case change := <-changes:
+			if change.Kind == storage.CHANGE_RESYNC {
+				a.Equal(uuid.Nil, change.Id)
+				return
+			}
+		case <-deadline:
+			t.Fatal("no resync received")
+		}
+	}
+}
+
+func TestStorage_WithLoader(t *testing.T) {
+	a := assertions.New(t)
+
+	opDef := testFsOpDef("added")
+	opId := uuid.MustParse(opDef.Id)
+
+	st := storage.NewStorage(nil)
+	added := st.WithLoader(storage.NewMemoryLoader(opDef))
+
+	a.True(added.IsLoadable(opId))
+	a.False(st.IsLoadable(opId))
+}
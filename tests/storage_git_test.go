@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+func TestStorage_Git__HistoryAndRestore(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-git")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gitFs, err := storage.NewGitFileSystem(dir)
+	require.NoError(t, err)
+	st := storage.NewStorage(gitFs)
+
+	opId := uuid.New()
+	opDef := core.OperatorDef{
+		Id:   opId.String(),
+		Meta: core.OperatorMetaDef{Name: "history"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			"main": {
+				In:  core.TypeDef{Type: "number"},
+				Out: core.TypeDef{Type: "number"},
+			},
+		},
+		Connections: map[string][]string{"(": {")"}},
+	}
+
+	revs, err := st.Revisions(opId)
+	require.NoError(t, err)
+	a.Len(revs, 0)
+
+	_, err = st.Store(opDef)
+	require.NoError(t, err)
+
+	// Storing an unchanged operator does not create a revision
+	_, err = st.Store(opDef)
+	require.NoError(t, err)
+
+	opDef.Meta.Description = "changed"
+	opDef.ServiceDefs["main"].Out.Type = "string"
+	_, err = st.Store(opDef)
+	require.NoError(t, err)
+
+	revs, err = st.Revisions(opId)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+
+	first, err := st.LoadRevision(opId, revs[1].Hash)
+	require.NoError(t, err)
+	current, err := st.LoadRevision(opId, "")
+	require.NoError(t, err)
+
+	changes, err := storage.Diff(first, current)
+	require.NoError(t, err)
+	a.Equal([]storage.Change{
+		{Path: "meta.description", Kind: storage.DIFF_CHANGED, From: "", To: "changed"},
+		{Path: "services.main.out.type", Kind: storage.DIFF_CHANGED, From: "number", To: "string"},
+	}, changes)
+
+	restored, err := st.Restore(opId, revs[1].Hash)
+	require.NoError(t, err)
+	a.Equal("number", restored.ServiceDefs["main"].Out.Type)
+
+	current, err = st.Load(opId)
+	require.NoError(t, err)
+	a.Equal("", current.Meta.Description)
+
+	revs, err = st.Revisions(opId)
+	require.NoError(t, err)
+	a.Len(revs, 3)
+}
+
+func TestStorage_Git__StoreVersion(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-git")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gitFs, err := storage.NewGitFileSystem(dir)
+	require.NoError(t, err)
+	st := storage.NewStorage(gitFs)
+
+	opId := uuid.New()
+	opDef := core.OperatorDef{
+		Id:   opId.String(),
+		Meta: core.OperatorMetaDef{Name: "versioned", Version: "2.0.0"},
+		ServiceDefs: map[string]*core.ServiceDef{
+			"main": {
+				In:  core.TypeDef{Type: "number"},
+				Out: core.TypeDef{Type: "number"},
+			},
+		},
+		Connections: map[string][]string{"(": {")"}},
+	}
+	_, err = st.Store(opDef)
+	require.NoError(t, err)
+
+	opDef.Meta.Version = "1.0.0"
+	_, err = st.StoreVersion(opDef)
+	require.NoError(t, err)
+
+	current, err := st.Load(opId)
+	require.NoError(t, err)
+	a.Equal("2.0.0", current.Meta.Version)
+	a.ElementsMatch([]string{"1.0.0", "2.0.0"}, st.Versions(opId))
+
+	// The version file has been committed
+	repo, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	status, err := wt.Status()
+	require.NoError(t, err)
+	a.True(status.IsClean())
+}
+
+func TestStorage_Diff__Instances(t *testing.T) {
+	a := assertions.New(t)
+
+	from := &core.OperatorDef{InstanceDefs: core.InstanceDefList{
+		{Name: "a", Operator: "op1"},
+		{Name: "b", Operator: "op2"},
+	}}
+	to := &core.OperatorDef{InstanceDefs: core.InstanceDefList{
+		{Name: "b", Operator: "op2"},
+		{Name: "c", Operator: "op3"},
+	}}
+
+	changes, err := storage.Diff(from, to)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	a.Equal("operators.a", changes[0].Path)
+	a.Equal(storage.DIFF_REMOVED, changes[0].Kind)
+	a.Equal("operators.c", changes[1].Path)
+	a.Equal(storage.DIFF_ADDED, changes[1].Kind)
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_DependencyGraph(t *testing.T) {
+	a := assertions.New(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	st := storage.NewStorage(storage.NewFileSystem(dir))
+
+	leaf := testFsOpDef("leaf")
+	leafId, err := st.Store(leaf)
+	require.NoError(t, err)
+	middle := testFsOpDef("middle")
+	middle.InstanceDefs = core.InstanceDefList{{Name: "leaf", Operator: leaf.Id}}
+	middleId, err := st.Store(middle)
+	require.NoError(t, err)
+	top := testFsOpDef("top")
+	top.InstanceDefs = core.InstanceDefList{{Name: "middle", Operator: middle.Id}, {Name: "value", Operator: elem.GetId("value").String()}}
+	topId, err := st.Store(top)
+	require.NoError(t, err)
+
+	g, err := st.DependencyGraph()
+	require.NoError(t, err)
+	a.Equal("middle", g.Name(middleId))
+
+	a.Equal([]uuid.UUID{middleId}, g.Dependencies(topId, false))
+	a.ElementsMatch([]uuid.UUID{middleId, leafId}, g.Dependencies(topId, true))
+	a.Equal([]uuid.UUID{middleId}, g.Dependents(leafId, false))
+	a.ElementsMatch([]uuid.UUID{middleId, topId}, g.Dependents(leafId, true))
+	a.Empty(g.Dependents(topId, true))
+
+	dependents, err := st.Dependents(middleId)
+	require.NoError(t, err)
+	a.Equal([]uuid.UUID{topId}, dependents)
+}
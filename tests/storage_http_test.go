@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/daemon"
+	"github.com/Bitspark/slang/pkg/elem"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/pkg/storage/storagetest"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestStorage_HTTPLoader(t *testing.T) {
+	a := assertions.New(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	remote := storage.NewStorage(storage.NewFileSystem(dir))
+	opDef := storagetest.OperatorDef("remote")
+	opId, err := remote.Store(opDef)
+	require.NoError(t, err)
+
+	srv := daemon.New(*remote, "localhost", 0)
+	srv.Auth.AddAPIKey("test", "secret")
+	srv.AddService("/operator", daemon.DefinitionService)
+	paths := make(chan string, 64)
+	handler := srv.Handler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	_, err = storage.NewHTTPLoader(server.URL, "wrong").List()
+	a.Error(err)
+
+	loader := storage.NewHTTPLoader(server.URL, "secret")
+	ids, err := loader.List()
+	require.NoError(t, err)
+	a.Equal([]uuid.UUID{opId}, ids)
+	a.False(loader.Has(elem.GetId("value")))
+
+	st := storage.NewStorage(nil).AddLoader(loader)
+	for len(paths) != 0 {
+		<-paths
+	}
+	loaded, err := st.Load(opId)
+	require.NoError(t, err)
+	a.Equal("remote", loaded.Meta.Name)
+	a.Equal("/operator/def/"+opId.String(), <-paths)
+
+	_, err = loader.Load(uuid.New())
+	a.Error(err)
+
+	get := func(path string) map[string]interface{} {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var data map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+		return data
+	}
+
+	// Filtered listing
+	a.Equal(float64(1), get("/operator/?type=local&name=REM")["total"])
+	a.Equal(float64(0), get("/operator/?type=local&tag=unknown")["total"])
+	a.Len(get("/operator/?type=elementary&limit=2")["objects"], 2)
+	a.Len(get("/operator/?type=elementary&offset=1&limit=9223372036854775807")["objects"], int(get("/operator/?type=elementary")["total"].(float64))-1)
+
+	// SlangFileDef of an operator
+	req, _ := http.NewRequest("GET", server.URL+"/operator/def/"+opId.String()+"/slangfile/?format=yaml", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	var slFile core.SlangFileDef
+	require.NoError(t, yaml.NewDecoder(resp.Body).Decode(&slFile))
+	resp.Body.Close()
+	a.Equal(opId.String(), slFile.Main)
+	a.Len(slFile.Blueprints, 1)
+
+	// Deleting
+	req, _ = http.NewRequest("DELETE", server.URL+"/operator/def/"+opId.String(), nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	a.False(remote.IsDumpable(opId))
+	a.Equal(float64(0), get("/operator/?type=local")["total"])
+}
@@ -0,0 +1,32 @@
+//go:build cgo
+// +build cgo
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/pkg/storage/storagetest"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Conformance__SQLite(t *testing.T) {
+	dirs := []string{}
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	storagetest.TestLoaderDumper(t, func(t *testing.T) storage.LoaderDumper {
+		dir := tempDir(t)
+		dirs = append(dirs, dir)
+		sqlStorage, err := storage.OpenSQLStorage("sqlite3", filepath.Join(dir, "operators.db"))
+		require.NoError(t, err)
+		return sqlStorage
+	})
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Bitspark/go-version"
+	"github.com/Bitspark/slang/pkg/core"
+	"github.com/Bitspark/slang/pkg/storage"
+	"github.com/Bitspark/slang/tests/assertions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_ParseConstraint(t *testing.T) {
+	a := assertions.New(t)
+
+	cases := []struct {
+		constraint string
+		matching   []string
+		failing    []string
+	}{
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4", "1.2.2"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0"}},
+		{">=1.0,<2", []string{"1.0.0", "1.5.0"}, []string{"2.0.0", "0.9.0"}},
+	}
+
+	for _, c := range cases {
+		cs, err := storage.ParseConstraint(c.constraint)
+		require.NoError(t, err)
+		for _, v := range c.matching {
+			a.True(cs.Check(version.Must(version.NewVersion(v))), "%s should satisfy %s", v, c.constraint)
+		}
+		for _, v := range c.failing {
+			a.False(cs.Check(version.Must(version.NewVersion(v))), "%s should not satisfy %s", v, c.constraint)
+		}
+	}
+}
+
+func TestStorage_Versions__SideBySide(t *testing.T) {
+	a := assertions.New(t)
+
+	dir, err := ioutil.TempDir("", "slang-versions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	st := storage.NewStorage(storage.NewFileSystem(dir))
+
+	opId := uuid.New()
+	for _, v := range []string{"1.0.0", "1.1.0", "2.0.0"} {
+		_, err := st.Store(core.OperatorDef{
+			Id:   opId.String(),
+			Meta: core.OperatorMetaDef{Name: "versioned", Version: v},
+			ServiceDefs: map[string]*core.ServiceDef{
+				"main": {
+					In:  core.TypeDef{Type: "trigger"},
+					Out: core.TypeDef{Type: "trigger"},
+				},
+			},
+			Connections: map[string][]string{"(": {")"}},
+		})
+		require.NoError(t, err)
+	}
+
+	a.ElementsMatch([]string{"1.0.0", "1.1.0", "2.0.0"}, st.Versions(opId))
+
+	opDef, err := st.Load(opId)
+	require.NoError(t, err)
+	a.Equal("2.0.0", opDef.Meta.Version)
+
+	opDef, err = st.LoadRef(opId.String() + "@^1.0.0")
+	require.NoError(t, err)
+	a.Equal("1.1.0", opDef.Meta.Version)
+
+	opDef, err = st.LoadRef(opId.String() + "@1.0.0")
+	require.NoError(t, err)
+	a.Equal("1.0.0", opDef.Meta.Version)
+
+	_, err = st.LoadRef(opId.String() + "@^3.0.0")
+	a.Error(err)
+}